@@ -4,15 +4,26 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"regexp"
 
 	"github.com/joho/godotenv"
 
 	"github.com/rodrigogmartins/log-processor/internal/api"
+	"github.com/rodrigogmartins/log-processor/internal/api/handlers"
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
 	"github.com/rodrigogmartins/log-processor/internal/config"
+	"github.com/rodrigogmartins/log-processor/internal/container"
 	"github.com/rodrigogmartins/log-processor/internal/db"
+	"github.com/rodrigogmartins/log-processor/internal/filetail"
+	"github.com/rodrigogmartins/log-processor/internal/fluent"
+	"github.com/rodrigogmartins/log-processor/internal/health"
 	"github.com/rodrigogmartins/log-processor/internal/kafka"
+	"github.com/rodrigogmartins/log-processor/internal/metrics"
 	"github.com/rodrigogmartins/log-processor/internal/service"
 	"github.com/rodrigogmartins/log-processor/internal/shutdown"
+	"github.com/rodrigogmartins/log-processor/internal/syslog"
+	"github.com/rodrigogmartins/log-processor/internal/tail"
+	"github.com/rodrigogmartins/log-processor/internal/wal"
 )
 
 func main() {
@@ -26,10 +37,15 @@ func main() {
 	// --- Initializing services ---
 	esClient, err := db.NewElasticSearchClient([]string{cfg.ElasticHost})
 	if err != nil {
-		log.Printf("Error trying to create ElasticSearchClient: %v", err)
+		log.Fatalf("Error trying to create ElasticSearchClient: %v", err)
 	}
 
-	logService := service.NewLogService(esClient, cfg.ElasticIndex)
+	esBreaker := breaker.New("elasticsearch", cfg.ElasticBreakerFailures, cfg.ElasticBreakerProbe, esClient.Ping)
+	guardedClient := breaker.NewElasticSearchClient(metrics.NewElasticSearchClient(esClient), esBreaker)
+
+	logService := service.NewLogService(guardedClient, cfg.ElasticIndex)
+	tailHub := tail.NewHub(cfg.TailBufferSize)
+	logService.SetPublisher(tailHub)
 	log.Println(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID)
 	consumer := kafka.NewKafkaConsumer(cfg.KafkaBrokers, cfg.KafkaTopic, cfg.KafkaGroupID)
 	processor := kafka.NewProcessor(
@@ -39,11 +55,155 @@ func main() {
 		cfg.MaxConsumeRetries,
 		cfg.BackOffRetries,
 	)
+	processor.Retry.MaxInterval = cfg.RetryMaxInterval
+	processor.Retry.MaxElapsedTime = cfg.RetryMaxElapsedTime
+	processor.Batch = kafka.BatchConfig{
+		MaxDocs:    cfg.ElasticBulkMaxDocs,
+		MaxBytes:   cfg.ElasticBulkMaxBytes,
+		MaxLatency: cfg.ElasticBulkMaxLatency,
+	}
+	processor.OrderByKey = cfg.KafkaOrderByKey
+	processor.Breaker = esBreaker
+	processor.Headers = kafka.HeaderMapping{
+		Allowlist:    cfg.KafkaHeaderAllowlist,
+		Renames:      cfg.KafkaHeaderRenames,
+		SourceHeader: cfg.KafkaSourceHeader,
+	}
+
+	if err := processor.Parsers.SetDefault(cfg.LogFormatDefault); err != nil {
+		log.Printf("Ignoring LOG_FORMAT_DEFAULT: %v", err)
+	}
+	for topic, format := range cfg.LogFormatByTopic {
+		if err := processor.Parsers.SetTopicFormat(topic, format); err != nil {
+			log.Printf("Ignoring LOG_FORMAT_BY_TOPIC entry: %v", err)
+		}
+	}
+
+	processor.Multiline = kafka.MultilineConfig{
+		MaxLines:     cfg.MultilineMaxLines,
+		MaxBytes:     cfg.MultilineMaxBytes,
+		FlushTimeout: cfg.MultilineFlushTimeout,
+	}
+	if cfg.MultilineStartPattern != "" {
+		if pattern, err := regexp.Compile(cfg.MultilineStartPattern); err != nil {
+			log.Printf("Ignoring MULTILINE_START_PATTERN: %v", err)
+		} else {
+			processor.Multiline.StartPattern = pattern
+		}
+	}
+	if cfg.MultilineContinuationPattern != "" {
+		if pattern, err := regexp.Compile(cfg.MultilineContinuationPattern); err != nil {
+			log.Printf("Ignoring MULTILINE_CONTINUATION_PATTERN: %v", err)
+		} else {
+			processor.Multiline.ContinuationPattern = pattern
+		}
+	}
+
+	shutdownables := []shutdown.Shutdownable{consumer, tailHub}
+	// The inputs below drain through the processor, so the sinks it falls
+	// back on are closed after them.
+	var sinks []shutdown.Shutdownable
+
+	var buffer *wal.WAL
+	if cfg.WALDir != "" {
+		buffer, err = wal.Open(cfg.WALDir, cfg.WALMaxSegmentBytes, cfg.WALMaxBytes)
+		if err != nil {
+			log.Fatalf("Error opening WAL at %s: %v", cfg.WALDir, err)
+		}
+		processor.WAL = buffer
+		sinks = append(sinks, buffer)
+	}
+
+	if cfg.KafkaDeadLetterTopic != "" {
+		deadLetter := kafka.NewKafkaWriter(cfg.KafkaBrokers, cfg.KafkaDeadLetterTopic)
+		processor.DeadLetter = deadLetter
+		sinks = append(sinks, deadLetter)
+	}
+
+	var ingestSink handlers.IngestSink
+	switch cfg.IngestMode {
+	case "direct":
+		ingestSink = processor
+	case "kafka":
+		ingestWriter := kafka.NewKafkaWriter(cfg.KafkaBrokers, cfg.KafkaTopic)
+		ingestSink = kafka.NewProducer(ingestWriter)
+		shutdownables = append(shutdownables, ingestWriter)
+	default:
+		log.Printf("Ignoring INGEST_MODE %q, POST /logs is disabled", cfg.IngestMode)
+	}
+
+	// OTLP follows INGEST_MODE, and is processed directly when it is not set
+	// to a valid mode.
+	otlpSink := ingestSink
+	if otlpSink == nil {
+		otlpSink = processor
+	}
+
+	var syslogServer *syslog.Server
+	if cfg.SyslogUDPAddr != "" || cfg.SyslogTCPAddr != "" {
+		syslogServer = syslog.NewServer(cfg.SyslogUDPAddr, cfg.SyslogTCPAddr, processor)
+		syslogServer.MaxMessageBytes = cfg.SyslogMaxMessageBytes
+		shutdownables = append(shutdownables, syslogServer)
+	}
+
+	var fluentServer *fluent.Server
+	if cfg.FluentForwardAddr != "" {
+		fluentServer = fluent.NewServer(cfg.FluentForwardAddr, processor)
+		shutdownables = append(shutdownables, fluentServer)
+	}
+
+	var tailers []*filetail.Tailer
+	if len(cfg.FileTailPatterns) > 0 {
+		tailer := filetail.NewTailer(cfg.FileTailPatterns, cfg.FileTailCheckpoint, processor)
+		tailer.PollInterval = cfg.FileTailPollInterval
+		tailer.Format = cfg.FileTailFormat
+		tailer.Parsers = processor.Parsers
+		tailers = append(tailers, tailer)
+	}
+
+	if len(cfg.ContainerLogPatterns) > 0 {
+		tailer := filetail.NewTailer(cfg.ContainerLogPatterns, cfg.ContainerLogCheckpoint, processor)
+		tailer.PollInterval = cfg.FileTailPollInterval
+		tailer.Decoder = container.Decoder{}
+		tailer.Parsers = processor.Parsers
+		tailers = append(tailers, tailer)
+	}
+
+	for _, tailer := range tailers {
+		shutdownables = append(shutdownables, tailer)
+	}
+	shutdownables = append(shutdownables, sinks...)
 
 	// --- Inicializa graceful shutdown ---
-	shutdownables := []shutdown.Shutdownable{consumer}
 	ctx = shutdown.Graceful(ctx, shutdownables, cfg.ShutdownTimeout)
 
+	go esBreaker.Run(ctx)
+
+	if buffer != nil {
+		replayer := wal.NewReplayer(buffer, guardedClient, cfg.ElasticIndex, cfg.WALReplayInterval, func() bool {
+			return esBreaker.State() == breaker.Closed
+		})
+		go replayer.Run(ctx)
+	}
+
+	if syslogServer != nil {
+		if err := syslogServer.Start(ctx); err != nil {
+			log.Fatalf("Error starting syslog input: %v", err)
+		}
+	}
+
+	if fluentServer != nil {
+		if err := fluentServer.Start(ctx); err != nil {
+			log.Fatalf("Error starting fluent forward input: %v", err)
+		}
+	}
+
+	for _, tailer := range tailers {
+		if err := tailer.Start(ctx); err != nil {
+			log.Fatalf("Error starting file tail input: %v", err)
+		}
+	}
+
 	// --- Rodando processor em goroutine ---
 	go func() {
 		log.Println("Starting Kafka processor")
@@ -52,8 +212,26 @@ func main() {
 		}
 	}()
 
+	readiness := health.NewChecker(cfg.HealthCheckTimeout)
+	readiness.Register("kafka", consumer.GroupMembership)
+	readiness.Register("elasticsearch", esClient.Health)
+	readiness.Register("processor", processor.Health)
+
 	// --- Inicializa API ---
-	router := api.NewRouter(esClient, cfg.ElasticIndex)
+	router := api.NewRouter(api.RouterConfig{
+		ESClient: guardedClient,
+		Index:    cfg.ElasticIndex,
+		Breaker:  esBreaker,
+		WAL:      buffer,
+		Health:   readiness,
+
+		Tail:          tailHub,
+		TailHeartbeat: cfg.TailHeartbeatInterval,
+
+		Ingest:         ingestSink,
+		OTLP:           otlpSink,
+		IngestMaxBytes: cfg.IngestMaxBytes,
+	})
 	server := &http.Server{
 		Addr:    cfg.APIPort,
 		Handler: router,
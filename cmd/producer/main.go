@@ -2,23 +2,17 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rodrigogmartins/log-processor/internal/config"
+	logkafka "github.com/rodrigogmartins/log-processor/internal/kafka"
+	"github.com/rodrigogmartins/log-processor/internal/service"
 	"github.com/segmentio/kafka-go"
 )
 
-type LogMessage struct {
-	ID        string `json:"id"`
-	Level     string `json:"level"`
-	Message   string `json:"message"`
-	Timestamp string `json:"timestamp"`
-}
-
 func main() {
 	if err := godotenv.Load(".env"); err != nil {
 		log.Println("No .env file found, using defaults or system env")
@@ -31,27 +25,19 @@ func main() {
 		log.Fatalf("Error trying to check the topic %v", err)
 	}
 
-	writer := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  cfg.KafkaBrokers,
-		Topic:    cfg.KafkaTopic,
-		Balancer: &kafka.LeastBytes{},
-	})
+	writer := logkafka.NewKafkaWriter(cfg.KafkaBrokers, cfg.KafkaTopic)
 	defer writer.Close()
+	producer := logkafka.NewProducer(writer)
 
 	for i := 1; i <= 5; i++ {
-		msg := LogMessage{
+		msg := service.Log{
 			ID:        fmt.Sprintf("%v-%v", time.Now().Format("20060102150405"), i),
 			Level:     "INFO",
 			Message:   fmt.Sprintf("Test message number %v", i),
-			Timestamp: time.Now().Format(time.RFC3339),
+			Timestamp: time.Now().UTC(),
 		}
 
-		data, _ := json.Marshal(msg)
-		err := writer.WriteMessages(context.Background(), kafka.Message{
-			Key:   []byte(msg.ID),
-			Value: data,
-		})
-		if err != nil {
+		if err := producer.ProcessBatch(context.Background(), []service.Log{msg})[0]; err != nil {
 			log.Fatalf("Error trying to publish message: %v", err)
 		}
 
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	defaultStatsSize     = 10
+	maxStatsSize         = 100
+	defaultInterval      = "1m"
+	defaultHistogramFrom = "now-1h"
+	maxHistogramBuckets  = 10000
+	aggregationBucket    = "buckets"
+)
+
+// histogramInterval matches the fixed intervals accepted by the histogram.
+var histogramInterval = regexp.MustCompile(`^([1-9]\d*)(ms|s|m|h|d)$`)
+
+var intervalUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+}
+
+type AggregationHandler struct {
+	LogService service.ElasticSearchClient
+	Index      string
+}
+
+// AggregationResponse lists the log counts per bucket of the logs matching
+// the filters, Total being the count of all of them.
+type AggregationResponse struct {
+	Total    int64            `json:"total"`
+	Interval string           `json:"interval,omitempty"`
+	Buckets  []service.Bucket `json:"buckets"`
+}
+
+// GET /logs/stats/levels?size=10&from=now-1h&...
+func (h *AggregationHandler) CountByLevel(w http.ResponseWriter, r *http.Request) {
+	h.countByField(w, r, "level")
+}
+
+// GET /logs/stats/sources?size=10&from=now-1h&...
+func (h *AggregationHandler) CountBySource(w http.ResponseWriter, r *http.Request) {
+	h.countByField(w, r, "source")
+}
+
+// GET /logs/histogram?interval=1m&from=now-1h&to=now&...
+//
+// The range defaults to the last hour, and is refused when it holds more
+// than maxHistogramBuckets intervals rather than failing in Elasticsearch.
+func (h *AggregationHandler) Histogram(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if strings.TrimSpace(params.Get("from")) == "" {
+		params.Set("from", defaultHistogramFrom)
+	}
+	if strings.TrimSpace(params.Get("to")) == "" {
+		params.Set("to", "now")
+	}
+	where, errs := logFilters(params)
+
+	interval := params.Get("interval")
+	if interval == "" {
+		interval = defaultInterval
+	}
+	if width, ok := intervalDuration(interval); !ok {
+		errs = append(errs, FieldError{Parameter: "interval", Message: fmt.Sprintf("must be a number followed by ms, s, m, h or d, got %q", interval)})
+	} else if buckets := histogramBuckets(params, width, time.Now()); buckets > maxHistogramBuckets {
+		errs = append(errs, FieldError{Parameter: "interval", Message: fmt.Sprintf("gives %d buckets between from and to, at most %d are allowed", buckets, maxHistogramBuckets)})
+	}
+
+	h.aggregate(w, r, errs, AggregationResponse{Interval: interval}, where, service.Aggregation{
+		Name:     aggregationBucket,
+		Kind:     service.AggregateDateHistogram,
+		Field:    "timestamp",
+		Interval: interval,
+	})
+}
+
+func intervalDuration(interval string) (time.Duration, bool) {
+	match := histogramInterval.FindStringSubmatch(interval)
+	if match == nil {
+		return 0, false
+	}
+	unit := intervalUnits[match[2]]
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	// Larger intervals would overflow time.Duration.
+	if err != nil || n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// histogramBuckets estimates how many intervals fit between the from and to
+// parameters, zero when either cannot be resolved.
+func histogramBuckets(params url.Values, width time.Duration, now time.Time) int64 {
+	from, ok := resolveTime(params.Get("from"), now)
+	if !ok {
+		return 0
+	}
+	to, ok := resolveTime(params.Get("to"), now)
+	if !ok || !to.After(from) {
+		return 0
+	}
+	return int64(to.Sub(from)/width) + 1
+}
+
+func (h *AggregationHandler) countByField(w http.ResponseWriter, r *http.Request, field string) {
+	params := r.URL.Query()
+	where, errs := logFilters(params)
+
+	size, err := statsSize(params)
+	if err != nil {
+		errs = append(errs, *err)
+	}
+
+	h.aggregate(w, r, errs, AggregationResponse{}, where, service.Aggregation{
+		Name:  aggregationBucket,
+		Kind:  service.AggregateTerms,
+		Field: field,
+		Size:  size,
+	})
+}
+
+func (h *AggregationHandler) aggregate(w http.ResponseWriter, r *http.Request, errs []FieldError, resp AggregationResponse, where service.Condition, agg service.Aggregation) {
+	if len(errs) > 0 {
+		writeInvalidParameters(w, errs)
+		return
+	}
+
+	result, err := h.LogService.AggregateLogs(r.Context(), h.Index, where, []service.Aggregation{agg})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: err.Error()})
+		return
+	}
+
+	resp.Total = result.Total
+	resp.Buckets = result.Buckets[aggregationBucket]
+	if resp.Buckets == nil {
+		resp.Buckets = []service.Bucket{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func statsSize(params url.Values) (int, *FieldError) {
+	raw := params.Get("size")
+	if raw == "" {
+		return defaultStatsSize, nil
+	}
+
+	size, err := strconv.Atoi(raw)
+	if err != nil || size < 1 || size > maxStatsSize {
+		return 0, &FieldError{Parameter: "size", Message: fmt.Sprintf("must be between 1 and %d", maxStatsSize)}
+	}
+	return size, nil
+}
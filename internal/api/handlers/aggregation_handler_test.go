@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregationHandler_CountByLevel(t *testing.T) {
+	t.Run("GIVEN filters WHEN counting by level THEN aggregate the matching logs", func(t *testing.T) {
+		var where service.Condition
+		var aggs []service.Aggregation
+		mockClient := &MockElasticSearchClient{
+			AggregateFunc: func(ctx context.Context, index string, w service.Condition, a []service.Aggregation) (service.AggregationResult, error) {
+				where, aggs = w, a
+				return service.AggregationResult{
+					Total: 3,
+					Buckets: map[string][]service.Bucket{
+						"buckets": {{Key: "ERROR", Count: 2}, {Key: "INFO", Count: 1}},
+					},
+				}, nil
+			},
+		}
+		handler := &AggregationHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.CountByLevel(w, httptest.NewRequest(http.MethodGet, "/logs/stats/levels?source=api&size=5", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"total": 3, "buckets": [{"key": "ERROR", "count": 2}, {"key": "INFO", "count": 1}]}`, w.Body.String())
+
+		assert.Equal(t, service.And{service.Term{Field: "source", Values: []string{"api"}}}, where)
+		assert.Equal(t, []service.Aggregation{{Name: "buckets", Kind: service.AggregateTerms, Field: "level", Size: 5}}, aggs)
+	})
+
+	t.Run("GIVEN an invalid size WHEN counting THEN return 400", func(t *testing.T) {
+		handler := &AggregationHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.CountBySource(w, httptest.NewRequest(http.MethodGet, "/logs/stats/sources?size=1000", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"parameter":"size"`)
+	})
+}
+
+func TestAggregationHandler_CountBySource(t *testing.T) {
+	var aggs []service.Aggregation
+	mockClient := &MockElasticSearchClient{
+		AggregateFunc: func(ctx context.Context, index string, where service.Condition, a []service.Aggregation) (service.AggregationResult, error) {
+			aggs = a
+			return service.AggregationResult{}, nil
+		},
+	}
+	handler := &AggregationHandler{LogService: mockClient, Index: "logs-index"}
+
+	w := httptest.NewRecorder()
+	handler.CountBySource(w, httptest.NewRequest(http.MethodGet, "/logs/stats/sources", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"total": 0, "buckets": []}`, w.Body.String())
+	assert.Equal(t, []service.Aggregation{{Name: "buckets", Kind: service.AggregateTerms, Field: "source", Size: defaultStatsSize}}, aggs)
+}
+
+func TestAggregationHandler_Histogram(t *testing.T) {
+	t.Run("GIVEN an interval and a range WHEN building the histogram THEN bucket the logs by time", func(t *testing.T) {
+		var where service.Condition
+		var aggs []service.Aggregation
+		mockClient := &MockElasticSearchClient{
+			AggregateFunc: func(ctx context.Context, index string, w service.Condition, a []service.Aggregation) (service.AggregationResult, error) {
+				where, aggs = w, a
+				return service.AggregationResult{
+					Total: 4,
+					Buckets: map[string][]service.Bucket{
+						"buckets": {{Key: "2024-06-10T10:00:00Z", Count: 1}, {Key: "2024-06-10T10:05:00Z", Count: 3}},
+					},
+				}, nil
+			},
+		}
+		handler := &AggregationHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.Histogram(w, httptest.NewRequest(http.MethodGet, "/logs/histogram?interval=5m&from=now-1h&to=now&level=ERROR", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{
+			"total": 4,
+			"interval": "5m",
+			"buckets": [{"key": "2024-06-10T10:00:00Z", "count": 1}, {"key": "2024-06-10T10:05:00Z", "count": 3}]
+		}`, w.Body.String())
+
+		assert.Equal(t, service.And{
+			service.Range{Field: "timestamp", From: "now-1h", To: "now"},
+			service.Term{Field: "level", Values: []string{"ERROR"}},
+		}, where)
+		assert.Equal(t, []service.Aggregation{{Name: "buckets", Kind: service.AggregateDateHistogram, Field: "timestamp", Interval: "5m"}}, aggs)
+	})
+
+	t.Run("GIVEN no range WHEN building the histogram THEN cover the last hour", func(t *testing.T) {
+		var where service.Condition
+		mockClient := &MockElasticSearchClient{
+			AggregateFunc: func(ctx context.Context, index string, w service.Condition, a []service.Aggregation) (service.AggregationResult, error) {
+				where = w
+				return service.AggregationResult{}, nil
+			},
+		}
+		handler := &AggregationHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.Histogram(w, httptest.NewRequest(http.MethodGet, "/logs/histogram", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, service.And{service.Range{Field: "timestamp", From: "now-1h", To: "now"}}, where)
+	})
+
+	t.Run("GIVEN more buckets than allowed WHEN building the histogram THEN return 400", func(t *testing.T) {
+		handler := &AggregationHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		for _, target := range []string{
+			"/logs/histogram?interval=1s&from=now-7d",
+			"/logs/histogram?interval=1ms",
+			"/logs/histogram?interval=1m&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z",
+		} {
+			w := httptest.NewRecorder()
+			handler.Histogram(w, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+			assert.Contains(t, w.Body.String(), `"parameter":"interval"`, target)
+		}
+
+		w := httptest.NewRecorder()
+		handler.Histogram(w, httptest.NewRequest(http.MethodGet, "/logs/histogram?interval=1h&from=now-1y/d&to=now", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("GIVEN an invalid interval WHEN building the histogram THEN return 400", func(t *testing.T) {
+		handler := &AggregationHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		for _, interval := range []string{"1x", "0m", "m", "1M", "281474976710656d", "9223372036854775807ms", "99999999999999999999s"} {
+			w := httptest.NewRecorder()
+			handler.Histogram(w, httptest.NewRequest(http.MethodGet, "/logs/histogram?interval="+interval, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code, interval)
+		}
+	})
+}
@@ -7,8 +7,11 @@ import (
 )
 
 type MockElasticSearchClient struct {
-	IndexedLogs map[string]service.Log
-	SearchFunc  func(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error)
+	IndexedLogs   map[string]service.Log
+	SearchFunc    func(ctx context.Context, index string, query service.Query) (service.SearchResult, error)
+	AggregateFunc func(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error)
+	// ClosedPointInTimes records the IDs passed to ClosePointInTime.
+	ClosedPointInTimes []string
 }
 
 func NewMockElasticSearchClient() *MockElasticSearchClient {
@@ -26,17 +29,41 @@ func (m *MockElasticSearchClient) Index(ctx context.Context, index string, id st
 	return nil
 }
 
-func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error) {
+func (m *MockElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	results := make([]service.BulkItemResult, len(docs))
+	for i, doc := range docs {
+		if logEntry, ok := doc.Body.(service.Log); ok {
+			m.IndexedLogs[doc.ID] = logEntry
+		}
+		results[i] = service.BulkItemResult{ID: doc.ID, Status: 201}
+	}
+	return results, nil
+}
+
+func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
 	if m.SearchFunc != nil {
-		return m.SearchFunc(ctx, index, query, size)
+		return m.SearchFunc(ctx, index, query)
 	}
 
 	logs := make([]service.Log, 0, len(m.IndexedLogs))
 	for _, l := range m.IndexedLogs {
 		logs = append(logs, l)
 	}
-	if len(logs) > size {
-		logs = logs[:size]
+	total := int64(len(logs))
+	if len(logs) > query.Size {
+		logs = logs[:query.Size]
+	}
+	return service.SearchResult{Logs: logs, Total: total}, nil
+}
+
+func (m *MockElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	m.ClosedPointInTimes = append(m.ClosedPointInTimes, id)
+	return nil
+}
+
+func (m *MockElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	if m.AggregateFunc != nil {
+		return m.AggregateFunc(ctx, index, where, aggs)
 	}
-	return logs, nil
+	return service.AggregationResult{}, nil
 }
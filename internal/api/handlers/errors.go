@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	ErrCodeInvalidParameter = "invalid_parameter"
+	ErrCodeCursorExpired    = "cursor_expired"
+	ErrCodeInternal         = "internal_error"
+	ErrCodeInvalidBody      = "invalid_body"
+	ErrCodeBodyTooLarge     = "body_too_large"
+	ErrCodeUnsupportedMedia = "unsupported_media_type"
+)
+
+// APIError is the body of every structured error response, wrapped in an
+// "error" field.
+type APIError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError points at the query parameter that was rejected. Position is
+// the 1-based character of the value where a syntax error was found.
+type FieldError struct {
+	Parameter string `json:"parameter"`
+	Message   string `json:"message"`
+	Position  int    `json:"position,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, apiErr APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]APIError{"error": apiErr})
+}
+
+func writeInvalidParameters(w http.ResponseWriter, details []FieldError) {
+	writeError(w, http.StatusBadRequest, APIError{
+		Code:    ErrCodeInvalidParameter,
+		Message: "invalid query parameters",
+		Details: details,
+	})
+}
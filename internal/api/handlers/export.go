@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	exportFormatNDJSON = "ndjson"
+	exportFormatCSV    = "csv"
+	exportPageSize     = 1000
+)
+
+var exportCSVHeader = []string{"id", "timestamp", "level", "source", "message", "tags", "attributes", "metadata"}
+
+// exportWriter encodes the exported logs one at a time.
+type exportWriter interface {
+	WriteLog(logEntry service.Log) error
+	Flush() error
+}
+
+// GET /logs/export?format=ndjson|csv&from=now-1h&...
+//
+// Streams every log matching the /logs filters, a page at a time, so memory
+// use does not grow with the size of the export. It stops as soon as the
+// client goes away.
+func (h *LogHandler) ExportLogs(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	where, errs := logFilters(params)
+
+	sort, sortErr := sortOrder(params)
+	if sortErr != nil {
+		errs = append(errs, *sortErr)
+	}
+
+	format := params.Get("format")
+	if format == "" {
+		format = exportFormatNDJSON
+	}
+	if format != exportFormatNDJSON && format != exportFormatCSV {
+		errs = append(errs, FieldError{Parameter: "format", Message: fmt.Sprintf("must be ndjson or csv, got %q", format)})
+	}
+
+	if len(errs) > 0 {
+		writeInvalidParameters(w, errs)
+		return
+	}
+
+	query := service.Query{
+		Where:       where,
+		Sort:        sort,
+		Size:        exportPageSize,
+		PointInTime: &service.PointInTime{KeepAlive: pointInTimeKeepAlive},
+	}
+
+	ctx := r.Context()
+	flusher, _ := w.(http.Flusher)
+	var out exportWriter
+	exported := 0
+
+	// However the export ends, finished, failed midway or abandoned by the
+	// client, the last point in time it read from is closed.
+	var pitID string
+	defer func() { closePointInTime(ctx, h.LogService, pitID) }()
+
+	for {
+		result, err := h.LogService.SearchLogs(ctx, h.Index, query)
+		if id := pagePointInTime(query, result); id != "" {
+			pitID = id
+		}
+		if ctx.Err() != nil {
+			log.Printf("Export cancelled by the client after %d logs", exported)
+			return
+		}
+		if err != nil {
+			if out == nil {
+				writeError(w, http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: err.Error()})
+				return
+			}
+			// The status is already sent: cutting the stream short is the
+			// only way left to tell the client the export is incomplete.
+			log.Printf("Error exporting logs after %d logs: %v", exported, err)
+			panic(http.ErrAbortHandler)
+		}
+
+		if out == nil {
+			out, err = startExport(w, format)
+			if err != nil {
+				log.Printf("Error starting export: %v", err)
+				return
+			}
+		}
+
+		for _, logEntry := range result.Logs {
+			if err := out.WriteLog(logEntry); err != nil {
+				log.Printf("Error writing export after %d logs: %v", exported, err)
+				return
+			}
+			exported++
+		}
+		if err := out.Flush(); err != nil {
+			log.Printf("Error writing export after %d logs: %v", exported, err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(result.Logs) < query.Size || len(result.SearchAfter) == 0 {
+			return
+		}
+		query.SearchAfter = result.SearchAfter
+		query.PointInTime = &service.PointInTime{ID: pitID, KeepAlive: pointInTimeKeepAlive}
+	}
+}
+
+func startExport(w http.ResponseWriter, format string) (exportWriter, error) {
+	filename := fmt.Sprintf("logs-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == exportFormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		out := &csvExportWriter{w: csv.NewWriter(w)}
+		return out, out.w.Write(exportCSVHeader)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	return &ndjsonExportWriter{enc: json.NewEncoder(w)}, nil
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportWriter) WriteLog(logEntry service.Log) error {
+	return e.enc.Encode(logEntry)
+}
+
+func (e *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (e *csvExportWriter) WriteLog(logEntry service.Log) error {
+	timestamp := ""
+	if !logEntry.Timestamp.IsZero() {
+		timestamp = logEntry.Timestamp.UTC().Format(time.RFC3339Nano)
+	}
+
+	return e.w.Write([]string{
+		logEntry.ID,
+		timestamp,
+		logEntry.Level,
+		logEntry.Source,
+		logEntry.Message,
+		strings.Join(logEntry.Tags, ";"),
+		jsonColumn(logEntry.Attributes),
+		jsonColumn(logEntry.Metadata),
+	})
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonColumn renders nested fields of a log as JSON inside a CSV cell.
+func jsonColumn[T any](m map[string]T) string {
+	if len(m) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
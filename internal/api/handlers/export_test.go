@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedSearch serves total logs in pages of the requested size, the way
+// search_after walks a point in time.
+func pagedSearch(total int, queries *[]service.Query) func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	return func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+		*queries = append(*queries, query)
+
+		start := 0
+		if len(query.SearchAfter) > 0 {
+			start = query.SearchAfter[0].(int)
+		}
+		end := min(start+query.Size, total)
+
+		result := service.SearchResult{PointInTimeID: "pit-1"}
+		for i := start; i < end; i++ {
+			result.Logs = append(result.Logs, service.Log{
+				ID:        fmt.Sprint(i),
+				Level:     "INFO",
+				Message:   fmt.Sprintf("log %d", i),
+				Timestamp: time.Date(2024, 6, 10, 10, 0, i, 0, time.UTC),
+			})
+		}
+		if end > start {
+			result.SearchAfter = []interface{}{end, fmt.Sprint(end - 1)}
+		}
+		return result, nil
+	}
+}
+
+func TestLogHandler_ExportLogs(t *testing.T) {
+	t.Run("GIVEN more logs than a page WHEN exporting NDJSON THEN stream every page", func(t *testing.T) {
+		var queries []service.Query
+		mockClient := &MockElasticSearchClient{SearchFunc: pagedSearch(exportPageSize+2, &queries)}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ExportLogs(w, httptest.NewRequest(http.MethodGet, "/logs/export?level=INFO", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.True(t, w.Flushed)
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Len(t, lines, exportPageSize+2)
+		var last service.Log
+		assert.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+		assert.Equal(t, fmt.Sprint(exportPageSize+1), last.ID)
+
+		assert.Len(t, queries, 2)
+		assert.Equal(t, "", queries[0].PointInTime.ID)
+		assert.Equal(t, "pit-1", queries[1].PointInTime.ID)
+		assert.Equal(t, []interface{}{exportPageSize, fmt.Sprint(exportPageSize - 1)}, queries[1].SearchAfter)
+		assert.Equal(t, service.And{service.Term{Field: "level", Values: []string{"INFO"}}}, queries[1].Where)
+		assert.Equal(t, []string{"pit-1"}, mockClient.ClosedPointInTimes)
+	})
+
+	t.Run("GIVEN csv format WHEN exporting THEN write a header and a row per log", func(t *testing.T) {
+		var queries []service.Query
+		mockClient := &MockElasticSearchClient{SearchFunc: pagedSearch(2, &queries)}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ExportLogs(w, httptest.NewRequest(http.MethodGet, "/logs/export?format=csv", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+		assert.Equal(t, "id,timestamp,level,source,message,tags,attributes,metadata\n"+
+			"0,2024-06-10T10:00:00Z,INFO,,log 0,,,\n"+
+			"1,2024-06-10T10:00:01Z,INFO,,log 1,,,\n", w.Body.String())
+	})
+
+	t.Run("GIVEN the client disconnects WHEN exporting THEN stop searching", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var queries []service.Query
+		search := pagedSearch(10*exportPageSize, &queries)
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				if len(queries) == 2 {
+					cancel()
+				}
+				return search(ctx, index, query)
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ExportLogs(w, httptest.NewRequest(http.MethodGet, "/logs/export", nil).WithContext(ctx))
+
+		assert.Len(t, queries, 3)
+		assert.Equal(t, 2*exportPageSize, strings.Count(w.Body.String(), "\n"))
+		assert.Equal(t, []string{"pit-1"}, mockClient.ClosedPointInTimes)
+	})
+
+	t.Run("GIVEN a search failing midway WHEN exporting THEN abort the stream and close the point in time", func(t *testing.T) {
+		var queries []service.Query
+		search := pagedSearch(10*exportPageSize, &queries)
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				if len(queries) == 1 {
+					return service.SearchResult{}, errors.New("search failed")
+				}
+				return search(ctx, index, query)
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.ExportLogs(w, httptest.NewRequest(http.MethodGet, "/logs/export", nil))
+		})
+		assert.Equal(t, []string{"pit-1"}, mockClient.ClosedPointInTimes)
+	})
+
+	t.Run("GIVEN an unknown format WHEN exporting THEN return 400", func(t *testing.T) {
+		handler := &LogHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ExportLogs(w, httptest.NewRequest(http.MethodGet, "/logs/export?format=xml", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"parameter":"format"`)
+	})
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/querylang"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// dateMath matches the relative expressions Elasticsearch understands, such
+// as now, now-15m or now-1d/d.
+var dateMath = regexp.MustCompile(`^now([+-]\d+[yMwdhHms])*(/[yMwdhHms])?$`)
+
+// dateMathStep matches one addition or subtraction of a date math expression.
+var dateMathStep = regexp.MustCompile(`([+-])(\d+)([yMwdhHms])`)
+
+// dateMathUnits approximates months and years, which is close enough to size
+// a request.
+var dateMathUnits = map[string]time.Duration{
+	"y": 365 * 24 * time.Hour,
+	"M": 30 * 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"d": 24 * time.Hour,
+	"h": time.Hour,
+	"H": time.Hour,
+	"m": time.Minute,
+	"s": time.Second,
+}
+
+// logFilters turns the /logs filter parameters into the conditions every
+// listed log must satisfy. Every parameter is validated so a bad value is
+// reported on its name instead of surfacing as a search error.
+func logFilters(params url.Values) (service.Condition, []FieldError) {
+	var where service.And
+	var errs []FieldError
+
+	from, fromTime, err := parseTimeBound(params.Get("from"))
+	if err != nil {
+		errs = append(errs, FieldError{Parameter: "from", Message: err.Error()})
+	}
+	to, toTime, err := parseTimeBound(params.Get("to"))
+	if err != nil {
+		errs = append(errs, FieldError{Parameter: "to", Message: err.Error()})
+	}
+	if !fromTime.IsZero() && !toTime.IsZero() && fromTime.After(toTime) {
+		errs = append(errs, FieldError{Parameter: "from", Message: "must not be after to"})
+	}
+	if from != "" || to != "" {
+		where = append(where, service.Range{Field: "timestamp", From: from, To: to})
+	}
+
+	for _, field := range []string{"level", "source"} {
+		values, err := nonEmpty(params[field])
+		if err != nil {
+			errs = append(errs, FieldError{Parameter: field, Message: err.Error()})
+			continue
+		}
+		if len(values) > 0 {
+			where = append(where, service.Term{Field: field, Values: values})
+		}
+	}
+
+	if q := strings.TrimSpace(params.Get("q")); q != "" {
+		condition, err := querylang.ParseCondition(q)
+		var syntaxErr *querylang.SyntaxError
+		switch {
+		case errors.As(err, &syntaxErr):
+			errs = append(errs, FieldError{Parameter: "q", Message: syntaxErr.Msg, Position: syntaxErr.Pos})
+		case err != nil:
+			errs = append(errs, FieldError{Parameter: "q", Message: err.Error()})
+		default:
+			where = append(where, condition)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return where, nil
+}
+
+// parseTimeBound accepts an RFC 3339 timestamp or a date math expression. The
+// parsed time is returned for absolute values so the bounds can be compared.
+func parseTimeBound(raw string) (string, time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", time.Time{}, nil
+	}
+
+	if dateMath.MatchString(raw) {
+		return raw, time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("must be an RFC 3339 timestamp or a relative time such as now-15m, got %q", raw)
+	}
+	return t.UTC().Format(time.RFC3339Nano), t, nil
+}
+
+func nonEmpty(values []string) ([]string, error) {
+	var out []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("must not be empty")
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// resolveTime approximates the time a from or to parameter stands for,
+// ignoring date math rounding.
+func resolveTime(raw string, now time.Time) (time.Time, bool) {
+	raw = strings.TrimSpace(raw)
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t, true
+	}
+	if !dateMath.MatchString(raw) {
+		return time.Time{}, false
+	}
+
+	expr, _, _ := strings.Cut(raw, "/")
+	t := now
+	for _, step := range dateMathStep.FindAllStringSubmatch(expr, -1) {
+		n, err := strconv.Atoi(step[2])
+		if err != nil {
+			return time.Time{}, false
+		}
+		d := time.Duration(n) * dateMathUnits[step[3]]
+		if step[1] == "-" {
+			d = -d
+		}
+		t = t.Add(d)
+	}
+	return t, true
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rodrigogmartins/log-processor/internal/health"
+)
+
+type HealthHandler struct {
+	Checker *health.Checker
+}
+
+// GET /healthz
+// Liveness only tells the process is serving requests: restarting it would
+// not fix an unreachable dependency.
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(health.Report{Status: health.StatusUp, Components: map[string]health.ComponentStatus{}})
+}
+
+// GET /readyz
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.Checker.Check(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != health.StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler_Liveness(t *testing.T) {
+	handler := &HealthHandler{Checker: health.NewChecker(time.Second)}
+	handler.Checker.Register("elasticsearch", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+
+	handler.Liveness(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"up","components":{}}`, w.Body.String())
+}
+
+func TestHealthHandler_Readiness(t *testing.T) {
+	t.Run("GIVEN healthy components WHEN probing THEN return 200 with each status", func(t *testing.T) {
+		handler := &HealthHandler{Checker: health.NewChecker(time.Second)}
+		handler.Checker.Register("kafka", func(ctx context.Context) error { return nil })
+		handler.Checker.Register("elasticsearch", func(ctx context.Context) error { return nil })
+
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		handler.Readiness(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var report health.Report
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+		assert.Equal(t, health.StatusUp, report.Status)
+		assert.Len(t, report.Components, 2)
+	})
+
+	t.Run("GIVEN an unhealthy component WHEN probing THEN return 503 naming it", func(t *testing.T) {
+		handler := &HealthHandler{Checker: health.NewChecker(time.Second)}
+		handler.Checker.Register("kafka", func(ctx context.Context) error { return nil })
+		handler.Checker.Register("processor", func(ctx context.Context) error { return errors.New("processor is stopping") })
+
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		w := httptest.NewRecorder()
+
+		handler.Readiness(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{
+			"status": "down",
+			"components": {
+				"kafka": {"status": "up"},
+				"processor": {"status": "down", "error": "processor is stopping"}
+			}
+		}`, w.Body.String())
+	})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	defaultIngestMaxBytes = 5 << 20
+	ingestRetryAfter      = "5"
+
+	IngestAccepted = "accepted"
+	IngestRejected = "rejected"
+)
+
+// IngestSink takes the logs posted to the API: kafka.Processor indexes them
+// directly, with the retries, breaker pauses and WAL spill of Kafka
+// messages, and kafka.Producer publishes them to the topic. The returned
+// errors are aligned with logEntries.
+type IngestSink interface {
+	ProcessBatch(ctx context.Context, logEntries []service.Log) []error
+}
+
+type IngestHandler struct {
+	Sink     IngestSink
+	MaxBytes int64
+}
+
+// IngestResult is the outcome of one line of the request body.
+type IngestResult struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type IngestResponse struct {
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Results  []IngestResult `json:"results"`
+}
+
+// POST /logs
+//
+// Takes a single JSON log, or one log per line with
+// Content-Type: application/x-ndjson. Lines are accepted or rejected one by
+// one; the answer is 429 when the pipeline is pushing back, so the client
+// can retry the rejected lines later.
+func (h *IngestHandler) Ingest(w http.ResponseWriter, r *http.Request) {
+	mediaType := "application/json"
+	if raw := r.Header.Get("Content-Type"); raw != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(raw); err != nil {
+			mediaType = raw
+		}
+	}
+	if mediaType != "application/json" && mediaType != "application/x-ndjson" {
+		writeError(w, http.StatusUnsupportedMediaType, APIError{
+			Code:    ErrCodeUnsupportedMedia,
+			Message: fmt.Sprintf("content type must be application/json or application/x-ndjson, got %q", mediaType),
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBytes()))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, APIError{
+				Code:    ErrCodeBodyTooLarge,
+				Message: fmt.Sprintf("body is larger than %d bytes", tooLarge.Limit),
+			})
+			return
+		}
+		writeError(w, http.StatusBadRequest, APIError{Code: ErrCodeInvalidBody, Message: err.Error()})
+		return
+	}
+
+	var lines [][]byte
+	if mediaType == "application/x-ndjson" {
+		lines = bytes.Split(body, []byte("\n"))
+	} else {
+		lines = [][]byte{body}
+	}
+
+	response := IngestResponse{Results: []IngestResult{}}
+	logEntries := make([]service.Log, 0, len(lines))
+	positions := make([]int, 0, len(lines))
+
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		result := IngestResult{Line: i + 1, Status: IngestAccepted}
+		var logEntry service.Log
+		if err := json.Unmarshal(line, &logEntry); err != nil {
+			result.Status = IngestRejected
+			result.Error = "invalid JSON: " + err.Error()
+		} else {
+			result.ID = logEntry.ID
+			logEntries = append(logEntries, logEntry)
+			positions = append(positions, len(response.Results))
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if len(response.Results) == 0 {
+		writeError(w, http.StatusBadRequest, APIError{Code: ErrCodeInvalidBody, Message: "body has no logs"})
+		return
+	}
+
+	var errs []error
+	if len(logEntries) > 0 {
+		errs = h.Sink.ProcessBatch(r.Context(), logEntries)
+	}
+
+	invalidOnly, backpressure := true, false
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		result := &response.Results[positions[i]]
+		result.Status = IngestRejected
+		result.Error = err.Error()
+
+		if !errors.Is(err, service.ErrInvalidLog) {
+			invalidOnly = false
+		}
+		if isBackpressure(err) {
+			backpressure = true
+		}
+	}
+
+	for _, result := range response.Results {
+		if result.Status == IngestAccepted {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+	}
+
+	status := http.StatusAccepted
+	switch {
+	case backpressure:
+		w.Header().Set("Retry-After", ingestRetryAfter)
+		status = http.StatusTooManyRequests
+	case response.Accepted == 0 && invalidOnly:
+		status = http.StatusBadRequest
+	case response.Accepted == 0:
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *IngestHandler) maxBytes() int64 {
+	if h.MaxBytes <= 0 {
+		return defaultIngestMaxBytes
+	}
+	return h.MaxBytes
+}
+
+// isBackpressure tells whether a log was refused because the pipeline is
+// overloaded rather than because of the log itself: the Elasticsearch
+// breaker is open, Elasticsearch answered 429 or Kafka reported a
+// temporary failure.
+func isBackpressure(err error) bool {
+	if errors.Is(err, breaker.ErrOpen) {
+		return true
+	}
+
+	var status interface{ HTTPStatus() int }
+	if errors.As(err, &status) {
+		return status.HTTPStatus() == http.StatusTooManyRequests
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type sinkFunc func(ctx context.Context, logEntries []service.Log) []error
+
+func (f sinkFunc) ProcessBatch(ctx context.Context, logEntries []service.Log) []error {
+	return f(ctx, logEntries)
+}
+
+func postLogs(handler *IngestHandler, contentType, body string) (*httptest.ResponseRecorder, IngestResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/logs", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+
+	handler.Ingest(w, req)
+
+	var response IngestResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestIngestHandler_Ingest(t *testing.T) {
+	t.Run("GIVEN a single JSON log WHEN posting THEN index it and return 202", func(t *testing.T) {
+		mockClient := NewMockElasticSearchClient()
+		handler := &IngestHandler{Sink: service.NewLogService(mockClient, "logs-index")}
+
+		w, response := postLogs(handler, "application/json", `{"id":"1","level":"INFO","message":"hello"}`)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, 1, response.Accepted)
+		assert.Equal(t, []IngestResult{{Line: 1, ID: "1", Status: IngestAccepted}}, response.Results)
+		assert.Equal(t, "hello", mockClient.IndexedLogs["1"].Message)
+	})
+
+	t.Run("GIVEN an NDJSON batch WHEN some lines are invalid THEN report acceptance per line", func(t *testing.T) {
+		mockClient := NewMockElasticSearchClient()
+		handler := &IngestHandler{Sink: service.NewLogService(mockClient, "logs-index")}
+		body := `{"id":"1","message":"first"}
+not json
+
+{"id":"","message":"no id"}
+{"id":"5","message":"fifth"}
+`
+
+		w, response := postLogs(handler, "application/x-ndjson; charset=utf-8", body)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, 2, response.Accepted)
+		assert.Equal(t, 2, response.Rejected)
+		assert.Len(t, response.Results, 4)
+		assert.Equal(t, IngestResult{Line: 1, ID: "1", Status: IngestAccepted}, response.Results[0])
+		assert.Equal(t, 2, response.Results[1].Line)
+		assert.Contains(t, response.Results[1].Error, "invalid JSON")
+		assert.Equal(t, IngestResult{Line: 4, Status: IngestRejected, Error: service.ErrInvalidLog.Error()}, response.Results[2])
+		assert.Equal(t, IngestResult{Line: 5, ID: "5", Status: IngestAccepted}, response.Results[3])
+		assert.Len(t, mockClient.IndexedLogs, 2)
+	})
+
+	t.Run("GIVEN only invalid logs WHEN posting THEN return 400", func(t *testing.T) {
+		handler := &IngestHandler{Sink: service.NewLogService(NewMockElasticSearchClient(), "logs-index")}
+
+		w, response := postLogs(handler, "application/x-ndjson", "{\"id\":\"1\"}\n{")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 2, response.Rejected)
+	})
+
+	t.Run("GIVEN the pipeline pushes back WHEN posting THEN return 429 with Retry-After", func(t *testing.T) {
+		handler := &IngestHandler{Sink: sinkFunc(func(ctx context.Context, logEntries []service.Log) []error {
+			return []error{nil, breaker.ErrOpen}
+		})}
+
+		w, response := postLogs(handler, "application/x-ndjson", "{\"id\":\"1\",\"message\":\"a\"}\n{\"id\":\"2\",\"message\":\"b\"}")
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		assert.Equal(t, IngestAccepted, response.Results[0].Status)
+		assert.Equal(t, IngestResult{Line: 2, ID: "2", Status: IngestRejected, Error: breaker.ErrOpen.Error()}, response.Results[1])
+	})
+
+	t.Run("GIVEN Elasticsearch throttles WHEN posting THEN return 429", func(t *testing.T) {
+		handler := &IngestHandler{Sink: sinkFunc(func(ctx context.Context, logEntries []service.Log) []error {
+			return []error{&service.BulkItemError{ID: "1", Status: http.StatusTooManyRequests, Reason: "queue full"}}
+		})}
+
+		w, _ := postLogs(handler, "application/json", `{"id":"1","message":"a"}`)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("GIVEN an unsupported content type WHEN posting THEN return 415", func(t *testing.T) {
+		handler := &IngestHandler{Sink: service.NewLogService(NewMockElasticSearchClient(), "logs-index")}
+
+		w, _ := postLogs(handler, "text/plain", "hello")
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Body.String(), ErrCodeUnsupportedMedia)
+	})
+
+	t.Run("GIVEN a body over the limit WHEN posting THEN return 413", func(t *testing.T) {
+		handler := &IngestHandler{Sink: service.NewLogService(NewMockElasticSearchClient(), "logs-index"), MaxBytes: 10}
+
+		w, _ := postLogs(handler, "application/json", `{"id":"1","message":"too long"}`)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), ErrCodeBodyTooLarge)
+	})
+}
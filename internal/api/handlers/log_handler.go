@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/rodrigogmartins/log-processor/internal/service"
@@ -12,43 +13,32 @@ type LogHandler struct {
 	Index      string
 }
 
-// GET /logs
+// GET /logs?from=now-15m&to=now&level=ERROR&source=api&q=timeout&sort=desc&limit=100&cursor=...
 func (h *LogHandler) ListLogs(w http.ResponseWriter, r *http.Request) {
-	query := map[string]interface{}{
-		"match_all": map[string]interface{}{},
-	}
-	size := 100
-
-	logs, err := h.LogService.SearchLogs(r.Context(), h.Index, query, size)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	json.NewEncoder(w).Encode(logs)
+	where, errs := logFilters(r.URL.Query())
+	h.listPage(w, r, where, errs)
 }
 
-// GET /logs?level=INFO
+// GET /logs/by-level?level=INFO
+//
+// Unlike /logs it answers the first logs as a bare array, without cursors,
+// as it always has.
 func (h *LogHandler) ListLogsByLevel(w http.ResponseWriter, r *http.Request) {
-	level := r.URL.Query().Get("level")
-	if level == "" {
-		h.ListLogs(w, r)
-		return
-	}
-
-	query := map[string]interface{}{
-		"match": map[string]interface{}{
-			"level": level,
-		},
+	query := service.Query{Size: defaultPageSize}
+	if level := r.URL.Query().Get("level"); level != "" {
+		query.Where = service.Match{Field: "level", Text: level}
 	}
-	size := 100
 
-	logs, err := h.LogService.SearchLogs(r.Context(), h.Index, query, size)
+	result, err := h.LogService.SearchLogs(r.Context(), h.Index, query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	logs := result.Logs
+	if logs == nil {
+		logs = []service.Log{}
+	}
 	json.NewEncoder(w).Encode(logs)
 }
 
@@ -60,23 +50,57 @@ func (h *LogHandler) GetLogByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := map[string]interface{}{
-		"term": map[string]interface{}{
-			"id": id,
-		},
+	query := service.Query{
+		Where: service.Term{Field: "id", Values: []string{id}},
+		Size:  1,
 	}
-	size := 1
 
-	logs, err := h.LogService.SearchLogs(r.Context(), h.Index, query, size)
+	result, err := h.LogService.SearchLogs(r.Context(), h.Index, query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if len(logs) == 0 {
+	if len(result.Logs) == 0 {
 		http.Error(w, "log not found", http.StatusNotFound)
 		return
 	}
 
-	json.NewEncoder(w).Encode(logs[0])
+	json.NewEncoder(w).Encode(result.Logs[0])
+}
+
+// listPage searches one page of the logs matching where, unless the filters or the paging
+// parameters were rejected, in which case every error is reported at once.
+func (h *LogHandler) listPage(w http.ResponseWriter, r *http.Request, where service.Condition, filterErrs []FieldError) {
+	query, errs := pageRequest(r.URL.Query())
+	errs = append(filterErrs, errs...)
+	if len(errs) > 0 {
+		writeInvalidParameters(w, errs)
+		return
+	}
+	query.Where = where
+
+	result, err := h.LogService.SearchLogs(r.Context(), h.Index, query)
+	if err != nil {
+		// Elasticsearch answers 404 once the point in time behind the
+		// cursor has expired.
+		var statusErr interface{ HTTPStatus() int }
+		if query.SearchAfter != nil && errors.As(err, &statusErr) && statusErr.HTTPStatus() == http.StatusNotFound {
+			writeError(w, http.StatusGone, APIError{Code: ErrCodeCursorExpired, Message: "cursor expired, restart the listing without it"})
+			return
+		}
+		writeError(w, http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: err.Error()})
+		return
+	}
+
+	page, err := newLogPage(r.URL.Query(), query, result)
+	if page.NextCursor == "" {
+		closePointInTime(r.Context(), h.LogService, pagePointInTime(query, result))
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(page)
 }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -15,11 +16,11 @@ import (
 
 func TestLogHandler_ListLogs(t *testing.T) {
 	mockClient := &MockElasticSearchClient{
-		SearchFunc: func(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error) {
-			return []service.Log{
+		SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+			return service.SearchResult{Logs: []service.Log{
 				{ID: "1", Message: "log1", Timestamp: time.Now()},
 				{ID: "2", Message: "log2", Timestamp: time.Now()},
-			}, nil
+			}, Total: 2}, nil
 		},
 	}
 	handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
@@ -34,21 +35,23 @@ func TestLogHandler_ListLogs(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 
-	var logs []service.Log
-	err := json.NewDecoder(resp.Body).Decode(&logs)
+	var page LogPage
+	err := json.NewDecoder(resp.Body).Decode(&page)
 	assert.NoError(t, err)
-	assert.Len(t, logs, 2)
+	assert.Len(t, page.Items, 2)
+	assert.Equal(t, int64(2), page.Total)
+	assert.Empty(t, page.NextCursor)
 }
 
 func TestLogHandler_ListLogsByLevel(t *testing.T) {
 	mockClient := &MockElasticSearchClient{
-		SearchFunc: func(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error) {
-			if level, ok := query["match"].(map[string]interface{})["level"]; ok && level == "INFO" {
-				return []service.Log{
+		SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+			if query.Where == (service.Match{Field: "level", Text: "INFO"}) {
+				return service.SearchResult{Logs: []service.Log{
 					{ID: "1", Level: "INFO", Message: "log info", Timestamp: time.Now()},
-				}, nil
+				}, Total: 1}, nil
 			}
-			return []service.Log{}, nil
+			return service.SearchResult{}, nil
 		},
 	}
 	handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
@@ -70,15 +73,34 @@ func TestLogHandler_ListLogsByLevel(t *testing.T) {
 	assert.Equal(t, "INFO", logs[0].Level)
 }
 
+func TestLogHandler_ListLogsByLevelWithoutLevel(t *testing.T) {
+	var searched service.Query
+	mockClient := &MockElasticSearchClient{
+		SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+			searched = query
+			return service.SearchResult{}, nil
+		},
+	}
+	handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+	w := httptest.NewRecorder()
+	handler.ListLogsByLevel(w, httptest.NewRequest(http.MethodGet, "/logs/by-level", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+	assert.Nil(t, searched.Where)
+	assert.Nil(t, searched.PointInTime, "the bare array has no cursor to keep a point in time for")
+}
+
 func TestLogHandler_GetLogByID(t *testing.T) {
 	mockClient := &MockElasticSearchClient{
-		SearchFunc: func(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error) {
-			if term, ok := query["term"].(map[string]interface{})["id"]; ok && term == "1" {
-				return []service.Log{
+		SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+			if term, ok := query.Where.(service.Term); ok && term.Field == "id" && term.Values[0] == "1" {
+				return service.SearchResult{Logs: []service.Log{
 					{ID: "1", Message: "log1", Timestamp: time.Now()},
-				}, nil
+				}, Total: 1}, nil
 			}
-			return []service.Log{}, nil
+			return service.SearchResult{}, nil
 		},
 	}
 	handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
@@ -115,8 +137,8 @@ func TestLogHandler_GetLogByID(t *testing.T) {
 
 func TestLogHandler_ErrorFromClient(t *testing.T) {
 	mockClient := &MockElasticSearchClient{
-		SearchFunc: func(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error) {
-			return nil, errors.New("client error")
+		SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+			return service.SearchResult{}, errors.New("client error")
 		},
 	}
 	handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
@@ -129,3 +151,252 @@ func TestLogHandler_ErrorFromClient(t *testing.T) {
 	defer resp.Body.Close()
 	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
 }
+
+func TestLogHandler_ListLogsPagination(t *testing.T) {
+	t.Run("GIVEN a full page WHEN listing THEN return a cursor resuming after its last log", func(t *testing.T) {
+		var requests []service.Query
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				requests = append(requests, query)
+				return service.SearchResult{
+					Logs: []service.Log{
+						{ID: "2", Message: "log2"},
+						{ID: "1", Message: "log1"},
+					},
+					Total:         5,
+					SearchAfter:   []interface{}{json.Number("1718000000001"), "1"},
+					PointInTimeID: "pit-1",
+				}, nil
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?limit=2", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var page LogPage
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+		assert.Len(t, page.Items, 2)
+		assert.Equal(t, int64(5), page.Total)
+		assert.NotEmpty(t, page.NextCursor)
+
+		assert.Equal(t, 2, requests[0].Size)
+		assert.Equal(t, logSort(true), requests[0].Sort)
+		assert.Equal(t, "", requests[0].PointInTime.ID)
+		assert.True(t, requests[0].TrackTotalHits)
+		assert.Nil(t, requests[0].SearchAfter)
+
+		w = httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?limit=2&cursor="+page.NextCursor, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "pit-1", requests[1].PointInTime.ID)
+		assert.Equal(t, []interface{}{json.Number("1718000000001"), "1"}, requests[1].SearchAfter)
+		assert.Empty(t, mockClient.ClosedPointInTimes)
+	})
+
+	t.Run("GIVEN the last page WHEN listing THEN close the point in time", func(t *testing.T) {
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				return service.SearchResult{
+					Logs:          []service.Log{{ID: "1", Message: "log1"}},
+					Total:         1,
+					SearchAfter:   []interface{}{json.Number("1718000000001"), "1"},
+					PointInTimeID: "pit-2",
+				}, nil
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?limit=2", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var page LogPage
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+		assert.Empty(t, page.NextCursor)
+		assert.Equal(t, []string{"pit-2"}, mockClient.ClosedPointInTimes)
+	})
+
+	t.Run("GIVEN a cursor WHEN listing with other filters or sort THEN return 400", func(t *testing.T) {
+		handler := &LogHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		params := url.Values{"level": {"ERROR"}, "sort": {"asc"}}
+		cursor, err := encodeCursor(pageCursor{PointInTimeID: "pit-1", SearchAfter: []interface{}{1, "1"}, Listing: listingKey(params)})
+		assert.NoError(t, err)
+
+		for _, target := range []string{"/logs?level=WARN&sort=asc", "/logs?level=ERROR&sort=desc", "/logs?level=ERROR"} {
+			w := httptest.NewRecorder()
+			handler.ListLogs(w, httptest.NewRequest(http.MethodGet, target+"&cursor="+cursor, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?sort=asc&level=ERROR&limit=5&cursor="+cursor, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("GIVEN invalid parameters WHEN listing THEN return 400", func(t *testing.T) {
+		handler := &LogHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		for _, target := range []string{"/logs?limit=0", "/logs?limit=abc", "/logs?limit=1001", "/logs?cursor=not-a-cursor"} {
+			w := httptest.NewRecorder()
+			handler.ListLogs(w, httptest.NewRequest(http.MethodGet, target, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		}
+	})
+
+	t.Run("GIVEN an expired point in time WHEN listing with its cursor THEN return 410", func(t *testing.T) {
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				return service.SearchResult{}, &statusError{status: http.StatusNotFound}
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		cursor, err := encodeCursor(pageCursor{PointInTimeID: "pit-1", SearchAfter: []interface{}{1, "1"}, Listing: listingKey(url.Values{})})
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?cursor="+cursor, nil))
+		assert.Equal(t, http.StatusGone, w.Code)
+	})
+}
+
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return http.StatusText(e.status)
+}
+
+func (e *statusError) HTTPStatus() int {
+	return e.status
+}
+
+func TestLogHandler_ListLogsFilters(t *testing.T) {
+	t.Run("GIVEN time range, levels, source and text WHEN listing THEN compose them into a bool query", func(t *testing.T) {
+		var got service.Query
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				got = query
+				return service.SearchResult{}, nil
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		target := "/logs?from=now-15m&to=2024-06-10T12:00:00%2B02:00&level=ERROR&level=WARN&source=api&q=connection+timeout&sort=asc"
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, service.And{
+			service.Range{Field: "timestamp", From: "now-15m", To: "2024-06-10T10:00:00Z"},
+			service.Term{Field: "level", Values: []string{"ERROR", "WARN"}},
+			service.Term{Field: "source", Values: []string{"api"}},
+			service.And{
+				service.Match{Field: "message", Text: "connection"},
+				service.Match{Field: "message", Text: "timeout"},
+			},
+		}, got.Where)
+		assert.Equal(t, logSort(false), got.Sort)
+	})
+
+	t.Run("GIVEN no filters WHEN listing THEN match all logs", func(t *testing.T) {
+		var got service.Query
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				got = query
+				return service.SearchResult{}, nil
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, got.Where)
+	})
+
+	t.Run("GIVEN invalid parameters WHEN listing THEN return a structured 400 naming each one", func(t *testing.T) {
+		searched := false
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				searched = true
+				return service.SearchResult{}, nil
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?from=yesterday&level=&sort=up&limit=0", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.False(t, searched)
+
+		var body struct {
+			Error APIError `json:"error"`
+		}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.Equal(t, ErrCodeInvalidParameter, body.Error.Code)
+
+		var params []string
+		for _, d := range body.Error.Details {
+			params = append(params, d.Parameter)
+		}
+		assert.Equal(t, []string{"from", "level", "limit", "sort"}, params)
+	})
+
+	t.Run("GIVEN from after to WHEN listing THEN reject the range", func(t *testing.T) {
+		handler := &LogHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?from=2024-06-11T00:00:00Z&to=2024-06-10T00:00:00Z", nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "must not be after to")
+	})
+}
+
+func TestLogHandler_ListLogsQueryLanguage(t *testing.T) {
+	t.Run("GIVEN a query in q WHEN listing THEN search its compiled conditions", func(t *testing.T) {
+		var got service.Query
+		mockClient := &MockElasticSearchClient{
+			SearchFunc: func(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+				got = query
+				return service.SearchResult{}, nil
+			},
+		}
+		handler := &LogHandler{LogService: mockClient, Index: "logs-index"}
+
+		q := url.QueryEscape(`level:ERROR AND source:checkout AND NOT message:"timeout"`)
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?q="+q, nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, service.And{
+			service.And{
+				service.Term{Field: "level", Values: []string{"ERROR"}},
+				service.Term{Field: "source", Values: []string{"checkout"}},
+				service.Not{Condition: service.Phrase{Field: "message", Text: "timeout"}},
+			},
+		}, got.Where)
+	})
+
+	t.Run("GIVEN a syntax error in q WHEN listing THEN return 400 with its position", func(t *testing.T) {
+		handler := &LogHandler{LogService: NewMockElasticSearchClient(), Index: "logs-index"}
+
+		w := httptest.NewRecorder()
+		handler.ListLogs(w, httptest.NewRequest(http.MethodGet, "/logs?q="+url.QueryEscape("level:ERROR AND"), nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error": {
+			"code": "invalid_parameter",
+			"message": "invalid query parameters",
+			"details": [{"parameter": "q", "message": "unexpected end of query", "position": 16}]
+		}}`, w.Body.String())
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/rodrigogmartins/log-processor/internal/otlp"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPHandler receives OpenTelemetry logs and hands them to the same sink
+// as POST /logs.
+type OTLPHandler struct {
+	Sink     IngestSink
+	MaxBytes int64
+}
+
+// POST /v1/logs
+//
+// OTLP/HTTP receiver accepting protobuf and JSON, optionally gzipped. The
+// response follows the OTLP spec: records the pipeline refuses are reported
+// as a partial success, errors as a google.rpc.Status, both in the encoding
+// of the request.
+func (h *OTLPHandler) Export(w http.ResponseWriter, r *http.Request) {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != otlp.ContentTypeProtobuf && contentType != otlp.ContentTypeJSON) {
+		writeOTLPStatus(w, otlp.ContentTypeProtobuf, http.StatusUnsupportedMediaType, code.Code_INVALID_ARGUMENT,
+			fmt.Sprintf("content type must be %s or %s", otlp.ContentTypeProtobuf, otlp.ContentTypeJSON))
+		return
+	}
+
+	body, err := h.readBody(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeOTLPStatus(w, contentType, http.StatusRequestEntityTooLarge, code.Code_INVALID_ARGUMENT, err.Error())
+			return
+		}
+		writeOTLPStatus(w, contentType, http.StatusBadRequest, code.Code_INVALID_ARGUMENT, err.Error())
+		return
+	}
+
+	var req *collogspb.ExportLogsServiceRequest
+	if contentType == otlp.ContentTypeJSON {
+		req, err = otlp.DecodeJSON(body)
+	} else {
+		req, err = otlp.DecodeProtobuf(body)
+	}
+	if err != nil {
+		writeOTLPStatus(w, contentType, http.StatusBadRequest, code.Code_INVALID_ARGUMENT, "invalid export request: "+err.Error())
+		return
+	}
+
+	logEntries := otlp.Logs(req)
+	var errs []error
+	if len(logEntries) > 0 {
+		errs = h.Sink.ProcessBatch(r.Context(), logEntries)
+	}
+
+	var rejected int64
+	var firstErr error
+	backpressure := false
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		rejected++
+		if firstErr == nil {
+			firstErr = err
+		}
+		if isBackpressure(err) {
+			backpressure = true
+		}
+	}
+
+	// Retrying would be pointless for invalid records, but the whole
+	// request is worth retrying once the pipeline catches up.
+	if backpressure {
+		w.Header().Set("Retry-After", ingestRetryAfter)
+		writeOTLPStatus(w, contentType, http.StatusTooManyRequests, code.Code_RESOURCE_EXHAUSTED, firstErr.Error())
+		return
+	}
+	if rejected > 0 && rejected == int64(len(logEntries)) && !errors.Is(firstErr, service.ErrInvalidLog) {
+		writeOTLPStatus(w, contentType, http.StatusServiceUnavailable, code.Code_UNAVAILABLE, firstErr.Error())
+		return
+	}
+
+	resp := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: rejected,
+			ErrorMessage:       firstErr.Error(),
+		}
+	}
+	writeOTLP(w, contentType, http.StatusOK, resp)
+}
+
+func (h *OTLPHandler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	maxBytes := h.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultIngestMaxBytes
+	}
+
+	var reader io.Reader = http.MaxBytesReader(w, r.Body, maxBytes)
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		// The limit applies to the decompressed size too.
+		reader = io.LimitReader(gz, maxBytes+1)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, &http.MaxBytesError{Limit: maxBytes}
+	}
+	return body, nil
+}
+
+func writeOTLPStatus(w http.ResponseWriter, contentType string, httpStatus int, rpcCode code.Code, message string) {
+	writeOTLP(w, contentType, httpStatus, &status.Status{Code: int32(rpcCode), Message: message})
+}
+
+func writeOTLP(w http.ResponseWriter, contentType string, httpStatus int, msg proto.Message) {
+	data, err := otlp.EncodeResponse(msg, contentType)
+	if err != nil {
+		log.Printf("Error encoding OTLP response: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(httpStatus)
+	w.Write(data)
+}
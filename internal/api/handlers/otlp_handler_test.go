@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func otlpRequest(bodies ...string) []byte {
+	records := make([]*logspb.LogRecord, 0, len(bodies))
+	for _, body := range bodies {
+		records = append(records, &logspb.LogRecord{
+			SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+			Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: body}},
+		})
+	}
+	data, _ := proto.Marshal(&collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{ScopeLogs: []*logspb.ScopeLogs{{LogRecords: records}}}},
+	})
+	return data
+}
+
+func exportOTLP(handler *OTLPHandler, contentType string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	handler.Export(w, req)
+	return w
+}
+
+func TestOTLPHandler_Export(t *testing.T) {
+	t.Run("GIVEN a protobuf export WHEN receiving THEN index every record and answer in protobuf", func(t *testing.T) {
+		mockClient := NewMockElasticSearchClient()
+		handler := &OTLPHandler{Sink: service.NewLogService(mockClient, "logs-index")}
+
+		w := exportOTLP(handler, "application/x-protobuf", otlpRequest("first", "second"), nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+		var resp collogspb.ExportLogsServiceResponse
+		require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Nil(t, resp.PartialSuccess)
+		assert.Len(t, mockClient.IndexedLogs, 2)
+		for _, logEntry := range mockClient.IndexedLogs {
+			assert.Equal(t, "ERROR", logEntry.Level)
+		}
+	})
+
+	t.Run("GIVEN a gzipped JSON export WHEN a record is invalid THEN report a partial success", func(t *testing.T) {
+		mockClient := NewMockElasticSearchClient()
+		handler := &OTLPHandler{Sink: service.NewLogService(mockClient, "logs-index")}
+		var body bytes.Buffer
+		gz := gzip.NewWriter(&body)
+		gz.Write([]byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":{"stringValue":"ok"}},{"severityText":"INFO"}]}]}]}`))
+		gz.Close()
+
+		w := exportOTLP(handler, "application/json", body.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"invalid log: empty ID or message"}}`, w.Body.String())
+		assert.Len(t, mockClient.IndexedLogs, 1)
+	})
+
+	t.Run("GIVEN the pipeline pushes back WHEN receiving THEN return 429 with a status", func(t *testing.T) {
+		handler := &OTLPHandler{Sink: sinkFunc(func(ctx context.Context, logEntries []service.Log) []error {
+			return []error{breaker.ErrOpen}
+		})}
+
+		w := exportOTLP(handler, "application/x-protobuf", otlpRequest("first"), nil)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		var st status.Status
+		require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &st))
+		assert.Equal(t, breaker.ErrOpen.Error(), st.Message)
+	})
+
+	t.Run("GIVEN a malformed body WHEN receiving THEN return 400", func(t *testing.T) {
+		handler := &OTLPHandler{Sink: service.NewLogService(NewMockElasticSearchClient(), "logs-index")}
+
+		w := exportOTLP(handler, "application/json", []byte(`{"resourceLogs":`), nil)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid export request")
+	})
+
+	t.Run("GIVEN an unsupported content type WHEN receiving THEN return 415", func(t *testing.T) {
+		handler := &OTLPHandler{Sink: service.NewLogService(NewMockElasticSearchClient(), "logs-index")}
+
+		w := exportOTLP(handler, "text/plain", []byte("hello"), nil)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
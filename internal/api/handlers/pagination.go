@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	defaultPageSize      = 100
+	maxPageSize          = 1000
+	pointInTimeKeepAlive = "1m"
+	closeTimeout         = 5 * time.Second
+)
+
+// LogPage is the envelope of the paginated log listings. NextCursor is empty
+// on the last page.
+type LogPage struct {
+	Items      []service.Log `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+	Total      int64         `json:"total"`
+}
+
+// logSort orders the pages by timestamp. The ID breaks ties between logs
+// sharing a timestamp so search_after never skips or repeats one.
+func logSort(desc bool) []service.SortField {
+	return []service.SortField{
+		{Field: "timestamp", Desc: desc},
+		{Field: "id", Desc: desc},
+	}
+}
+
+// pageCursor is what next_cursor encodes: the point in time the listing is
+// read from, the sort values of the last log returned and the key of the
+// filters and sort order the listing was started with.
+type pageCursor struct {
+	PointInTimeID string        `json:"pit"`
+	SearchAfter   []interface{} `json:"after"`
+	Listing       string        `json:"listing"`
+}
+
+var (
+	errInvalidCursor = errors.New("is not a cursor returned by a previous page")
+	errCursorListing = errors.New("was returned for other filters or another sort order")
+)
+
+// listingKey identifies the filters and sort order of a listing: every query
+// parameter but the cursor and the limit, which may change between pages.
+func listingKey(params url.Values) string {
+	listing := make(url.Values, len(params))
+	for key, values := range params {
+		if key != "cursor" && key != "limit" {
+			listing[key] = values
+		}
+	}
+	sum := sha256.Sum256([]byte(listing.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodeCursor(c pageCursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || len(c.SearchAfter) != 2 {
+		return c, errInvalidCursor
+	}
+	return c, nil
+}
+
+// pageRequest builds the search for the page selected by the limit, sort and
+// cursor query parameters.
+func pageRequest(params url.Values) (service.Query, []FieldError) {
+	var errs []FieldError
+	query := service.Query{
+		Size:           defaultPageSize,
+		PointInTime:    &service.PointInTime{KeepAlive: pointInTimeKeepAlive},
+		TrackTotalHits: true,
+	}
+
+	if raw := params.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			errs = append(errs, FieldError{Parameter: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxPageSize)})
+		}
+		query.Size = limit
+	}
+
+	sort, err := sortOrder(params)
+	if err != nil {
+		errs = append(errs, *err)
+	}
+	query.Sort = sort
+
+	if raw := params.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err == nil && cursor.Listing != listingKey(params) {
+			err = errCursorListing
+		}
+		if err != nil {
+			errs = append(errs, FieldError{Parameter: "cursor", Message: err.Error()})
+		}
+		query.PointInTime.ID = cursor.PointInTimeID
+		query.SearchAfter = cursor.SearchAfter
+	}
+
+	return query, errs
+}
+
+// sortOrder reads the sort query parameter, newest first by default.
+func sortOrder(params url.Values) ([]service.SortField, *FieldError) {
+	switch raw := params.Get("sort"); raw {
+	case "", "desc":
+		return logSort(true), nil
+	case "asc":
+		return logSort(false), nil
+	default:
+		return logSort(true), &FieldError{Parameter: "sort", Message: fmt.Sprintf("must be asc or desc, got %q", raw)}
+	}
+}
+
+// newLogPage wraps the result of the search built by pageRequest from
+// params. The cursor is only emitted when a full page came back.
+func newLogPage(params url.Values, query service.Query, result service.SearchResult) (LogPage, error) {
+	page := LogPage{Items: result.Logs, Total: result.Total}
+	if page.Items == nil {
+		page.Items = []service.Log{}
+	}
+
+	if len(result.Logs) < query.Size || len(result.SearchAfter) == 0 {
+		return page, nil
+	}
+
+	next, err := encodeCursor(pageCursor{
+		PointInTimeID: pagePointInTime(query, result),
+		SearchAfter:   result.SearchAfter,
+		Listing:       listingKey(params),
+	})
+	if err != nil {
+		return page, err
+	}
+	page.NextCursor = next
+	return page, nil
+}
+
+// pagePointInTime is the point in time the next page reads from.
+// Elasticsearch may return a new ID with every page.
+func pagePointInTime(query service.Query, result service.SearchResult) string {
+	if result.PointInTimeID != "" {
+		return result.PointInTimeID
+	}
+	if query.PointInTime != nil {
+		return query.PointInTime.ID
+	}
+	return ""
+}
+
+// closePointInTime releases the point in time of a listing that will not be
+// read any further, instead of holding it open until its keep alive runs
+// out. It runs even when the client has gone away.
+func closePointInTime(ctx context.Context, client service.ElasticSearchClient, id string) {
+	if id == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), closeTimeout)
+	defer cancel()
+	if err := client.ClosePointInTime(ctx, id); err != nil {
+		log.Printf("Error closing point in time: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/wal"
+)
+
+type StatusHandler struct {
+	Breaker *breaker.Breaker
+	WAL     *wal.WAL
+}
+
+// GET /status/breaker
+func (h *StatusHandler) GetBreaker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Breaker.Status())
+}
+
+// GET /status/wal
+func (h *StatusHandler) GetWAL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.WAL.Stats())
+}
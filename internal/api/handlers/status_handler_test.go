@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/rodrigogmartins/log-processor/internal/wal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusHandler_GetBreaker(t *testing.T) {
+	esBreaker := breaker.New("elasticsearch", 1, time.Hour, nil)
+	esBreaker.Record(errors.New("connection refused"))
+	handler := &StatusHandler{Breaker: esBreaker}
+
+	req := httptest.NewRequest(http.MethodGet, "/status/breaker", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetBreaker(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]interface{}
+	err := json.NewDecoder(resp.Body).Decode(&body)
+	assert.NoError(t, err)
+	assert.Equal(t, "open", body["state"])
+	assert.Equal(t, "connection refused", body["last_error"])
+}
+
+func TestStatusHandler_GetWAL(t *testing.T) {
+	w, err := wal.Open(t.TempDir(), 0, 1<<20)
+	assert.NoError(t, err)
+	defer w.Close()
+	assert.NoError(t, w.Append(service.Log{ID: "1", Message: "log1"}))
+	handler := &StatusHandler{WAL: w}
+
+	req := httptest.NewRequest(http.MethodGet, "/status/wal", nil)
+	rec := httptest.NewRecorder()
+
+	handler.GetWAL(rec, req)
+
+	resp := rec.Result()
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var stats wal.Stats
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.AppendedRecords)
+	assert.Greater(t, stats.PendingBytes, int64(0))
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rodrigogmartins/log-processor/internal/tail"
+)
+
+const (
+	defaultTailHeartbeat = 15 * time.Second
+	tailWriteTimeout     = 10 * time.Second
+)
+
+type TailHandler struct {
+	Hub       *tail.Hub
+	Heartbeat time.Duration
+}
+
+// TailEvent is a WebSocket message: Data holds a log for "log" events and a
+// TailHeartbeat for "heartbeat" events. Server-Sent Events carry the same
+// name and data.
+type TailEvent struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// TailHeartbeat keeps idle streams open and tells the subscriber how many
+// logs it missed because it was reading too slowly.
+type TailHeartbeat struct {
+	Time    time.Time `json:"time"`
+	Dropped uint64    `json:"dropped"`
+}
+
+var tailUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// GET /logs/tail?level=ERROR&source=payments
+//
+// Streams the logs as they are indexed, over WebSocket when the request asks
+// for an upgrade and as Server-Sent Events otherwise. level and source can be
+// repeated.
+func (h *TailHandler) Tail(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := tail.Filter{Levels: params["level"], Sources: params["source"]}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.tailWebSocket(w, r, filter)
+		return
+	}
+	h.tailEvents(w, r, filter)
+}
+
+func (h *TailHandler) tailEvents(w http.ResponseWriter, r *http.Request, filter tail.Filter) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, APIError{Code: ErrCodeInternal, Message: "streaming is not supported"})
+		return
+	}
+
+	sub := h.Hub.Subscribe(filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(h.heartbeat())
+	defer ticker.Stop()
+
+	for {
+		var event TailEvent
+		select {
+		case <-r.Context().Done():
+			return
+		case logEntry, ok := <-sub.Logs():
+			if !ok {
+				return
+			}
+			event = TailEvent{Event: "log", Data: logEntry}
+		case now := <-ticker.C:
+			event = TailEvent{Event: "heartbeat", Data: TailHeartbeat{Time: now.UTC(), Dropped: sub.Dropped()}}
+		}
+
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			log.Printf("Error encoding tail event: %v", err)
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (h *TailHandler) tailWebSocket(w http.ResponseWriter, r *http.Request, filter tail.Filter) {
+	conn, err := tailUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the request.
+		return
+	}
+	defer conn.Close()
+
+	sub := h.Hub.Subscribe(filter)
+	defer sub.Close()
+
+	// Reading is what processes the client's close and ping frames; the
+	// client is not expected to send anything else.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(h.heartbeat())
+	defer ticker.Stop()
+
+	for {
+		var event TailEvent
+		select {
+		case <-gone:
+			return
+		case logEntry, ok := <-sub.Logs():
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+				conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(tailWriteTimeout))
+				return
+			}
+			event = TailEvent{Event: "log", Data: logEntry}
+		case now := <-ticker.C:
+			event = TailEvent{Event: "heartbeat", Data: TailHeartbeat{Time: now.UTC(), Dropped: sub.Dropped()}}
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(tailWriteTimeout))
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+}
+
+func (h *TailHandler) heartbeat() time.Duration {
+	if h.Heartbeat <= 0 {
+		return defaultTailHeartbeat
+	}
+	return h.Heartbeat
+}
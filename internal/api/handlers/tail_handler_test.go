@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/rodrigogmartins/log-processor/internal/tail"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForSubscribers(t *testing.T, hub *tail.Hub, n int) {
+	require.Eventually(t, func() bool { return hub.Subscribers() == n }, time.Second, 5*time.Millisecond)
+}
+
+// readEvent reads the next Server-Sent Event and returns its name and data.
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestTailHandler_ServerSentEvents(t *testing.T) {
+	t.Run("GIVEN a filtered subscriber WHEN logs are published THEN stream the matching ones", func(t *testing.T) {
+		hub := tail.NewHub(10)
+		server := httptest.NewServer(http.HandlerFunc((&TailHandler{Hub: hub, Heartbeat: time.Hour}).Tail))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/logs/tail?level=ERROR&source=payments")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		waitForSubscribers(t, hub, 1)
+		hub.Publish(service.Log{ID: "1", Level: "INFO", Source: "payments", Message: "skipped"})
+		hub.Publish(service.Log{ID: "2", Level: "ERROR", Source: "payments", Message: "card declined"})
+
+		name, data := readEvent(t, bufio.NewReader(resp.Body))
+		assert.Equal(t, "log", name)
+		var logEntry service.Log
+		require.NoError(t, json.Unmarshal([]byte(data), &logEntry))
+		assert.Equal(t, "2", logEntry.ID)
+	})
+
+	t.Run("GIVEN an idle stream WHEN the interval passes THEN send a heartbeat", func(t *testing.T) {
+		hub := tail.NewHub(10)
+		server := httptest.NewServer(http.HandlerFunc((&TailHandler{Hub: hub, Heartbeat: 10 * time.Millisecond}).Tail))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/logs/tail")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		name, data := readEvent(t, bufio.NewReader(resp.Body))
+		assert.Equal(t, "heartbeat", name)
+		assert.Contains(t, data, `"dropped":0`)
+	})
+
+	t.Run("GIVEN open streams WHEN the client leaves or the hub closes THEN tear the subscription down", func(t *testing.T) {
+		hub := tail.NewHub(10)
+		server := httptest.NewServer(http.HandlerFunc((&TailHandler{Hub: hub, Heartbeat: time.Hour}).Tail))
+		defer server.Close()
+
+		leaving, err := http.Get(server.URL + "/logs/tail")
+		require.NoError(t, err)
+		staying, err := http.Get(server.URL + "/logs/tail")
+		require.NoError(t, err)
+		defer staying.Body.Close()
+		waitForSubscribers(t, hub, 2)
+
+		leaving.Body.Close()
+		waitForSubscribers(t, hub, 1)
+
+		hub.Close()
+		_, err = bufio.NewReader(staying.Body).ReadString('\n')
+		assert.Error(t, err, "must end the stream")
+		waitForSubscribers(t, hub, 0)
+	})
+}
+
+func TestTailHandler_WebSocket(t *testing.T) {
+	hub := tail.NewHub(10)
+	server := httptest.NewServer(http.HandlerFunc((&TailHandler{Hub: hub, Heartbeat: time.Hour}).Tail))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/logs/tail?level=error", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitForSubscribers(t, hub, 1)
+
+	t.Run("GIVEN a WebSocket subscriber WHEN a log is published THEN send it as a log event", func(t *testing.T) {
+		hub.Publish(service.Log{ID: "1", Level: "ERROR", Message: "boom"})
+
+		var event struct {
+			Event string      `json:"event"`
+			Data  service.Log `json:"data"`
+		}
+		require.NoError(t, conn.ReadJSON(&event))
+		assert.Equal(t, "log", event.Event)
+		assert.Equal(t, "1", event.Data.ID)
+	})
+
+	t.Run("GIVEN a WebSocket subscriber WHEN the hub closes THEN close with going away", func(t *testing.T) {
+		hub.Close()
+
+		_, _, err := conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+		waitForSubscribers(t, hub, 0)
+	})
+}
@@ -1,21 +1,85 @@
 package api
 
 import (
+	"time"
+
 	"github.com/gorilla/mux"
 	"github.com/rodrigogmartins/log-processor/internal/api/handlers"
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/health"
+	"github.com/rodrigogmartins/log-processor/internal/metrics"
 	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/rodrigogmartins/log-processor/internal/tail"
+	"github.com/rodrigogmartins/log-processor/internal/wal"
 )
 
-func NewRouter(logService service.ElasticSearchClient, index string) *mux.Router {
+// RouterConfig holds what the API needs. Optional components left nil do
+// not get their routes registered.
+type RouterConfig struct {
+	ESClient service.ElasticSearchClient
+	Index    string
+	Breaker  *breaker.Breaker
+	WAL      *wal.WAL
+	Health   *health.Checker
+
+	Tail          *tail.Hub
+	TailHeartbeat time.Duration
+
+	Ingest         handlers.IngestSink
+	OTLP           handlers.IngestSink
+	IngestMaxBytes int64
+}
+
+func NewRouter(cfg RouterConfig) *mux.Router {
 	handler := &handlers.LogHandler{
-		LogService: logService,
-		Index:      index,
+		LogService: cfg.ESClient,
+		Index:      cfg.Index,
+	}
+
+	aggregationHandler := &handlers.AggregationHandler{
+		LogService: cfg.ESClient,
+		Index:      cfg.Index,
 	}
 
 	r := mux.NewRouter()
+	r.Use(metrics.Middleware)
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	checker := cfg.Health
+	if checker == nil {
+		checker = health.NewChecker(0)
+	}
+	healthHandler := &handlers.HealthHandler{Checker: checker}
+	r.HandleFunc("/healthz", healthHandler.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readiness).Methods("GET")
+
 	r.HandleFunc("/logs", handler.ListLogs).Methods("GET")
+	if cfg.Ingest != nil {
+		ingestHandler := &handlers.IngestHandler{Sink: cfg.Ingest, MaxBytes: cfg.IngestMaxBytes}
+		r.HandleFunc("/logs", ingestHandler.Ingest).Methods("POST")
+	}
+	if cfg.OTLP != nil {
+		otlpHandler := &handlers.OTLPHandler{Sink: cfg.OTLP, MaxBytes: cfg.IngestMaxBytes}
+		r.HandleFunc("/v1/logs", otlpHandler.Export).Methods("POST")
+	}
 	r.HandleFunc("/logs/by-level", handler.ListLogsByLevel).Methods("GET")
+	r.HandleFunc("/logs/export", handler.ExportLogs).Methods("GET")
+	if cfg.Tail != nil {
+		tailHandler := &handlers.TailHandler{Hub: cfg.Tail, Heartbeat: cfg.TailHeartbeat}
+		r.HandleFunc("/logs/tail", tailHandler.Tail).Methods("GET")
+	}
+	r.HandleFunc("/logs/stats/levels", aggregationHandler.CountByLevel).Methods("GET")
+	r.HandleFunc("/logs/stats/sources", aggregationHandler.CountBySource).Methods("GET")
+	r.HandleFunc("/logs/histogram", aggregationHandler.Histogram).Methods("GET")
 	r.HandleFunc("/logs/{id}", handler.GetLogByID).Methods("GET")
 
+	statusHandler := &handlers.StatusHandler{Breaker: cfg.Breaker, WAL: cfg.WAL}
+	if cfg.Breaker != nil {
+		r.HandleFunc("/status/breaker", statusHandler.GetBreaker).Methods("GET")
+	}
+	if cfg.WAL != nil {
+		r.HandleFunc("/status/wal", statusHandler.GetWAL).Methods("GET")
+	}
+
 	return r
 }
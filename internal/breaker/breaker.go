@@ -0,0 +1,221 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/retry"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type Status struct {
+	Name                string     `json:"name"`
+	State               State      `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// Breaker opens after FailureThreshold consecutive failures and rejects calls
+// with ErrOpen. While open, Run probes the dependency every ProbeInterval;
+// a successful probe moves it to half-open, where calls go through again and
+// the first result decides between closing and re-opening.
+type Breaker struct {
+	Name             string
+	FailureThreshold int
+	ProbeInterval    time.Duration
+	Probe            func(ctx context.Context) error
+	// IsFailure tells which errors count against the dependency. Defaults to
+	// retry.Transient, so rejected requests do not open the breaker.
+	IsFailure func(error) bool
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	lastErr  error
+	changed  chan struct{}
+}
+
+func New(name string, failureThreshold int, probeInterval time.Duration, probe func(ctx context.Context) error) *Breaker {
+	return &Breaker{
+		Name:             name,
+		FailureThreshold: failureThreshold,
+		ProbeInterval:    probeInterval,
+		Probe:            probe,
+		changed:          make(chan struct{}),
+	}
+}
+
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open {
+		return ErrOpen
+	}
+	return nil
+}
+
+// Record reports the outcome of a call made after Allow.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !b.isFailure(err) {
+		// Only a success ends a run of failures. Other errors, like a
+		// rejected document or a cancelled request, say nothing about the
+		// health of the dependency, though getting one is enough to close
+		// a half-open breaker.
+		if err == nil || b.state == HalfOpen {
+			b.failures = 0
+		}
+		if b.state == HalfOpen {
+			b.setState(Closed)
+		}
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == HalfOpen || b.failures >= max(b.FailureThreshold, 1) {
+		if b.state != Open {
+			b.openedAt = time.Now()
+		}
+		b.setState(Open)
+	}
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := Status{
+		Name:                b.Name,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != Closed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if b.lastErr != nil {
+		status.LastError = b.lastErr.Error()
+	}
+	return status
+}
+
+// Wait blocks while the breaker is open.
+func (b *Breaker) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		if b.state != Open {
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changedChan()
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Run probes the dependency while the breaker is open, until ctx is done.
+func (b *Breaker) Run(ctx context.Context) {
+	if b.Probe == nil || b.ProbeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(b.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if b.State() != Open {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, b.ProbeInterval)
+		err := b.Probe(probeCtx)
+		cancel()
+
+		b.mu.Lock()
+		if err != nil {
+			b.lastErr = err
+			log.Printf("Circuit breaker %s probe failed: %v", b.Name, err)
+		} else if b.state == Open {
+			b.setState(HalfOpen)
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *Breaker) isFailure(err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	return retry.Transient(err)
+}
+
+// setState must be called with mu held.
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+
+	log.Printf("Circuit breaker %s: %s -> %s", b.Name, b.state, state)
+	b.state = state
+	close(b.changedChan())
+	b.changed = make(chan struct{})
+}
+
+func (b *Breaker) changedChan() chan struct{} {
+	if b.changed == nil {
+		b.changed = make(chan struct{})
+	}
+	return b.changed
+}
@@ -0,0 +1,113 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError int
+
+func (e statusError) Error() string   { return "status error" }
+func (e statusError) HTTPStatus() int { return int(e) }
+
+func TestBreaker_Transitions(t *testing.T) {
+	b := New("elasticsearch", 2, time.Hour, nil)
+
+	b.Record(errors.New("connection refused"))
+	assert.Equal(t, Closed, b.State())
+
+	b.Record(statusError(400))
+	assert.Equal(t, Closed, b.State(), "rejected requests must not count as failures")
+	assert.Equal(t, 1, b.Status().ConsecutiveFailures, "nor end a run of failures")
+
+	b.Record(nil)
+	assert.Equal(t, 0, b.Status().ConsecutiveFailures)
+
+	b.Record(errors.New("connection refused"))
+	b.Record(statusError(503))
+	assert.Equal(t, Open, b.State())
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+
+	status := b.Status()
+	assert.NotNil(t, status.OpenedAt)
+	assert.Equal(t, "status error", status.LastError)
+}
+
+func TestBreaker_Run(t *testing.T) {
+	var healthy atomic.Bool
+	b := New("elasticsearch", 1, 5*time.Millisecond, func(ctx context.Context) error {
+		if healthy.Load() {
+			return nil
+		}
+		return errors.New("still down")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	b.Record(errors.New("down"))
+	assert.Equal(t, Open, b.State())
+
+	waited := make(chan error)
+	go func() {
+		waited <- b.Wait(ctx)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait must block while the breaker is open")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	healthy.Store(true)
+	select {
+	case err := <-waited:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout: Wait did not return after a successful probe")
+	}
+
+	assert.Equal(t, HalfOpen, b.State())
+	assert.NoError(t, b.Allow())
+
+	b.Record(nil)
+	assert.Equal(t, Closed, b.State())
+}
+
+func TestBreaker_HalfOpenFailure(t *testing.T) {
+	b := New("elasticsearch", 3, time.Hour, nil)
+	b.mu.Lock()
+	b.setState(HalfOpen)
+	b.mu.Unlock()
+
+	b.Record(errors.New("down"))
+
+	assert.Equal(t, Open, b.State(), "a failure while half-open must re-open")
+}
+
+func TestBreaker_NonFailureErrors(t *testing.T) {
+	b := New("elasticsearch", 2, time.Hour, nil)
+
+	b.Record(errors.New("connection refused"))
+	b.Record(context.Canceled)
+	b.Record(statusError(400))
+	b.Record(errors.New("connection refused"))
+
+	assert.Equal(t, Open, b.State(), "errors that are not failures must not reset the count")
+}
+
+func TestBreaker_WaitCancelled(t *testing.T) {
+	b := New("elasticsearch", 1, time.Hour, nil)
+	b.Record(errors.New("down"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, b.Wait(ctx), context.DeadlineExceeded)
+}
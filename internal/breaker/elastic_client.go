@@ -0,0 +1,83 @@
+package breaker
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// ElasticSearchClient guards every call to the wrapped client with a Breaker.
+type ElasticSearchClient struct {
+	Next    service.ElasticSearchClient
+	Breaker *Breaker
+}
+
+func NewElasticSearchClient(next service.ElasticSearchClient, breaker *Breaker) *ElasticSearchClient {
+	return &ElasticSearchClient{
+		Next:    next,
+		Breaker: breaker,
+	}
+}
+
+func (c *ElasticSearchClient) Index(ctx context.Context, index string, id string, body interface{}) error {
+	if err := c.Breaker.Allow(); err != nil {
+		return err
+	}
+
+	err := c.Next.Index(ctx, index, id, body)
+	c.Breaker.Record(err)
+	return err
+}
+
+func (c *ElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	if err := c.Breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	results, err := c.Next.BulkIndex(ctx, index, docs)
+	if err == nil {
+		// Items rejected because the cluster is overloaded count as a
+		// failure even though the request itself succeeded.
+		for _, result := range results {
+			if result.Status == http.StatusTooManyRequests || result.Status >= 500 {
+				err := &service.BulkItemError{ID: result.ID, Status: result.Status, Reason: result.Error}
+				c.Breaker.Record(err)
+				return results, nil
+			}
+		}
+	}
+
+	c.Breaker.Record(err)
+	return results, err
+}
+
+func (c *ElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	if err := c.Breaker.Allow(); err != nil {
+		return service.SearchResult{}, err
+	}
+
+	result, err := c.Next.SearchLogs(ctx, index, query)
+	c.Breaker.Record(err)
+	return result, err
+}
+
+func (c *ElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	if err := c.Breaker.Allow(); err != nil {
+		return service.AggregationResult{}, err
+	}
+
+	result, err := c.Next.AggregateLogs(ctx, index, where, aggs)
+	c.Breaker.Record(err)
+	return result, err
+}
+
+func (c *ElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	if err := c.Breaker.Allow(); err != nil {
+		return err
+	}
+
+	err := c.Next.ClosePointInTime(ctx, id)
+	c.Breaker.Record(err)
+	return err
+}
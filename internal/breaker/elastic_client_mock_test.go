@@ -0,0 +1,38 @@
+package breaker
+
+import (
+	"context"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+type MockElasticSearchClient struct {
+	Err         error
+	BulkResults []service.BulkItemResult
+	Calls       int
+}
+
+func (m *MockElasticSearchClient) Index(ctx context.Context, index string, id string, body interface{}) error {
+	m.Calls++
+	return m.Err
+}
+
+func (m *MockElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	m.Calls++
+	return m.BulkResults, m.Err
+}
+
+func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	m.Calls++
+	return service.SearchResult{}, m.Err
+}
+
+func (m *MockElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	m.Calls++
+	return m.Err
+}
+
+func (m *MockElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	m.Calls++
+	return service.AggregationResult{}, m.Err
+}
@@ -0,0 +1,39 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElasticSearchClient(t *testing.T) {
+	t.Run("GIVEN repeated failures WHEN calling THEN open and stop calling the client", func(t *testing.T) {
+		mockES := &MockElasticSearchClient{Err: errors.New("connection refused")}
+		client := NewElasticSearchClient(mockES, New("elasticsearch", 2, time.Hour, nil))
+
+		_ = client.Index(context.Background(), "logs-index", "1", nil)
+		_ = client.Index(context.Background(), "logs-index", "1", nil)
+		err := client.Index(context.Background(), "logs-index", "1", nil)
+
+		assert.ErrorIs(t, err, ErrOpen)
+		assert.Equal(t, 2, mockES.Calls)
+	})
+
+	t.Run("GIVEN bulk items throttled WHEN bulk indexing THEN count as failure", func(t *testing.T) {
+		mockES := &MockElasticSearchClient{BulkResults: []service.BulkItemResult{
+			{ID: "1", Status: 201},
+			{ID: "2", Status: 429, Error: "es_rejected_execution_exception: queue full"},
+		}}
+		client := NewElasticSearchClient(mockES, New("elasticsearch", 1, time.Hour, nil))
+
+		results, err := client.BulkIndex(context.Background(), "logs-index", []service.BulkDocument{{ID: "1"}, {ID: "2"}})
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, Open, client.Breaker.State())
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,17 +13,74 @@ type Config struct {
 	KafkaBrokers         []string
 	KafkaTopic           string
 	KafkaGroupID         string
+	KafkaDeadLetterTopic string
+	KafkaOrderByKey      bool
+	KafkaHeaderAllowlist []string
+	KafkaHeaderRenames   map[string]string
+	KafkaSourceHeader    string
 	MaxWorkers           int
 	MaxConsumeRetries    int
 	BackOffRetries       time.Duration
+	RetryMaxInterval     time.Duration
+	RetryMaxElapsedTime  time.Duration
 	WorkerTimeoutSeconds int
 
+	// Parsing
+	LogFormatDefault string
+	LogFormatByTopic map[string]string
+
+	// Multiline stitching, disabled when neither pattern is set
+	MultilineStartPattern        string
+	MultilineContinuationPattern string
+	MultilineMaxLines            int
+	MultilineMaxBytes            int
+	MultilineFlushTimeout        time.Duration
+
 	// Elasticsearch
-	ElasticHost  string
-	ElasticIndex string
+	ElasticHost            string
+	ElasticIndex           string
+	ElasticBulkMaxDocs     int
+	ElasticBulkMaxBytes    int
+	ElasticBulkMaxLatency  time.Duration
+	ElasticBreakerFailures int
+	ElasticBreakerProbe    time.Duration
+
+	// Write-ahead buffer, disabled when WALDir is empty
+	WALDir             string
+	WALMaxSegmentBytes int64
+	WALMaxBytes        int64
+	WALReplayInterval  time.Duration
 
 	// API
-	APIPort string
+	APIPort            string
+	HealthCheckTimeout time.Duration
+
+	// HTTP ingestion, "kafka" publishes to KafkaTopic and "direct" indexes
+	IngestMode     string
+	IngestMaxBytes int64
+
+	// Syslog input, each transport disabled when its address is empty
+	SyslogUDPAddr         string
+	SyslogTCPAddr         string
+	SyslogMaxMessageBytes int
+
+	// Fluent Forward input, disabled when the address is empty
+	FluentForwardAddr string
+
+	// File tailing, disabled when no pattern is set
+	FileTailPatterns     []string
+	FileTailCheckpoint   string
+	FileTailPollInterval time.Duration
+	FileTailFormat       string
+
+	// Container logs (Docker json-file and CRI), disabled when no pattern
+	// is set. Polled every FileTailPollInterval.
+	ContainerLogPatterns   []string
+	ContainerLogCheckpoint string
+
+	// Live tail
+	TailBufferSize        int
+	TailHeartbeatInterval time.Duration
 
 	// Other
 	ShutdownTimeout time.Duration
@@ -44,11 +102,129 @@ func LoadConfig() *Config {
 		backOffRetriesMs = 300
 	}
 
+	retryMaxInterval, err := time.ParseDuration(os.Getenv("RETRY_MAX_INTERVAL"))
+	if err != nil {
+		retryMaxInterval = 30 * time.Second
+	}
+
+	retryMaxElapsedTime, err := time.ParseDuration(os.Getenv("RETRY_MAX_ELAPSED_TIME"))
+	if err != nil {
+		retryMaxElapsedTime = 2 * time.Minute
+	}
+
 	workerTimeout, err := strconv.Atoi(os.Getenv("WORKER_TIMEOUT_SECONDS"))
 	if err != nil {
 		workerTimeout = 1
 	}
 
+	orderByKey, _ := strconv.ParseBool(os.Getenv("KAFKA_ORDER_BY_KEY"))
+
+	// Setting ELASTIC_BULK_MAX_DOCS=0 disables bulk indexing.
+	bulkMaxDocs, err := strconv.Atoi(os.Getenv("ELASTIC_BULK_MAX_DOCS"))
+	if err != nil {
+		bulkMaxDocs = 500
+	}
+
+	bulkMaxBytes, err := strconv.Atoi(os.Getenv("ELASTIC_BULK_MAX_BYTES"))
+	if err != nil {
+		bulkMaxBytes = 5 << 20
+	}
+
+	bulkMaxLatency, err := time.ParseDuration(os.Getenv("ELASTIC_BULK_MAX_LATENCY"))
+	if err != nil {
+		bulkMaxLatency = time.Second
+	}
+
+	breakerFailures, err := strconv.Atoi(os.Getenv("ELASTIC_BREAKER_FAILURES"))
+	if err != nil {
+		breakerFailures = 5
+	}
+
+	breakerProbe, err := time.ParseDuration(os.Getenv("ELASTIC_BREAKER_PROBE_INTERVAL"))
+	if err != nil {
+		breakerProbe = 5 * time.Second
+	}
+
+	walMaxSegmentBytes, err := strconv.ParseInt(os.Getenv("WAL_MAX_SEGMENT_BYTES"), 10, 64)
+	if err != nil {
+		walMaxSegmentBytes = 64 << 20
+	}
+
+	walMaxBytes, err := strconv.ParseInt(os.Getenv("WAL_MAX_BYTES"), 10, 64)
+	if err != nil {
+		walMaxBytes = 1 << 30
+	}
+
+	walReplayInterval, err := time.ParseDuration(os.Getenv("WAL_REPLAY_INTERVAL"))
+	if err != nil {
+		walReplayInterval = 10 * time.Second
+	}
+
+	healthCheckTimeout, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_TIMEOUT"))
+	if err != nil {
+		healthCheckTimeout = 2 * time.Second
+	}
+
+	ingestMode := os.Getenv("INGEST_MODE")
+	if ingestMode == "" {
+		ingestMode = "kafka"
+	}
+
+	ingestMaxBytes, err := strconv.ParseInt(os.Getenv("INGEST_MAX_BODY_BYTES"), 10, 64)
+	if err != nil {
+		ingestMaxBytes = 5 << 20
+	}
+
+	syslogMaxMessageBytes, err := strconv.Atoi(os.Getenv("SYSLOG_MAX_MESSAGE_BYTES"))
+	if err != nil {
+		syslogMaxMessageBytes = 64 << 10
+	}
+
+	fileTailCheckpoint := os.Getenv("FILE_TAIL_CHECKPOINT")
+	if fileTailCheckpoint == "" {
+		fileTailCheckpoint = "./data/filetail.json"
+	}
+
+	containerLogCheckpoint := os.Getenv("CONTAINER_LOG_CHECKPOINT")
+	if containerLogCheckpoint == "" {
+		containerLogCheckpoint = "./data/containers.json"
+	}
+
+	fileTailPollInterval, err := time.ParseDuration(os.Getenv("FILE_TAIL_POLL_INTERVAL"))
+	if err != nil {
+		fileTailPollInterval = time.Second
+	}
+
+	tailBufferSize, err := strconv.Atoi(os.Getenv("TAIL_BUFFER_SIZE"))
+	if err != nil {
+		tailBufferSize = 256
+	}
+
+	tailHeartbeat, err := time.ParseDuration(os.Getenv("TAIL_HEARTBEAT_INTERVAL"))
+	if err != nil {
+		tailHeartbeat = 15 * time.Second
+	}
+
+	multilineMaxLines, err := strconv.Atoi(os.Getenv("MULTILINE_MAX_LINES"))
+	if err != nil {
+		multilineMaxLines = 500
+	}
+
+	multilineMaxBytes, err := strconv.Atoi(os.Getenv("MULTILINE_MAX_BYTES"))
+	if err != nil {
+		multilineMaxBytes = 1 << 20
+	}
+
+	multilineFlushTimeout, err := time.ParseDuration(os.Getenv("MULTILINE_FLUSH_TIMEOUT"))
+	if err != nil {
+		multilineFlushTimeout = 2 * time.Second
+	}
+
+	logFormatDefault := os.Getenv("LOG_FORMAT_DEFAULT")
+	if logFormatDefault == "" {
+		logFormatDefault = "auto"
+	}
+
 	timeoutStr := os.Getenv("SHUTDOWN_TIMEOUT")
 	timeout, err := time.ParseDuration(timeoutStr)
 	if err != nil {
@@ -57,16 +233,77 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		KafkaBrokers:         []string{os.Getenv("KAFKA_BROKERS")},
-		KafkaTopic:           os.Getenv("KAFKA_TOPIC"),
-		KafkaGroupID:         os.Getenv("KAFKA_GROUP_ID"),
-		MaxWorkers:           maxWorkers,
-		MaxConsumeRetries:    maxConsumeRetries,
-		BackOffRetries:       (time.Duration(backOffRetriesMs) * time.Millisecond),
-		WorkerTimeoutSeconds: workerTimeout,
-		ElasticHost:          os.Getenv("ELASTIC_HOST"),
-		ElasticIndex:         os.Getenv("ELASTIC_INDEX"),
-		APIPort:              os.Getenv("API_PORT"),
-		ShutdownTimeout:      timeout,
+		KafkaBrokers:                 []string{os.Getenv("KAFKA_BROKERS")},
+		KafkaTopic:                   os.Getenv("KAFKA_TOPIC"),
+		KafkaGroupID:                 os.Getenv("KAFKA_GROUP_ID"),
+		KafkaDeadLetterTopic:         os.Getenv("KAFKA_DEAD_LETTER_TOPIC"),
+		KafkaOrderByKey:              orderByKey,
+		KafkaHeaderAllowlist:         parseList(os.Getenv("KAFKA_HEADER_ALLOWLIST")),
+		KafkaHeaderRenames:           parsePairs(os.Getenv("KAFKA_HEADER_RENAMES")),
+		KafkaSourceHeader:            os.Getenv("KAFKA_SOURCE_HEADER"),
+		MaxWorkers:                   maxWorkers,
+		MaxConsumeRetries:            maxConsumeRetries,
+		BackOffRetries:               (time.Duration(backOffRetriesMs) * time.Millisecond),
+		RetryMaxInterval:             retryMaxInterval,
+		RetryMaxElapsedTime:          retryMaxElapsedTime,
+		WorkerTimeoutSeconds:         workerTimeout,
+		LogFormatDefault:             logFormatDefault,
+		LogFormatByTopic:             parsePairs(os.Getenv("LOG_FORMAT_BY_TOPIC")),
+		MultilineStartPattern:        os.Getenv("MULTILINE_START_PATTERN"),
+		MultilineContinuationPattern: os.Getenv("MULTILINE_CONTINUATION_PATTERN"),
+		MultilineMaxLines:            multilineMaxLines,
+		MultilineMaxBytes:            multilineMaxBytes,
+		MultilineFlushTimeout:        multilineFlushTimeout,
+		ElasticHost:                  os.Getenv("ELASTIC_HOST"),
+		ElasticIndex:                 os.Getenv("ELASTIC_INDEX"),
+		ElasticBulkMaxDocs:           bulkMaxDocs,
+		ElasticBulkMaxBytes:          bulkMaxBytes,
+		ElasticBulkMaxLatency:        bulkMaxLatency,
+		ElasticBreakerFailures:       breakerFailures,
+		ElasticBreakerProbe:          breakerProbe,
+		WALDir:                       os.Getenv("WAL_DIR"),
+		WALMaxSegmentBytes:           walMaxSegmentBytes,
+		WALMaxBytes:                  walMaxBytes,
+		WALReplayInterval:            walReplayInterval,
+		APIPort:                      os.Getenv("API_PORT"),
+		HealthCheckTimeout:           healthCheckTimeout,
+		IngestMode:                   ingestMode,
+		IngestMaxBytes:               ingestMaxBytes,
+		SyslogUDPAddr:                os.Getenv("SYSLOG_UDP_ADDR"),
+		SyslogTCPAddr:                os.Getenv("SYSLOG_TCP_ADDR"),
+		SyslogMaxMessageBytes:        syslogMaxMessageBytes,
+		FluentForwardAddr:            os.Getenv("FLUENT_FORWARD_ADDR"),
+		FileTailPatterns:             parseList(os.Getenv("FILE_TAIL_PATTERNS")),
+		FileTailCheckpoint:           fileTailCheckpoint,
+		FileTailPollInterval:         fileTailPollInterval,
+		FileTailFormat:               os.Getenv("FILE_TAIL_FORMAT"),
+		ContainerLogPatterns:         parseList(os.Getenv("CONTAINER_LOG_PATTERNS")),
+		ContainerLogCheckpoint:       containerLogCheckpoint,
+		TailBufferSize:               tailBufferSize,
+		TailHeartbeatInterval:        tailHeartbeat,
+		ShutdownTimeout:              timeout,
+	}
+}
+
+func parseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePairs reads "key:value,key:value" lists.
+func parsePairs(raw string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok || key == "" {
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(value)
 	}
+	return pairs
 }
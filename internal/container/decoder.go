@@ -0,0 +1,33 @@
+package container
+
+import (
+	"github.com/rodrigogmartins/log-processor/internal/filetail"
+)
+
+// Decoder unwraps the log files of Docker's json-file driver and of CRI
+// runtimes for the file tailer, telling the formats apart line by line. The
+// stream and what the path tells about the container become attributes;
+// lines in neither format are kept as they are.
+type Decoder struct{}
+
+func (Decoder) Decode(path string, data []byte) filetail.Line {
+	attributes := PathMetadata(path).Attributes()
+
+	entry, err := ParseLine(data)
+	if err != nil {
+		return filetail.Line{Text: data, Attributes: attributes}
+	}
+
+	attributes["container_stream"] = entry.Stream
+	if len(entry.Attrs) > 0 {
+		attributes["container_attrs"] = entry.Attrs
+	}
+
+	return filetail.Line{
+		Text:       []byte(entry.Message),
+		Partial:    entry.Partial,
+		Stream:     entry.Stream,
+		Time:       entry.Time,
+		Attributes: attributes,
+	}
+}
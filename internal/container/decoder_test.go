@@ -0,0 +1,109 @@
+package container
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/filetail"
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	mu   sync.Mutex
+	logs []service.Log
+}
+
+func (p *recordingProcessor) ProcessLog(ctx context.Context, logEntry service.Log) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logs = append(p.logs, logEntry)
+	return nil
+}
+
+func (p *recordingProcessor) Logs() []service.Log {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]service.Log{}, p.logs...)
+}
+
+func TestDecoder(t *testing.T) {
+	t.Run("GIVEN a CRI log file WHEN tailing THEN reassemble split lines with the container fields", func(t *testing.T) {
+		id := strings.Repeat("ab12", 16)
+		dir := t.TempDir()
+		path := filepath.Join(dir, "web_shop_nginx-"+id+".log")
+		require.NoError(t, os.WriteFile(path, []byte(
+			"2024-05-01T10:00:00.000000001Z stdout P {\"level\":\"ERROR\",\n"+
+				"2024-05-01T10:00:00.000000002Z stdout F \"message\":\"boom\"}\n"+
+				"2024-05-01T10:00:01Z stderr F plain text\n"), 0o644))
+
+		processor := &recordingProcessor{}
+		tailer := filetail.NewTailer([]string{filepath.Join(dir, "*.log")}, "", processor)
+		tailer.PollInterval = 10 * time.Millisecond
+		tailer.Decoder = Decoder{}
+		require.NoError(t, tailer.Start(context.Background()))
+		defer tailer.Close()
+
+		assert.Eventually(t, func() bool { return len(processor.Logs()) == 2 }, 2*time.Second, 10*time.Millisecond)
+		logs := processor.Logs()
+
+		assert.Equal(t, "boom", logs[0].Message)
+		assert.Equal(t, "ERROR", logs[0].Level)
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 1, time.UTC), logs[0].Timestamp)
+		assert.Equal(t, id, logs[0].Attributes["container_id"])
+		assert.Equal(t, "nginx", logs[0].Attributes["container_name"])
+		assert.Equal(t, "shop", logs[0].Attributes["k8s_namespace"])
+		assert.Equal(t, StreamStdout, logs[0].Attributes["container_stream"])
+
+		assert.Equal(t, "plain text", logs[1].Message)
+		assert.Equal(t, StreamStderr, logs[1].Attributes["container_stream"])
+	})
+
+	t.Run("GIVEN split lines of stdout and stderr interleaved WHEN tailing THEN reassemble each stream apart", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "app.log"), []byte(
+			"2024-05-01T10:00:00Z stdout P out-1 \n"+
+				"2024-05-01T10:00:01Z stderr P err-1 \n"+
+				"2024-05-01T10:00:02Z stderr F err-2\n"+
+				"2024-05-01T10:00:03Z stdout F out-2\n"), 0o644))
+
+		processor := &recordingProcessor{}
+		tailer := filetail.NewTailer([]string{filepath.Join(dir, "*.log")}, "", processor)
+		tailer.PollInterval = 10 * time.Millisecond
+		tailer.Format = parser.FormatRaw
+		tailer.Decoder = Decoder{}
+		require.NoError(t, tailer.Start(context.Background()))
+		defer tailer.Close()
+
+		assert.Eventually(t, func() bool { return len(processor.Logs()) == 2 }, 2*time.Second, 10*time.Millisecond)
+		logs := processor.Logs()
+
+		assert.Equal(t, "err-1 err-2", logs[0].Message)
+		assert.Equal(t, StreamStderr, logs[0].Attributes["container_stream"])
+		assert.Equal(t, "out-1 out-2", logs[1].Message)
+		assert.Equal(t, StreamStdout, logs[1].Attributes["container_stream"])
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), logs[1].Timestamp)
+	})
+
+	t.Run("GIVEN a CRI line WHEN decoding THEN tell its stream", func(t *testing.T) {
+		line := Decoder{}.Decode("/var/log/app.log", []byte("2024-05-01T10:00:00Z stderr P boom"))
+
+		assert.Equal(t, StreamStderr, line.Stream)
+		assert.True(t, line.Partial)
+	})
+
+	t.Run("GIVEN a line in neither format WHEN decoding THEN keep it as it is", func(t *testing.T) {
+		line := Decoder{}.Decode("/var/log/app.log", []byte("hello"))
+
+		assert.Equal(t, "hello", string(line.Text))
+		assert.False(t, line.Partial)
+		assert.Empty(t, line.Attributes)
+	})
+}
@@ -0,0 +1,103 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+
+	// criPartial tags the lines a CRI runtime split, the last part is
+	// tagged "F".
+	criPartial = "P"
+)
+
+// Entry is one line written by a container runtime.
+type Entry struct {
+	Time    time.Time
+	Stream  string
+	Message string
+	// Partial marks a line the runtime split, continued by the next entry
+	// of the same stream.
+	Partial bool
+	// Attrs are the extra attributes Docker adds when configured with the
+	// labels, env or tag log options.
+	Attrs map[string]string
+}
+
+var errInvalidCRI = errors.New("not a CRI log line")
+
+// ParseLine reads a json-file line when it starts with a brace and a CRI
+// line otherwise.
+func ParseLine(data []byte) (Entry, error) {
+	if len(data) > 0 && data[0] == '{' {
+		return ParseDocker(data)
+	}
+	return ParseCRI(data)
+}
+
+// ParseDocker reads a line of Docker's json-file driver:
+//
+//	{"log":"message\n","stream":"stdout","time":"2024-05-01T10:00:00.000000001Z"}
+//
+// Docker splits lines longer than 16KB, every part but the last lacks the
+// trailing newline.
+func ParseDocker(data []byte) (Entry, error) {
+	var line struct {
+		Log    *string           `json:"log"`
+		Stream string            `json:"stream"`
+		Time   time.Time         `json:"time"`
+		Attrs  map[string]string `json:"attrs"`
+	}
+	if err := json.Unmarshal(data, &line); err != nil {
+		return Entry{}, fmt.Errorf("invalid json-file line: %w", err)
+	}
+	if line.Log == nil {
+		return Entry{}, errors.New("invalid json-file line: missing log")
+	}
+
+	message, complete := strings.CutSuffix(*line.Log, "\n")
+	return Entry{
+		Time:    line.Time,
+		Stream:  line.Stream,
+		Message: strings.TrimSuffix(message, "\r"),
+		Partial: !complete,
+		Attrs:   line.Attrs,
+	}, nil
+}
+
+// ParseCRI reads a line in the CRI logging format used by containerd and
+// CRI-O:
+//
+//	2024-05-01T10:00:00.000000001Z stdout F message
+func ParseCRI(data []byte) (Entry, error) {
+	fields := bytes.SplitN(data, []byte(" "), 4)
+	if len(fields) < 3 {
+		return Entry{}, errInvalidCRI
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, string(fields[0]))
+	if err != nil {
+		return Entry{}, errInvalidCRI
+	}
+
+	stream := string(fields[1])
+	if stream != StreamStdout && stream != StreamStderr {
+		return Entry{}, errInvalidCRI
+	}
+
+	// Runtimes may append more tags after a colon.
+	tag, _, _ := strings.Cut(string(fields[2]), ":")
+
+	entry := Entry{Time: ts, Stream: stream, Partial: tag == criPartial}
+	if len(fields) == 4 {
+		entry.Message = string(fields[3])
+	}
+	return entry, nil
+}
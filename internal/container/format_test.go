@@ -0,0 +1,72 @@
+package container
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 1, time.UTC)
+
+	tests := []struct {
+		name     string
+		line     string
+		expected Entry
+		wantErr  bool
+	}{
+		{
+			name:     "docker complete line",
+			line:     `{"log":"hello\n","stream":"stdout","time":"2024-05-01T10:00:00.000000001Z"}`,
+			expected: Entry{Time: ts, Stream: StreamStdout, Message: "hello"},
+		},
+		{
+			name:     "docker split line",
+			line:     `{"log":"hel","stream":"stderr","time":"2024-05-01T10:00:00.000000001Z"}`,
+			expected: Entry{Time: ts, Stream: StreamStderr, Message: "hel", Partial: true},
+		},
+		{
+			name:     "docker crlf and attrs",
+			line:     `{"log":"hello\r\n","stream":"stdout","time":"2024-05-01T10:00:00.000000001Z","attrs":{"tag":"web"}}`,
+			expected: Entry{Time: ts, Stream: StreamStdout, Message: "hello", Attrs: map[string]string{"tag": "web"}},
+		},
+		{name: "docker without log", line: `{"stream":"stdout"}`, wantErr: true},
+		{name: "docker broken json", line: `{"log":`, wantErr: true},
+		{
+			name:     "cri full line",
+			line:     "2024-05-01T10:00:00.000000001Z stdout F hello world",
+			expected: Entry{Time: ts, Stream: StreamStdout, Message: "hello world"},
+		},
+		{
+			name:     "cri partial line",
+			line:     "2024-05-01T10:00:00.000000001Z stderr P hel",
+			expected: Entry{Time: ts, Stream: StreamStderr, Message: "hel", Partial: true},
+		},
+		{
+			name:     "cri extra tags",
+			line:     "2024-05-01T10:00:00.000000001Z stdout P:x hel",
+			expected: Entry{Time: ts, Stream: StreamStdout, Message: "hel", Partial: true},
+		},
+		{
+			name:     "cri empty message",
+			line:     "2024-05-01T10:00:00.000000001Z stdout F",
+			expected: Entry{Time: ts, Stream: StreamStdout},
+		},
+		{name: "cri bad timestamp", line: "yesterday stdout F hello", wantErr: true},
+		{name: "cri bad stream", line: "2024-05-01T10:00:00Z stdin F hello", wantErr: true},
+		{name: "plain text", line: "hello", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := ParseLine([]byte(tt.line))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, entry)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package container
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// Metadata is what the path of a container log file tells about the
+// container that wrote it. Fields the layout does not carry are empty.
+type Metadata struct {
+	ContainerID   string
+	ContainerName string
+	Pod           string
+	Namespace     string
+	PodUID        string
+}
+
+// PathMetadata recognizes the layouts container logs are found under:
+//
+//	/var/lib/docker/containers/<id>/<id>-json.log
+//	/var/log/containers/<pod>_<namespace>_<container>-<id>.log
+//	/var/log/pods/<namespace>_<pod>_<uid>/<container>/<restart>.log
+//
+// Rotated files, with a suffix after ".log", are recognized as well.
+func PathMetadata(path string) Metadata {
+	base := filepath.Base(path)
+	dir := filepath.Base(filepath.Dir(path))
+
+	// Docker names the file after its directory.
+	if isContainerID(dir) && strings.HasPrefix(base, dir+"-json.log") {
+		return Metadata{ContainerID: dir}
+	}
+
+	name, _, ok := strings.Cut(base, ".log")
+	if !ok {
+		return Metadata{}
+	}
+
+	// The kubelet symlinks, named after the pod and the container.
+	if parts := strings.Split(name, "_"); len(parts) == 3 {
+		if i := strings.LastIndex(parts[2], "-"); i > 0 && isContainerID(parts[2][i+1:]) {
+			return Metadata{
+				ContainerID:   parts[2][i+1:],
+				ContainerName: parts[2][:i],
+				Pod:           parts[0],
+				Namespace:     parts[1],
+			}
+		}
+	}
+
+	// The files they point to, one directory per pod and container.
+	podDir := filepath.Base(filepath.Dir(filepath.Dir(path)))
+	if parts := strings.Split(podDir, "_"); len(parts) == 3 && isNumber(name) {
+		return Metadata{
+			ContainerName: dir,
+			Namespace:     parts[0],
+			Pod:           parts[1],
+			PodUID:        parts[2],
+		}
+	}
+
+	return Metadata{}
+}
+
+// Attributes returns the metadata as document attributes, leaving out the
+// empty ones.
+func (m Metadata) Attributes() map[string]interface{} {
+	attributes := make(map[string]interface{})
+	for key, value := range map[string]string{
+		"container_id":   m.ContainerID,
+		"container_name": m.ContainerName,
+		"k8s_pod":        m.Pod,
+		"k8s_namespace":  m.Namespace,
+		"k8s_pod_uid":    m.PodUID,
+	} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+	return attributes
+}
+
+func isContainerID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
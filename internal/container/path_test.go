@@ -0,0 +1,52 @@
+package container
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathMetadata(t *testing.T) {
+	id := strings.Repeat("ab12", 16)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected Metadata
+	}{
+		{
+			name:     "docker json-file",
+			path:     "/var/lib/docker/containers/" + id + "/" + id + "-json.log",
+			expected: Metadata{ContainerID: id},
+		},
+		{
+			name:     "docker rotated json-file",
+			path:     "/var/lib/docker/containers/" + id + "/" + id + "-json.log.1",
+			expected: Metadata{ContainerID: id},
+		},
+		{
+			name:     "kubelet symlink",
+			path:     "/var/log/containers/web-7d9f_shop_nginx-proxy-" + id + ".log",
+			expected: Metadata{ContainerID: id, ContainerName: "nginx-proxy", Pod: "web-7d9f", Namespace: "shop"},
+		},
+		{
+			name:     "pod log directory",
+			path:     "/var/log/pods/shop_web-7d9f_0c5e-11ef/nginx/3.log",
+			expected: Metadata{ContainerName: "nginx", Pod: "web-7d9f", Namespace: "shop", PodUID: "0c5e-11ef"},
+		},
+		{
+			name:     "rotated pod log",
+			path:     "/var/log/pods/shop_web-7d9f_0c5e-11ef/nginx/0.log.20240501-100000",
+			expected: Metadata{ContainerName: "nginx", Pod: "web-7d9f", Namespace: "shop", PodUID: "0c5e-11ef"},
+		},
+		{name: "unrelated file", path: "/var/log/app/server.log", expected: Metadata{}},
+		{name: "short container id", path: "/var/log/containers/web_shop_nginx-abc.log", expected: Metadata{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, PathMetadata(tt.path))
+		})
+	}
+}
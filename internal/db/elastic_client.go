@@ -5,7 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log"
+	"net/http"
+	"time"
 
 	esv8 "github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
@@ -13,6 +15,25 @@ import (
 	"github.com/rodrigogmartins/log-processor/internal/service"
 )
 
+// ResponseError is returned when Elasticsearch answers with an error status.
+type ResponseError struct {
+	Op         string
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Op, e.Body)
+}
+
+func (e *ResponseError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+func newResponseError(op string, res *esapi.Response) error {
+	return &ResponseError{Op: op, StatusCode: res.StatusCode, Body: res.String()}
+}
+
 type ElasticSearchClient struct {
 	Client *esv8.Client
 }
@@ -31,6 +52,46 @@ func NewElasticSearchClient(addresses []string) (*ElasticSearchClient, error) {
 	}, nil
 }
 
+func (c *ElasticSearchClient) Ping(ctx context.Context) error {
+	res, err := c.Client.Ping(c.Client.Ping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newResponseError("error pinging elasticsearch", res)
+	}
+
+	return nil
+}
+
+// Health fails when the cluster is unreachable or red. A yellow cluster is
+// healthy: only replicas are missing and every index can still be written.
+func (c *ElasticSearchClient) Health(ctx context.Context) error {
+	res, err := c.Client.Cluster.Health(c.Client.Cluster.Health.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return newResponseError("error reading cluster health", res)
+	}
+
+	var health struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		return err
+	}
+
+	if health.Status == "red" {
+		return fmt.Errorf("cluster status is %s", health.Status)
+	}
+	return nil
+}
+
 func (c *ElasticSearchClient) Index(ctx context.Context, index string, id string, body interface{}) error {
 	data, err := json.Marshal(body)
 	if err != nil {
@@ -51,22 +112,187 @@ func (c *ElasticSearchClient) Index(ctx context.Context, index string, id string
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("error indexing document ID %s: %s", id, res.String())
+		return newResponseError(fmt.Sprintf("error indexing document ID %s", id), res)
 	}
 
 	return nil
 }
 
-func (c *ElasticSearchClient) SearchLogs(ctx context.Context, index string, query map[string]interface{}, size int) ([]service.Log, error) {
-	body := map[string]interface{}{
-		"query": query,
-		"size":  size,
+func (c *ElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	if len(docs) == 0 {
+		return nil, nil
 	}
 
-	data, err := json.Marshal(body)
+	var buf bytes.Buffer
+	for _, doc := range docs {
+		meta := map[string]interface{}{
+			"index": map[string]interface{}{
+				"_index": index,
+				"_id":    doc.ID,
+			},
+		}
+		if err := writeNDJSON(&buf, meta); err != nil {
+			return nil, err
+		}
+		if err := writeNDJSON(&buf, doc.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := c.Client.Bulk(
+		bytes.NewReader(buf.Bytes()),
+		c.Client.Bulk.WithContext(ctx),
+		c.Client.Bulk.WithIndex(index),
+	)
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, newResponseError(fmt.Sprintf("error bulk indexing %d documents", len(docs)), res)
+	}
+
+	var r struct {
+		Items []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	results := make([]service.BulkItemResult, len(r.Items))
+	for i, item := range r.Items {
+		for _, action := range item {
+			results[i] = service.BulkItemResult{ID: action.ID, Status: action.Status}
+			if action.Error != nil {
+				results[i].Error = fmt.Sprintf("%s: %s", action.Error.Type, action.Error.Reason)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func writeNDJSON(buf *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	return nil
+}
+
+func (c *ElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	body, err := searchBody(query)
+	if err != nil {
+		return service.SearchResult{}, err
+	}
+
+	opts := []func(*esapi.SearchRequest){c.Client.Search.WithContext(ctx)}
+	// opened is the point in time opened for this search, if any.
+	var opened string
+	if query.PointInTime != nil {
+		pitID := query.PointInTime.ID
+		if pitID == "" {
+			pitID, err = c.openPointInTime(ctx, index, query.PointInTime.KeepAlive)
+			if err != nil {
+				return service.SearchResult{}, err
+			}
+			opened = pitID
+		}
+		// A search on a point in time must not name the index.
+		body["pit"] = map[string]interface{}{
+			"id":         pitID,
+			"keep_alive": query.PointInTime.KeepAlive,
+		}
+	} else {
+		opts = append(opts, c.Client.Search.WithIndex(index))
+	}
+
+	result, err := c.search(opts, body, query)
+	if err != nil && opened != "" {
+		// Nobody else learns the ID of a point in time opened for a search
+		// that failed, so it is closed here.
+		if closeErr := c.ClosePointInTime(context.WithoutCancel(ctx), opened); closeErr != nil {
+			log.Printf("Error closing point in time of a failed search: %v", closeErr)
+		}
+	}
+	return result, err
+}
+
+func (c *ElasticSearchClient) search(opts []func(*esapi.SearchRequest), body map[string]interface{}, query service.Query) (service.SearchResult, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return service.SearchResult{}, err
+	}
+
+	res, err := c.Client.Search(append(opts, c.Client.Search.WithBody(bytes.NewReader(data)))...)
+	if err != nil {
+		return service.SearchResult{}, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return service.SearchResult{}, newResponseError("error searching logs", res)
+	}
+
+	var r struct {
+		PitID string `json:"pit_id"`
+		Hits  struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source service.Log   `json:"_source"`
+				Sort   []interface{} `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+
+	// Sort values are decoded as json.Number so long timestamps are sent
+	// back in search_after without losing precision.
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&r); err != nil {
+		return service.SearchResult{}, err
+	}
+
+	result := service.SearchResult{
+		Logs:          make([]service.Log, len(r.Hits.Hits)),
+		Total:         r.Hits.Total.Value,
+		PointInTimeID: r.PitID,
+	}
+	for i, hit := range r.Hits.Hits {
+		result.Logs[i] = hit.Source
+	}
+	if n := len(r.Hits.Hits); n > 0 {
+		result.SearchAfter = r.Hits.Hits[n-1].Sort
+	}
+
+	return result, nil
+}
+
+// AggregateLogs counts the logs matching where per bucket of each
+// aggregation, without fetching the logs themselves.
+func (c *ElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	body, err := aggregationBody(where, aggs)
+	if err != nil {
+		return service.AggregationResult{}, err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return service.AggregationResult{}, err
+	}
 
 	res, err := c.Client.Search(
 		c.Client.Search.WithContext(ctx),
@@ -74,35 +300,103 @@ func (c *ElasticSearchClient) SearchLogs(ctx context.Context, index string, quer
 		c.Client.Search.WithBody(bytes.NewReader(data)),
 	)
 	if err != nil {
-		return nil, err
+		return service.AggregationResult{}, err
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, fmt.Errorf("error searching logs: %s", res.String())
+		return service.AggregationResult{}, newResponseError("error aggregating logs", res)
 	}
 
 	var r struct {
 		Hits struct {
-			Hits []struct {
-				Source service.Log `json:"_source"`
-			} `json:"hits"`
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
 		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key      interface{} `json:"key"`
+				DocCount int64       `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
 	}
 
-	dataResp, err := io.ReadAll(res.Body)
+	dec := json.NewDecoder(res.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&r); err != nil {
+		return service.AggregationResult{}, err
+	}
+
+	kinds := make(map[string]service.AggregationKind, len(aggs))
+	for _, agg := range aggs {
+		kinds[agg.Name] = agg.Kind
+	}
+
+	result := service.AggregationResult{
+		Total:   r.Hits.Total.Value,
+		Buckets: make(map[string][]service.Bucket, len(r.Aggregations)),
+	}
+	for name, agg := range r.Aggregations {
+		buckets := make([]service.Bucket, len(agg.Buckets))
+		for i, b := range agg.Buckets {
+			buckets[i] = service.Bucket{Key: bucketKey(kinds[name], b.Key), Count: b.DocCount}
+		}
+		result.Buckets[name] = buckets
+	}
+	return result, nil
+}
+
+// bucketKey renders date histogram keys, which Elasticsearch sends as epoch
+// milliseconds, as RFC 3339 timestamps.
+func bucketKey(kind service.AggregationKind, key interface{}) string {
+	if n, ok := key.(json.Number); ok && kind == service.AggregateDateHistogram {
+		if millis, err := n.Int64(); err == nil {
+			return time.UnixMilli(millis).UTC().Format(time.RFC3339)
+		}
+	}
+	return fmt.Sprint(key)
+}
+
+// ClosePointInTime releases a point in time before its keep alive runs out.
+// One that already expired is not an error.
+func (c *ElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	body, err := json.Marshal(map[string]string{"id": id})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := json.Unmarshal(dataResp, &r); err != nil {
-		return nil, err
+	res, err := c.Client.ClosePointInTime(
+		c.Client.ClosePointInTime.WithContext(ctx),
+		c.Client.ClosePointInTime.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return err
 	}
+	defer res.Body.Close()
 
-	logs := make([]service.Log, len(r.Hits.Hits))
-	for i, hit := range r.Hits.Hits {
-		logs[i] = hit.Source
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return newResponseError("error closing point in time", res)
+	}
+	return nil
+}
+
+func (c *ElasticSearchClient) openPointInTime(ctx context.Context, index string, keepAlive string) (string, error) {
+	res, err := c.Client.OpenPointInTime([]string{index}, keepAlive, c.Client.OpenPointInTime.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", newResponseError("error opening point in time", res)
 	}
 
-	return logs, nil
+	var r struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return "", err
+	}
+	return r.ID, nil
 }
@@ -3,6 +3,7 @@ package db
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"testing"
@@ -45,6 +46,61 @@ func TestElasticSearchClient_Index(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestElasticSearchClient_IndexError(t *testing.T) {
+	mockResp := &http.Response{
+		StatusCode: 429,
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+		Body:       io.NopCloser(bytes.NewBufferString(`{"error":"too many requests"}`)),
+	}
+
+	cfg := esv8.Config{
+		Transport: &MockTransport{Response: mockResp},
+	}
+	client, _ := esv8.NewClient(cfg)
+	esClient := &ElasticSearchClient{Client: client}
+
+	err := esClient.Index(context.Background(), "logs-index", "1", service.Log{ID: "1", Message: "Test log"})
+
+	var resErr *ResponseError
+	assert.ErrorAs(t, err, &resErr)
+	assert.Equal(t, 429, resErr.HTTPStatus())
+}
+
+func TestElasticSearchClient_BulkIndex(t *testing.T) {
+	respJSON := `{
+		"errors": true,
+		"items": [
+			{"index": {"_id":"1","status":201}},
+			{"index": {"_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}
+		]
+	}`
+
+	mockResp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+		Body:       io.NopCloser(bytes.NewBufferString(respJSON)),
+	}
+
+	cfg := esv8.Config{
+		Transport: &MockTransport{Response: mockResp},
+	}
+	client, _ := esv8.NewClient(cfg)
+	esClient := &ElasticSearchClient{Client: client}
+
+	docs := []service.BulkDocument{
+		{ID: "1", Body: service.Log{ID: "1", Message: "msg1"}},
+		{ID: "2", Body: service.Log{ID: "2", Message: "msg2"}},
+	}
+
+	results, err := esClient.BulkIndex(context.Background(), "logs-index", docs)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.False(t, results[0].Failed())
+	assert.True(t, results[1].Failed())
+	assert.Equal(t, 400, results[1].Status)
+	assert.Equal(t, "mapper_parsing_exception: failed to parse", results[1].Error)
+}
+
 func TestElasticSearchClient_SearchLogs(t *testing.T) {
 	respJSON := `{
 		"hits": {
@@ -67,13 +123,183 @@ func TestElasticSearchClient_SearchLogs(t *testing.T) {
 	client, _ := esv8.NewClient(cfg)
 	esClient := &ElasticSearchClient{Client: client}
 
-	query := map[string]interface{}{
-		"match": map[string]interface{}{"level": "INFO"},
+	query := service.Query{
+		Where: service.Match{Field: "level", Text: "INFO"},
+		Size:  10,
+	}
+
+	result, err := esClient.SearchLogs(context.Background(), "logs-index", query)
+	assert.NoError(t, err)
+	assert.Len(t, result.Logs, 2)
+	assert.Equal(t, "msg1", result.Logs[0].Message)
+	assert.Equal(t, "msg2", result.Logs[1].Message)
+}
+
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestElasticSearchClient_SearchLogsPointInTime(t *testing.T) {
+	var paths []string
+	var searchBody map[string]interface{}
+	transport := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+
+		respJSON := `{"id":"pit-1"}`
+		if req.URL.Path == "/_search" {
+			json.NewDecoder(req.Body).Decode(&searchBody)
+			respJSON = `{
+				"pit_id": "pit-2",
+				"hits": {
+					"total": {"value": 42, "relation": "eq"},
+					"hits": [
+						{"_source": {"id":"2","message":"msg2"}, "sort": [1718000000123, "2"]},
+						{"_source": {"id":"1","message":"msg1"}, "sort": [1718000000001, "1"]}
+					]
+				}
+			}`
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:       io.NopCloser(bytes.NewBufferString(respJSON)),
+		}, nil
+	})
+
+	client, _ := esv8.NewClient(esv8.Config{Transport: transport})
+	esClient := &ElasticSearchClient{Client: client}
+
+	result, err := esClient.SearchLogs(context.Background(), "logs-index", service.Query{
+		Size:           2,
+		Sort:           []service.SortField{{Field: "timestamp", Desc: true}},
+		PointInTime:    &service.PointInTime{KeepAlive: "1m"},
+		TrackTotalHits: true,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"/logs-index/_pit", "/_search"}, paths)
+	assert.Equal(t, map[string]interface{}{"id": "pit-1", "keep_alive": "1m"}, searchBody["pit"])
+	assert.Equal(t, true, searchBody["track_total_hits"])
+
+	assert.Len(t, result.Logs, 2)
+	assert.Equal(t, int64(42), result.Total)
+	assert.Equal(t, "pit-2", result.PointInTimeID)
+	assert.Equal(t, []interface{}{json.Number("1718000000001"), "1"}, result.SearchAfter)
+}
+
+func TestElasticSearchClient_ClosePointInTime(t *testing.T) {
+	newClient := func(status int, requests *[]string) *ElasticSearchClient {
+		transport := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			*requests = append(*requests, req.Method+" "+req.URL.Path+" "+string(body))
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				Body:       io.NopCloser(bytes.NewBufferString(`{"succeeded":true,"num_freed":1}`)),
+			}, nil
+		})
+		client, _ := esv8.NewClient(esv8.Config{Transport: transport})
+		return &ElasticSearchClient{Client: client}
 	}
 
-	logs, err := esClient.SearchLogs(context.Background(), "logs-index", query, 10)
+	t.Run("GIVEN a point in time WHEN closing it THEN delete it by ID", func(t *testing.T) {
+		var requests []string
+		err := newClient(200, &requests).ClosePointInTime(context.Background(), "pit-1")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`DELETE /_pit {"id":"pit-1"}`}, requests)
+	})
+
+	t.Run("GIVEN an expired point in time WHEN closing it THEN succeed", func(t *testing.T) {
+		var requests []string
+		assert.NoError(t, newClient(404, &requests).ClosePointInTime(context.Background(), "pit-1"))
+	})
+
+	t.Run("GIVEN an error WHEN closing a point in time THEN return it", func(t *testing.T) {
+		var requests []string
+		assert.Error(t, newClient(500, &requests).ClosePointInTime(context.Background(), "pit-1"))
+	})
+
+	t.Run("GIVEN a failed search WHEN it opened a point in time THEN close it", func(t *testing.T) {
+		var requests []string
+		transport := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			status, respJSON := 200, `{"id":"pit-1"}`
+			if req.URL.Path == "/_search" {
+				status, respJSON = 500, `{"error":"boom"}`
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+				Body:       io.NopCloser(bytes.NewBufferString(respJSON)),
+			}, nil
+		})
+		client, _ := esv8.NewClient(esv8.Config{Transport: transport})
+		esClient := &ElasticSearchClient{Client: client}
+
+		_, err := esClient.SearchLogs(context.Background(), "logs-index", service.Query{
+			Size:        2,
+			PointInTime: &service.PointInTime{KeepAlive: "1m"},
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, []string{"POST /logs-index/_pit", "POST /_search", "DELETE /_pit"}, requests)
+	})
+}
+
+func TestElasticSearchClient_Health(t *testing.T) {
+	newClient := func(status int, body string) *ElasticSearchClient {
+		mockResp := &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}
+		client, _ := esv8.NewClient(esv8.Config{Transport: &MockTransport{Response: mockResp}})
+		return &ElasticSearchClient{Client: client}
+	}
+
+	t.Run("GIVEN a yellow cluster WHEN checking health THEN report healthy", func(t *testing.T) {
+		err := newClient(200, `{"cluster_name":"logs","status":"yellow"}`).Health(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("GIVEN a red cluster WHEN checking health THEN report unhealthy", func(t *testing.T) {
+		err := newClient(200, `{"cluster_name":"logs","status":"red"}`).Health(context.Background())
+		assert.EqualError(t, err, "cluster status is red")
+	})
+
+	t.Run("GIVEN an error response WHEN checking health THEN return it", func(t *testing.T) {
+		err := newClient(503, `{"error":"unavailable"}`).Health(context.Background())
+		assert.Error(t, err)
+	})
+}
+
+func TestElasticSearchClient_AggregateLogs(t *testing.T) {
+	respJSON := `{
+		"hits": {"total": {"value": 3}, "hits": []},
+		"aggregations": {
+			"levels": {"buckets": [{"key": "ERROR", "doc_count": 2}, {"key": "INFO", "doc_count": 1}]},
+			"timeline": {"buckets": [{"key": 1718000000000, "key_as_string": "2024-06-10T06:13:20.000Z", "doc_count": 3}]}
+		}
+	}`
+
+	mockResp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"X-Elastic-Product": []string{"Elasticsearch"}},
+		Body:       io.NopCloser(bytes.NewBufferString(respJSON)),
+	}
+	client, _ := esv8.NewClient(esv8.Config{Transport: &MockTransport{Response: mockResp}})
+	esClient := &ElasticSearchClient{Client: client}
+
+	result, err := esClient.AggregateLogs(context.Background(), "logs-index", nil, []service.Aggregation{
+		{Name: "levels", Kind: service.AggregateTerms, Field: "level"},
+		{Name: "timeline", Kind: service.AggregateDateHistogram, Field: "timestamp", Interval: "1h"},
+	})
 	assert.NoError(t, err)
-	assert.Len(t, logs, 2)
-	assert.Equal(t, "msg1", logs[0].Message)
-	assert.Equal(t, "msg2", logs[1].Message)
+
+	assert.Equal(t, int64(3), result.Total)
+	assert.Equal(t, []service.Bucket{{Key: "ERROR", Count: 2}, {Key: "INFO", Count: 1}}, result.Buckets["levels"])
+	assert.Equal(t, []service.Bucket{{Key: "2024-06-10T06:13:20Z", Count: 3}}, result.Buckets["timeline"])
 }
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// nonTextFields are mapped as dates or numbers. Every other field is indexed
+// as text with a keyword sub-field by the dynamic mapping, and exact matches,
+// sorting and aggregations must use the keyword.
+var nonTextFields = map[string]bool{
+	"timestamp":       true,
+	"kafka.partition": true,
+	"kafka.offset":    true,
+}
+
+// fullTextFields are searched word by word rather than as a whole value.
+var fullTextFields = map[string]bool{
+	"message": true,
+}
+
+func keywordField(field string) string {
+	if nonTextFields[field] {
+		return field
+	}
+	return field + ".keyword"
+}
+
+// searchBody translates the query into the body of an Elasticsearch search.
+func searchBody(q service.Query) (map[string]interface{}, error) {
+	query, err := translateCondition(q.Where)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"query": query,
+		"size":  q.Size,
+	}
+
+	if len(q.Sort) > 0 {
+		sort := make([]map[string]interface{}, len(q.Sort))
+		for i, s := range q.Sort {
+			order := "asc"
+			if s.Desc {
+				order = "desc"
+			}
+			opts := map[string]interface{}{"order": order}
+			if !nonTextFields[s.Field] {
+				opts["unmapped_type"] = "keyword"
+			}
+			sort[i] = map[string]interface{}{keywordField(s.Field): opts}
+		}
+		body["sort"] = sort
+	}
+
+	if len(q.SearchAfter) > 0 {
+		body["search_after"] = q.SearchAfter
+	}
+	if q.TrackTotalHits {
+		body["track_total_hits"] = true
+	}
+
+	return body, nil
+}
+
+// aggregationBody translates the aggregations of the logs matching where into
+// the body of a search returning only the buckets and the total.
+func aggregationBody(where service.Condition, aggregations []service.Aggregation) (map[string]interface{}, error) {
+	body, err := searchBody(service.Query{Where: where, TrackTotalHits: true})
+	if err != nil {
+		return nil, err
+	}
+
+	aggs := make(map[string]interface{}, len(aggregations))
+	for _, agg := range aggregations {
+		translated, err := translateAggregation(agg)
+		if err != nil {
+			return nil, err
+		}
+		aggs[agg.Name] = translated
+	}
+	body["aggs"] = aggs
+	return body, nil
+}
+
+func translateCondition(c service.Condition) (map[string]interface{}, error) {
+	switch c := c.(type) {
+	case nil:
+		return matchAll(), nil
+
+	case service.Term:
+		if len(c.Values) == 1 {
+			return map[string]interface{}{
+				"term": map[string]interface{}{keywordField(c.Field): c.Values[0]},
+			}, nil
+		}
+		return map[string]interface{}{
+			"terms": map[string]interface{}{keywordField(c.Field): c.Values},
+		}, nil
+
+	case service.Match:
+		return map[string]interface{}{
+			"match": map[string]interface{}{
+				c.Field: map[string]interface{}{"query": c.Text, "operator": "and"},
+			},
+		}, nil
+
+	case service.Phrase:
+		return map[string]interface{}{
+			"match_phrase": map[string]interface{}{c.Field: c.Text},
+		}, nil
+
+	case service.Wildcard:
+		field := keywordField(c.Field)
+		if fullTextFields[c.Field] {
+			field = c.Field
+		}
+		return map[string]interface{}{
+			"wildcard": map[string]interface{}{
+				field: map[string]interface{}{"value": c.Pattern, "case_insensitive": true},
+			},
+		}, nil
+
+	case service.Range:
+		bounds := map[string]interface{}{}
+		if c.From != "" {
+			bounds[bound("gte", "gt", c.FromExclusive)] = c.From
+		}
+		if c.To != "" {
+			bounds[bound("lte", "lt", c.ToExclusive)] = c.To
+		}
+		return map[string]interface{}{
+			"range": map[string]interface{}{c.Field: bounds},
+		}, nil
+
+	case service.Exists:
+		return map[string]interface{}{
+			"exists": map[string]interface{}{"field": c.Field},
+		}, nil
+
+	case service.And:
+		if len(c) == 0 {
+			return matchAll(), nil
+		}
+		// Full-text matches score the hits, the rest only filter them.
+		var must, filter []interface{}
+		for _, child := range c {
+			translated, err := translateCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			if isFullText(child) {
+				must = append(must, translated)
+			} else {
+				filter = append(filter, translated)
+			}
+		}
+		boolQuery := map[string]interface{}{}
+		if len(filter) > 0 {
+			boolQuery["filter"] = filter
+		}
+		if len(must) > 0 {
+			boolQuery["must"] = must
+		}
+		return map[string]interface{}{"bool": boolQuery}, nil
+
+	case service.Or:
+		should := make([]interface{}, len(c))
+		for i, child := range c {
+			translated, err := translateCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			should[i] = translated
+		}
+		return map[string]interface{}{
+			"bool": map[string]interface{}{"should": should, "minimum_should_match": 1},
+		}, nil
+
+	case service.Not:
+		translated, err := translateCondition(c.Condition)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"bool": map[string]interface{}{"must_not": []interface{}{translated}},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported condition %T", c)
+}
+
+func translateAggregation(agg service.Aggregation) (map[string]interface{}, error) {
+	switch agg.Kind {
+	case service.AggregateTerms:
+		terms := map[string]interface{}{"field": keywordField(agg.Field)}
+		if agg.Size > 0 {
+			terms["size"] = agg.Size
+		}
+		return map[string]interface{}{"terms": terms}, nil
+
+	case service.AggregateDateHistogram:
+		return map[string]interface{}{
+			"date_histogram": map[string]interface{}{
+				"field":          agg.Field,
+				"fixed_interval": agg.Interval,
+				"min_doc_count":  0,
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported aggregation %q", agg.Kind)
+}
+
+func isFullText(c service.Condition) bool {
+	switch c.(type) {
+	case service.Match, service.Phrase:
+		return true
+	}
+	return false
+}
+
+func bound(inclusive, exclusive string, isExclusive bool) string {
+	if isExclusive {
+		return exclusive
+	}
+	return inclusive
+}
+
+func matchAll() map[string]interface{} {
+	return map[string]interface{}{"match_all": map[string]interface{}{}}
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type m = map[string]interface{}
+
+func TestTranslateCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition service.Condition
+		expected  map[string]interface{}
+	}{
+		{
+			name:      "GIVEN no condition THEN match all",
+			condition: nil,
+			expected:  m{"match_all": m{}},
+		},
+		{
+			name:      "GIVEN a single term THEN use term on the keyword field",
+			condition: service.Term{Field: "id", Values: []string{"abc-1"}},
+			expected:  m{"term": m{"id.keyword": "abc-1"}},
+		},
+		{
+			name:      "GIVEN several terms THEN use terms",
+			condition: service.Term{Field: "level", Values: []string{"ERROR", "WARN"}},
+			expected:  m{"terms": m{"level.keyword": []string{"ERROR", "WARN"}}},
+		},
+		{
+			name:      "GIVEN a numeric field THEN keep its name",
+			condition: service.Term{Field: "kafka.partition", Values: []string{"3"}},
+			expected:  m{"term": m{"kafka.partition": "3"}},
+		},
+		{
+			name:      "GIVEN a match THEN require every word",
+			condition: service.Match{Field: "message", Text: "connection timeout"},
+			expected:  m{"match": m{"message": m{"query": "connection timeout", "operator": "and"}}},
+		},
+		{
+			name:      "GIVEN an open range THEN only send the given bound",
+			condition: service.Range{Field: "timestamp", From: "now-15m"},
+			expected:  m{"range": m{"timestamp": m{"gte": "now-15m"}}},
+		},
+		{
+			name:      "GIVEN a phrase THEN use match_phrase",
+			condition: service.Phrase{Field: "message", Text: "disk full"},
+			expected:  m{"match_phrase": m{"message": "disk full"}},
+		},
+		{
+			name:      "GIVEN a wildcard on an exact field THEN match the keyword",
+			condition: service.Wildcard{Field: "source", Pattern: "check*"},
+			expected:  m{"wildcard": m{"source.keyword": m{"value": "check*", "case_insensitive": true}}},
+		},
+		{
+			name:      "GIVEN a wildcard on the message THEN match its words",
+			condition: service.Wildcard{Field: "message", Pattern: "time?out"},
+			expected:  m{"wildcard": m{"message": m{"value": "time?out", "case_insensitive": true}}},
+		},
+		{
+			name:      "GIVEN exclusive bounds THEN use gt and lt",
+			condition: service.Range{Field: "kafka.offset", From: "10", To: "20", FromExclusive: true, ToExclusive: true},
+			expected:  m{"range": m{"kafka.offset": m{"gt": "10", "lt": "20"}}},
+		},
+		{
+			name:      "GIVEN exists THEN use exists",
+			condition: service.Exists{Field: "metadata.trace-id"},
+			expected:  m{"exists": m{"field": "metadata.trace-id"}},
+		},
+		{
+			name: "GIVEN and THEN score matches and filter the rest",
+			condition: service.And{
+				service.Range{Field: "timestamp", To: "now"},
+				service.Match{Field: "message", Text: "timeout"},
+			},
+			expected: m{"bool": m{
+				"filter": []interface{}{m{"range": m{"timestamp": m{"lte": "now"}}}},
+				"must":   []interface{}{m{"match": m{"message": m{"query": "timeout", "operator": "and"}}}},
+			}},
+		},
+		{
+			name:      "GIVEN an empty and THEN match all",
+			condition: service.And{},
+			expected:  m{"match_all": m{}},
+		},
+		{
+			name: "GIVEN or and not THEN use should and must_not",
+			condition: service.Or{
+				service.Term{Field: "level", Values: []string{"ERROR"}},
+				service.Not{Condition: service.Exists{Field: "source"}},
+			},
+			expected: m{"bool": m{
+				"should": []interface{}{
+					m{"term": m{"level.keyword": "ERROR"}},
+					m{"bool": m{"must_not": []interface{}{m{"exists": m{"field": "source"}}}}},
+				},
+				"minimum_should_match": 1,
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translated, err := translateCondition(tt.condition)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, translated)
+		})
+	}
+}
+
+func TestSearchBody(t *testing.T) {
+	t.Run("GIVEN sort and paging WHEN translating THEN build the search body", func(t *testing.T) {
+		body, err := searchBody(service.Query{
+			Where:          service.Term{Field: "source", Values: []string{"api"}},
+			Sort:           []service.SortField{{Field: "timestamp", Desc: true}, {Field: "id"}},
+			Size:           50,
+			SearchAfter:    []interface{}{1718000000001, "1"},
+			TrackTotalHits: true,
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, m{
+			"query": m{"term": m{"source.keyword": "api"}},
+			"size":  50,
+			"sort": []map[string]interface{}{
+				{"timestamp": m{"order": "desc"}},
+				{"id.keyword": m{"order": "asc", "unmapped_type": "keyword"}},
+			},
+			"search_after":     []interface{}{1718000000001, "1"},
+			"track_total_hits": true,
+		}, body)
+	})
+}
+
+func TestAggregationBody(t *testing.T) {
+	t.Run("GIVEN aggregations WHEN translating THEN build a search for the buckets only", func(t *testing.T) {
+		body, err := aggregationBody(service.Term{Field: "source", Values: []string{"api"}}, []service.Aggregation{
+			{Name: "levels", Kind: service.AggregateTerms, Field: "level", Size: 5},
+			{Name: "timeline", Kind: service.AggregateDateHistogram, Field: "timestamp", Interval: "5m"},
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, m{
+			"query":            m{"term": m{"source.keyword": "api"}},
+			"size":             0,
+			"track_total_hits": true,
+			"aggs": m{
+				"levels":   m{"terms": m{"field": "level.keyword", "size": 5}},
+				"timeline": m{"date_histogram": m{"field": "timestamp", "fixed_interval": "5m", "min_doc_count": 0}},
+			},
+		}, body)
+	})
+
+	t.Run("GIVEN an unknown aggregation WHEN translating THEN return an error", func(t *testing.T) {
+		_, err := aggregationBody(nil, []service.Aggregation{{Name: "x", Kind: "avg"}})
+		assert.Error(t, err)
+	})
+}
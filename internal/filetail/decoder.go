@@ -0,0 +1,31 @@
+package filetail
+
+import "time"
+
+// Line is a line of a file once the framing its writer added is removed.
+type Line struct {
+	Text []byte
+	// Partial marks a line the writer split, continued by the next line of
+	// the same Stream.
+	Partial bool
+	// Stream tells apart the writers interleaving their lines in one file,
+	// like the stdout and stderr of a container.
+	Stream string
+	// Time and Attributes come from the framing and complete the log parsed
+	// from the text.
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// Decoder removes the framing from the lines of files whose writer wraps
+// them, like container runtimes do. Lines it cannot decode are returned as
+// they are.
+type Decoder interface {
+	Decode(path string, data []byte) Line
+}
+
+type plainDecoder struct{}
+
+func (plainDecoder) Decode(path string, data []byte) Line {
+	return Line{Text: data}
+}
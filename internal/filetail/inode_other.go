@@ -0,0 +1,11 @@
+//go:build !unix
+
+package filetail
+
+import "os"
+
+// fileKey falls back to the path where inodes are not available, so a
+// renamed file is read again from the start.
+func fileKey(info os.FileInfo, path string) string {
+	return path
+}
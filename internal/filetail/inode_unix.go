@@ -0,0 +1,18 @@
+//go:build unix
+
+package filetail
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file by device and inode, so it is still recognized
+// once rotation has renamed it.
+func fileKey(info os.FileInfo, path string) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+	}
+	return path
+}
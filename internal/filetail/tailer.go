@@ -0,0 +1,479 @@
+package filetail
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	DefaultPollInterval = time.Second
+	DefaultMaxLineBytes = 64 << 10
+)
+
+// LogProcessor is the part of the Kafka processor the tailer feeds, so file
+// lines get the same retries, breaker pauses and WAL spill as messages.
+type LogProcessor interface {
+	ProcessLog(ctx context.Context, logEntry service.Log) error
+}
+
+// Tailer follows the files matching Patterns and processes every line
+// appended to them, with the file path as the source. Read offsets are kept
+// per inode in CheckpointPath and only move past a line once it is stored or
+// rejected for good, so a restart resumes where the last run stopped.
+//
+// Both rotation styles are followed: a file renamed away is drained until a
+// poll finds nothing new in it, and a file truncated in place (copytruncate)
+// is read again from the start.
+type Tailer struct {
+	Patterns       []string
+	CheckpointPath string
+	PollInterval   time.Duration
+	// MaxLineBytes splits longer lines into several logs.
+	MaxLineBytes int
+	// Format forces the parser of every line, empty uses the registry default.
+	Format string
+	// Decoder unwraps the lines before they are parsed, plain text when nil.
+	Decoder   Decoder
+	Processor LogProcessor
+	Parsers   *parser.Registry
+
+	files map[string]*follower
+	saved map[string]fileState
+	// written is the last checkpoint saved, to skip rewriting it unchanged.
+	written []byte
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type follower struct {
+	file     *os.File
+	path     string
+	offset   int64
+	idPrefix string
+	// orphaned is set once no pattern matches the file anymore, because it
+	// was renamed away or deleted.
+	orphaned bool
+
+	// pending assembles the split lines of each stream.
+	pending map[string]*pendingLine
+}
+
+// pendingLine is a split line being assembled: first is its first part and
+// start the offset of it. The checkpoint stays there until the line is
+// complete, so a restart reads every part again.
+type pendingLine struct {
+	text  []byte
+	first Line
+	start int64
+}
+
+// checkpointOffset is where reading resumes after a restart: the start of
+// the earliest line still being assembled, if any.
+func (f *follower) checkpointOffset() int64 {
+	offset := f.offset
+	for _, p := range f.pending {
+		offset = min(offset, p.start)
+	}
+	return offset
+}
+
+type checkpoint struct {
+	Files map[string]fileState `json:"files"`
+}
+
+// fileState is the checkpoint of one file. IDPrefix names the logs of lines
+// without an ID of their own, which are identified by prefix and offset so
+// re-reading a line overwrites the log stored the first time.
+type fileState struct {
+	Path     string `json:"path"`
+	Offset   int64  `json:"offset"`
+	IDPrefix string `json:"id_prefix"`
+}
+
+func NewTailer(patterns []string, checkpointPath string, processor LogProcessor) *Tailer {
+	return &Tailer{
+		Patterns:       patterns,
+		CheckpointPath: checkpointPath,
+		PollInterval:   DefaultPollInterval,
+		MaxLineBytes:   DefaultMaxLineBytes,
+		Processor:      processor,
+		Parsers:        parser.NewRegistry(),
+		files:          make(map[string]*follower),
+	}
+}
+
+// Start loads the checkpoint and polls the patterns in the background until
+// ctx is cancelled or Close is called.
+func (t *Tailer) Start(ctx context.Context) error {
+	for _, pattern := range t.Patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	saved, err := t.loadCheckpoint()
+	if err != nil {
+		return fmt.Errorf("reading checkpoint %s: %w", t.CheckpointPath, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return os.ErrClosed
+	}
+
+	t.saved = saved
+	if t.files == nil {
+		t.files = make(map[string]*follower)
+	}
+	ctx, t.cancel = context.WithCancel(ctx)
+	log.Printf("Tailing files matching %s", strings.Join(t.Patterns, ", "))
+
+	t.wg.Add(1)
+	go t.run(ctx)
+	return nil
+}
+
+// Close stops polling, waits for the line being processed and saves the
+// checkpoint.
+func (t *Tailer) Close() error {
+	t.closeOnce.Do(func() {
+		t.mu.Lock()
+		t.closed = true
+		if t.cancel != nil {
+			t.cancel()
+		}
+		t.mu.Unlock()
+
+		t.wg.Wait()
+		for _, f := range t.files {
+			f.file.Close()
+		}
+	})
+	return nil
+}
+
+func (t *Tailer) run(ctx context.Context) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		t.poll(ctx)
+		if err := t.saveCheckpoint(); err != nil {
+			log.Printf("Error saving file tail checkpoint: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tailer) poll(ctx context.Context) {
+	seen := make(map[string]bool)
+	for _, pattern := range t.Patterns {
+		paths, _ := filepath.Glob(pattern)
+		for _, path := range paths {
+			if key, ok := t.discover(path); ok {
+				seen[key] = true
+			}
+		}
+	}
+
+	followers := make([]string, 0, len(t.files))
+	for key, f := range t.files {
+		f.orphaned = !seen[key]
+		followers = append(followers, key)
+	}
+	// Rotated files go first so their last lines come before the new file's.
+	slices.SortFunc(followers, func(a, b string) int {
+		fa, fb := t.files[a], t.files[b]
+		if fa.orphaned != fb.orphaned {
+			if fa.orphaned {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(fa.path, fb.path)
+	})
+
+	for _, key := range followers {
+		f := t.files[key]
+		lines, err := t.follow(ctx, f, false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Error reading %s: %v", f.path, err)
+			continue
+		}
+
+		if f.orphaned && lines == 0 {
+			// The writer has moved on, a last line without a newline is
+			// complete.
+			if _, err := t.follow(ctx, f, true); ctx.Err() != nil {
+				return
+			} else if err != nil {
+				log.Printf("Error reading %s: %v", f.path, err)
+			}
+			f.file.Close()
+			delete(t.files, key)
+		}
+	}
+}
+
+// discover starts following the file at path unless it already is, and
+// returns its key.
+func (t *Tailer) discover(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	key := fileKey(info, path)
+	if f, ok := t.files[key]; ok {
+		f.path = path
+		return key, true
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Error opening %s: %v", path, err)
+		return "", false
+	}
+	// The path may have been rotated between the stat and the open.
+	if info, err = file.Stat(); err != nil {
+		file.Close()
+		return "", false
+	}
+	key = fileKey(info, path)
+	if _, ok := t.files[key]; ok {
+		file.Close()
+		return key, true
+	}
+
+	f := &follower{file: file, path: path, idPrefix: newIDPrefix()}
+	if state, ok := t.saved[key]; ok {
+		f.offset = state.Offset
+		if state.IDPrefix != "" {
+			f.idPrefix = state.IDPrefix
+		}
+	}
+	t.files[key] = f
+	return key, true
+}
+
+// follow processes the complete lines written past the offset and returns
+// how many were read. A trailing line without a newline waits for the rest
+// of it, unless final is set.
+func (t *Tailer) follow(ctx context.Context, f *follower, final bool) (int, error) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if info.Size() < f.offset {
+		log.Printf("%s was truncated, reading it again from the start", f.path)
+		f.offset = 0
+		f.idPrefix = newIDPrefix()
+		f.pending = nil
+	}
+
+	section := io.NewSectionReader(f.file, f.offset, info.Size()-f.offset)
+	reader := bufio.NewReaderSize(section, t.maxLineBytes())
+
+	lines := 0
+	for {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, io.EOF) && !(final && len(line) > 0) {
+			if final {
+				return lines, t.flushAll(ctx, f)
+			}
+			return lines, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+			return lines, err
+		}
+
+		if err := t.processLine(ctx, f, line); err != nil {
+			return lines, err
+		}
+		f.offset += int64(len(line))
+		lines++
+	}
+}
+
+func (t *Tailer) processLine(ctx context.Context, f *follower, data []byte) error {
+	line := t.decoder().Decode(f.path, bytes.TrimRight(data, "\r\n"))
+
+	p := f.pending[line.Stream]
+	if p == nil {
+		if !line.Partial && len(bytes.TrimSpace(line.Text)) == 0 {
+			return nil
+		}
+		p = &pendingLine{first: line, start: f.offset}
+		if f.pending == nil {
+			f.pending = make(map[string]*pendingLine)
+		}
+		f.pending[line.Stream] = p
+	}
+	p.text = append(p.text, line.Text...)
+
+	if line.Partial && len(p.text) < t.maxLineBytes() {
+		return nil
+	}
+	return t.flush(ctx, f, line.Stream)
+}
+
+// flushAll processes the lines still being assembled, in the order they
+// started.
+func (t *Tailer) flushAll(ctx context.Context, f *follower) error {
+	streams := make([]string, 0, len(f.pending))
+	for stream := range f.pending {
+		streams = append(streams, stream)
+	}
+	slices.SortFunc(streams, func(a, b string) int {
+		return cmp.Compare(f.pending[a].start, f.pending[b].start)
+	})
+
+	for _, stream := range streams {
+		if err := t.flush(ctx, f, stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush processes the line assembled for stream. It is only dropped once
+// processed, so a shutdown midway leaves the checkpoint at its first part.
+func (t *Tailer) flush(ctx context.Context, f *follower, stream string) error {
+	p := f.pending[stream]
+	logEntry := t.Parsers.Parse(bytes.Clone(p.text), parser.Hints{Format: t.Format})
+	logEntry.Source = f.path
+	if logEntry.ID == "" {
+		logEntry.ID = fmt.Sprintf("%s-%d", f.idPrefix, p.start)
+	}
+	if logEntry.Timestamp.IsZero() {
+		logEntry.Timestamp = p.first.Time
+	}
+	for key, value := range p.first.Attributes {
+		if logEntry.Attributes == nil {
+			logEntry.Attributes = make(map[string]interface{})
+		}
+		logEntry.Attributes[key] = value
+	}
+
+	if err := t.Processor.ProcessLog(ctx, logEntry); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Dropping line at %s:%d: %v", f.path, p.start, err)
+	}
+	delete(f.pending, stream)
+	return nil
+}
+
+func (t *Tailer) loadCheckpoint() (map[string]fileState, error) {
+	if t.CheckpointPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(t.CheckpointPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	t.written = data
+	return cp.Files, nil
+}
+
+// saveCheckpoint records the files followed right now; rotated files that
+// were drained are forgotten.
+func (t *Tailer) saveCheckpoint() error {
+	if t.CheckpointPath == "" {
+		return nil
+	}
+
+	cp := checkpoint{Files: make(map[string]fileState, len(t.files))}
+	for key, f := range t.files {
+		cp.Files[key] = fileState{Path: f.path, Offset: f.checkpointOffset(), IDPrefix: f.idPrefix}
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, t.written) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.CheckpointPath), 0o755); err != nil {
+		return err
+	}
+	tmp := t.CheckpointPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, t.CheckpointPath); err != nil {
+		return err
+	}
+	t.written = data
+	return nil
+}
+
+func (t *Tailer) pollInterval() time.Duration {
+	if t.PollInterval <= 0 {
+		return DefaultPollInterval
+	}
+	return t.PollInterval
+}
+
+func (t *Tailer) decoder() Decoder {
+	if t.Decoder == nil {
+		return plainDecoder{}
+	}
+	return t.Decoder
+}
+
+func (t *Tailer) maxLineBytes() int {
+	if t.MaxLineBytes <= 0 {
+		return DefaultMaxLineBytes
+	}
+	return t.MaxLineBytes
+}
+
+func newIDPrefix() string {
+	return strings.ToLower(rand.Text()[:12])
+}
@@ -0,0 +1,250 @@
+package filetail
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	mu   sync.Mutex
+	logs []service.Log
+}
+
+func (p *recordingProcessor) ProcessLog(ctx context.Context, logEntry service.Log) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logs = append(p.logs, logEntry)
+	return nil
+}
+
+func (p *recordingProcessor) Messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	messages := make([]string, len(p.logs))
+	for i, logEntry := range p.logs {
+		messages[i] = logEntry.Message
+	}
+	return messages
+}
+
+func (p *recordingProcessor) Logs() []service.Log {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]service.Log{}, p.logs...)
+}
+
+func startTailer(t *testing.T, dir string, pattern string, options ...func(*Tailer)) (*Tailer, *recordingProcessor) {
+	processor := &recordingProcessor{}
+	tailer := NewTailer([]string{filepath.Join(dir, pattern)}, filepath.Join(dir, "state", "checkpoint.json"), processor)
+	tailer.PollInterval = 10 * time.Millisecond
+	tailer.Format = parser.FormatRaw
+	for _, option := range options {
+		option(tailer)
+	}
+	require.NoError(t, tailer.Start(context.Background()))
+	return tailer, processor
+}
+
+func appendFile(t *testing.T, path, data string) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString(data)
+	require.NoError(t, err)
+}
+
+func expectMessages(t *testing.T, processor *recordingProcessor, expected ...string) {
+	assert.Eventually(t, func() bool { return len(processor.Messages()) >= len(expected) }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, expected, processor.Messages())
+}
+
+func TestTailer_Follow(t *testing.T) {
+	t.Run("GIVEN lines appended to a file WHEN tailing THEN process complete lines with the path as source", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "first\nsecond\r\nthi")
+
+		tailer, processor := startTailer(t, dir, "*.log")
+		defer tailer.Close()
+
+		expectMessages(t, processor, "first", "second")
+
+		appendFile(t, path, "rd\n\n")
+		expectMessages(t, processor, "first", "second", "third")
+
+		logs := processor.Logs()
+		assert.Equal(t, path, logs[0].Source)
+		assert.NotEqual(t, logs[0].ID, logs[1].ID)
+	})
+
+	t.Run("GIVEN a line longer than the limit WHEN tailing THEN split it", func(t *testing.T) {
+		dir := t.TempDir()
+		appendFile(t, filepath.Join(dir, "app.log"), "0123456789abcdefXYZ\n")
+
+		tailer, processor := startTailer(t, dir, "*.log", func(tailer *Tailer) { tailer.MaxLineBytes = 16 })
+		defer tailer.Close()
+
+		expectMessages(t, processor, "0123456789abcdef", "XYZ")
+	})
+}
+
+func TestTailer_Checkpoint(t *testing.T) {
+	t.Run("GIVEN a previous run WHEN restarting THEN resume from the saved offset", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "first\nsecond\n")
+
+		tailer, processor := startTailer(t, dir, "*.log")
+		expectMessages(t, processor, "first", "second")
+		require.NoError(t, tailer.Close())
+		firstIDs := processor.Logs()
+
+		appendFile(t, path, "third\n")
+
+		tailer, processor = startTailer(t, dir, "*.log")
+		defer tailer.Close()
+		expectMessages(t, processor, "third")
+
+		// IDs keep the prefix of the file, so re-read lines would overwrite.
+		assert.Equal(t, firstIDs[0].ID[:12], processor.Logs()[0].ID[:12])
+	})
+
+	t.Run("GIVEN a corrupt checkpoint WHEN starting THEN fail", func(t *testing.T) {
+		dir := t.TempDir()
+		checkpointPath := filepath.Join(dir, "checkpoint.json")
+		require.NoError(t, os.WriteFile(checkpointPath, []byte("{"), 0o644))
+
+		tailer := NewTailer([]string{filepath.Join(dir, "*.log")}, checkpointPath, &recordingProcessor{})
+
+		assert.Error(t, tailer.Start(context.Background()))
+	})
+}
+
+func TestTailer_Rotation(t *testing.T) {
+	t.Run("GIVEN a file truncated in place WHEN tailing THEN read it again from the start", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "first line\nsecond line\n")
+
+		tailer, processor := startTailer(t, dir, "*.log")
+		defer tailer.Close()
+		expectMessages(t, processor, "first line", "second line")
+
+		require.NoError(t, os.Truncate(path, 0))
+		appendFile(t, path, "third\n")
+
+		expectMessages(t, processor, "first line", "second line", "third")
+		logs := processor.Logs()
+		assert.NotEqual(t, logs[0].ID, logs[2].ID)
+	})
+
+	t.Run("GIVEN a file renamed away WHEN tailing THEN drain it before following the new one", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "first\n")
+
+		tailer, processor := startTailer(t, dir, "app.log")
+		defer tailer.Close()
+		expectMessages(t, processor, "first")
+
+		writer, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+		require.NoError(t, err)
+		require.NoError(t, os.Rename(path, filepath.Join(dir, "app.log.1")))
+		_, err = writer.WriteString("second\nlast")
+		require.NoError(t, err)
+		writer.Close()
+		appendFile(t, path, "new\n")
+
+		assert.Eventually(t, func() bool { return len(processor.Messages()) == 4 }, 2*time.Second, 10*time.Millisecond)
+		assert.ElementsMatch(t, []string{"first", "second", "last", "new"}, processor.Messages())
+		for _, logEntry := range processor.Logs() {
+			assert.Equal(t, path, logEntry.Source)
+		}
+	})
+}
+
+// continuationDecoder treats a trailing backslash as a split line, and a
+// line starting with "2:" as written to a second stream.
+type continuationDecoder struct{}
+
+func (continuationDecoder) Decode(path string, data []byte) Line {
+	text, partial := bytes.CutSuffix(data, []byte(`\`))
+	text, stderr := bytes.CutPrefix(text, []byte("2:"))
+	line := Line{Text: text, Partial: partial, Attributes: map[string]interface{}{"decoded": true}}
+	if stderr {
+		line.Stream = "stderr"
+	}
+	return line
+}
+
+func withContinuation(tailer *Tailer) {
+	tailer.Decoder = continuationDecoder{}
+}
+
+func TestTailer_Decoder(t *testing.T) {
+	t.Run("GIVEN split lines WHEN tailing THEN reassemble them", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "one\\\n two\\\n three\nfour\n")
+
+		tailer, processor := startTailer(t, dir, "*.log", withContinuation)
+		defer tailer.Close()
+
+		expectMessages(t, processor, "one two three", "four")
+		assert.Equal(t, true, processor.Logs()[0].Attributes["decoded"])
+	})
+
+	t.Run("GIVEN split lines of two streams interleaved WHEN tailing THEN reassemble each stream apart", func(t *testing.T) {
+		dir := t.TempDir()
+		appendFile(t, filepath.Join(dir, "app.log"), "one\\\n2:uno\\\n2: dos\n two\n")
+
+		tailer, processor := startTailer(t, dir, "*.log", withContinuation)
+		defer tailer.Close()
+
+		expectMessages(t, processor, "uno dos", "one two")
+	})
+
+	t.Run("GIVEN a restart with a line of another stream completed after a split one WHEN resuming THEN read from the split line", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "one\\\n2:uno\n")
+
+		tailer, processor := startTailer(t, dir, "*.log", withContinuation)
+		expectMessages(t, processor, "uno")
+		require.NoError(t, tailer.Close())
+
+		appendFile(t, path, " two\n")
+
+		tailer, processor = startTailer(t, dir, "*.log", withContinuation)
+		defer tailer.Close()
+		// The line of the other stream is read again, under the same ID.
+		expectMessages(t, processor, "uno", "one two")
+	})
+
+	t.Run("GIVEN a restart before the last part WHEN resuming THEN read every part again", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		appendFile(t, path, "done\none\\\n")
+
+		tailer, processor := startTailer(t, dir, "*.log", withContinuation)
+		expectMessages(t, processor, "done")
+		require.NoError(t, tailer.Close())
+
+		appendFile(t, path, " two\n")
+
+		tailer, processor = startTailer(t, dir, "*.log", withContinuation)
+		defer tailer.Close()
+		expectMessages(t, processor, "one two")
+	})
+}
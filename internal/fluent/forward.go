@@ -0,0 +1,319 @@
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// eventTimeExt is the msgpack extension type of the Forward EventTime:
+// seconds and nanoseconds as two big-endian uint32.
+const eventTimeExt = 0
+
+// maxUnpackedBytes bounds what the entries of a CompressedPackedForward
+// message may inflate to.
+var maxUnpackedBytes int64 = 64 << 20
+
+// Entry is one record of a Forward message.
+type Entry struct {
+	Time   time.Time
+	Record map[string]interface{}
+}
+
+// Message is one Forward protocol message, whatever the mode it was sent
+// in: Message carries one entry, Forward an array of them and
+// (Compressed)PackedForward a msgpack stream, optionally gzipped.
+type Message struct {
+	Tag     string
+	Entries []Entry
+	// Chunk is set when the client asks for an ack.
+	Chunk string
+}
+
+// ReadMessage decodes the next message from the connection.
+func ReadMessage(dec *msgpack.Decoder) (Message, error) {
+	var msg Message
+
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return msg, err
+	}
+	if n < 2 || n > 4 {
+		return msg, fmt.Errorf("forward message has %d elements, expected 2 to 4", n)
+	}
+
+	if msg.Tag, err = dec.DecodeString(); err != nil {
+		return msg, fmt.Errorf("invalid tag: %w", err)
+	}
+
+	code, err := dec.PeekCode()
+	if err != nil {
+		return msg, err
+	}
+
+	var packed []byte
+	rest := n - 2
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		if msg.Entries, err = readEntries(dec); err != nil {
+			return msg, err
+		}
+	case msgpcode.IsBin(code) || msgpcode.IsString(code):
+		if packed, err = dec.DecodeBytes(); err != nil {
+			return msg, err
+		}
+	default:
+		if rest < 1 {
+			return msg, errors.New("forward message without record")
+		}
+		entry, err := readEntry(dec, false)
+		if err != nil {
+			return msg, err
+		}
+		msg.Entries = []Entry{entry}
+		rest--
+	}
+
+	var options map[string]interface{}
+	for ; rest > 0; rest-- {
+		if options, err = dec.DecodeMap(); err != nil {
+			return msg, fmt.Errorf("invalid option: %w", err)
+		}
+	}
+	msg.Chunk, _ = options["chunk"].(string)
+
+	if packed != nil {
+		compressed, _ := options["compressed"].(string)
+		if msg.Entries, err = readPacked(packed, compressed); err != nil {
+			return msg, err
+		}
+	}
+
+	return msg, nil
+}
+
+func readEntries(dec *msgpack.Decoder) ([]Entry, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+
+	// The length comes from the client, so entries grow as they are read
+	// rather than being allocated up front.
+	var entries []Entry
+	for i := 0; i < n; i++ {
+		entry, err := readEntry(dec, true)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func readPacked(data []byte, compressed string) ([]Entry, error) {
+	var reader io.Reader = bytes.NewReader(data)
+	switch compressed {
+	case "":
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = &limitedReader{reader: gz, remaining: maxUnpackedBytes}
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compressed)
+	}
+
+	dec := newDecoder(reader)
+	var entries []Entry
+	for {
+		entry, err := readEntry(dec, true)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// limitedReader fails once more than remaining bytes are read, where an
+// io.LimitReader would end the stream quietly.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, fmt.Errorf("packed entries exceed %d bytes", maxUnpackedBytes)
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// readEntry reads [time, record], or only time and record when they are
+// inlined in a Message mode event.
+func readEntry(dec *msgpack.Decoder, wrapped bool) (Entry, error) {
+	var entry Entry
+
+	if wrapped {
+		n, err := dec.DecodeArrayLen()
+		if err != nil {
+			return entry, err
+		}
+		if n != 2 {
+			return entry, fmt.Errorf("forward entry has %d elements, expected 2", n)
+		}
+	}
+
+	ts, err := readTime(dec)
+	if err != nil {
+		return entry, err
+	}
+	entry.Time = ts
+
+	record, err := dec.DecodeMap()
+	if err != nil {
+		return entry, fmt.Errorf("invalid record: %w", err)
+	}
+	entry.Record = record
+	return entry, nil
+}
+
+// readTime accepts the EventTime extension as well as plain epoch seconds.
+func readTime(dec *msgpack.Decoder) (time.Time, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if msgpcode.IsFixedExt(code) || msgpcode.IsExt(code) {
+		extID, extLen, err := dec.DecodeExtHeader()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if extID != eventTimeExt || extLen != 8 {
+			return time.Time{}, fmt.Errorf("unsupported time extension %d of %d bytes", extID, extLen)
+		}
+		buf := make([]byte, 8)
+		if err := dec.ReadFull(buf); err != nil {
+			return time.Time{}, err
+		}
+		sec, nsec := binary.BigEndian.Uint32(buf[:4]), binary.BigEndian.Uint32(buf[4:])
+		return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+	}
+
+	value, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return time.Time{}, err
+	}
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	case uint64:
+		return time.Unix(int64(v), 0).UTC(), nil
+	case float64:
+		return time.Unix(0, int64(v*float64(time.Second))).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid event time %v", value)
+	}
+}
+
+// Log maps a record onto a log: the first of message, log and msg becomes
+// the message, level or severity the level, and the tag the source. Every
+// other key is kept as an attribute. Records without an id get one derived
+// from their content, so a chunk the client resends overwrites what was
+// stored.
+func Log(tag string, entry Entry) service.Log {
+	logEntry := service.Log{
+		Source:    tag,
+		Timestamp: entry.Time,
+	}
+
+	used := make(map[string]bool)
+	pick := func(keys ...string) string {
+		for _, key := range keys {
+			if value, ok := entry.Record[key]; ok && value != nil {
+				used[key] = true
+				return fmt.Sprint(plain(value))
+			}
+		}
+		return ""
+	}
+	logEntry.ID = pick("id")
+	logEntry.Message = pick("message", "log", "msg")
+	logEntry.Level = pick("level", "severity")
+
+	for key, value := range entry.Record {
+		if !used[key] {
+			setAttribute(&logEntry, key, plain(value))
+		}
+	}
+
+	if logEntry.ID == "" {
+		logEntry.ID = entryID(tag, entry)
+	}
+	return logEntry
+}
+
+func entryID(tag string, entry Entry) string {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	enc.Encode([]interface{}{tag, entry.Time.UnixNano(), entry.Record})
+
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:16])
+}
+
+// plain turns the raw byte strings some shippers send into text, so the
+// record encodes as JSON the way it was meant to be read.
+func plain(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = plain(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = plain(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func setAttribute(logEntry *service.Log, key string, value interface{}) {
+	if logEntry.Attributes == nil {
+		logEntry.Attributes = make(map[string]interface{})
+	}
+	logEntry.Attributes[key] = value
+}
+
+func newDecoder(reader io.Reader) *msgpack.Decoder {
+	dec := msgpack.NewDecoder(reader)
+	dec.UseLooseInterfaceDecoding(true)
+	return dec
+}
@@ -0,0 +1,155 @@
+package fluent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var eventTS = time.Date(2024, 6, 10, 10, 0, 0, 123456789, time.UTC)
+
+// eventTime encodes t as the EventTime extension.
+func eventTime(t time.Time) msgpack.RawMessage {
+	raw := []byte{0xd7, eventTimeExt, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(raw[2:6], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(raw[6:], uint32(t.Nanosecond()))
+	return raw
+}
+
+func encode(t *testing.T, values ...interface{}) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, value := range values {
+		require.NoError(t, enc.Encode(value))
+	}
+	return buf.Bytes()
+}
+
+func readMessage(t *testing.T, data []byte) Message {
+	msg, err := ReadMessage(newDecoder(bytes.NewReader(data)))
+	require.NoError(t, err)
+	return msg
+}
+
+func TestReadMessage(t *testing.T) {
+	record := map[string]interface{}{"log": "hello", "stream": "stdout"}
+
+	t.Run("GIVEN Message mode WHEN reading THEN return its single entry", func(t *testing.T) {
+		msg := readMessage(t, encode(t, []interface{}{"app.web", eventTime(eventTS), record}))
+
+		assert.Equal(t, "app.web", msg.Tag)
+		require.Len(t, msg.Entries, 1)
+		assert.Equal(t, eventTS, msg.Entries[0].Time)
+		assert.Equal(t, "hello", msg.Entries[0].Record["log"])
+		assert.Empty(t, msg.Chunk)
+	})
+
+	t.Run("GIVEN Message mode with epoch seconds and a chunk WHEN reading THEN keep the chunk", func(t *testing.T) {
+		msg := readMessage(t, encode(t, []interface{}{"app.web", 1718013600, record, map[string]interface{}{"chunk": "c1"}}))
+
+		assert.Equal(t, time.Unix(1718013600, 0).UTC(), msg.Entries[0].Time)
+		assert.Equal(t, "c1", msg.Chunk)
+	})
+
+	t.Run("GIVEN Forward mode WHEN reading THEN return every entry", func(t *testing.T) {
+		entries := []interface{}{
+			[]interface{}{eventTime(eventTS), record},
+			[]interface{}{eventTime(eventTS.Add(time.Second)), map[string]interface{}{"log": "bye"}},
+		}
+
+		msg := readMessage(t, encode(t, []interface{}{"app.web", entries, map[string]interface{}{"chunk": "c2", "size": 2}}))
+
+		require.Len(t, msg.Entries, 2)
+		assert.Equal(t, "bye", msg.Entries[1].Record["log"])
+		assert.Equal(t, "c2", msg.Chunk)
+	})
+
+	t.Run("GIVEN PackedForward mode WHEN reading THEN decode the packed stream", func(t *testing.T) {
+		packed := encode(t,
+			[]interface{}{eventTime(eventTS), record},
+			[]interface{}{eventTime(eventTS), map[string]interface{}{"log": "second"}},
+		)
+
+		msg := readMessage(t, encode(t, []interface{}{"app.web", packed}))
+
+		require.Len(t, msg.Entries, 2)
+		assert.Equal(t, "second", msg.Entries[1].Record["log"])
+	})
+
+	t.Run("GIVEN CompressedPackedForward mode WHEN reading THEN gunzip the packed stream", func(t *testing.T) {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(encode(t, []interface{}{eventTime(eventTS), record}))
+		gz.Close()
+
+		msg := readMessage(t, encode(t, []interface{}{"app.web", compressed.Bytes(), map[string]interface{}{"compressed": "gzip", "chunk": "c3"}}))
+
+		require.Len(t, msg.Entries, 1)
+		assert.Equal(t, "hello", msg.Entries[0].Record["log"])
+		assert.Equal(t, "c3", msg.Chunk)
+	})
+
+	t.Run("GIVEN a Forward mode array claiming billions of entries WHEN reading THEN fail without allocating them", func(t *testing.T) {
+		_, err := ReadMessage(newDecoder(bytes.NewReader([]byte{0x92, 0xa1, 0x74, 0xdd, 0xff, 0xff, 0xff, 0xff})))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("GIVEN compressed entries inflating past the limit WHEN reading THEN fail", func(t *testing.T) {
+		defer func(limit int64) { maxUnpackedBytes = limit }(maxUnpackedBytes)
+		maxUnpackedBytes = 64
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		for i := 0; i < 10; i++ {
+			gz.Write(encode(t, []interface{}{eventTime(eventTS), record}))
+		}
+		gz.Close()
+
+		_, err := ReadMessage(newDecoder(bytes.NewReader(encode(t, []interface{}{"app.web", compressed.Bytes(), map[string]interface{}{"compressed": "gzip"}}))))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("GIVEN a message that is not an array WHEN reading THEN fail", func(t *testing.T) {
+		_, err := ReadMessage(newDecoder(bytes.NewReader(encode(t, "oops"))))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestLog(t *testing.T) {
+	t.Run("GIVEN a record WHEN mapping THEN use the tag as source and keep other keys", func(t *testing.T) {
+		entry := Entry{Time: eventTS, Record: map[string]interface{}{
+			"log":        []byte("payment failed"),
+			"message":    "structured message",
+			"level":      "error",
+			"kubernetes": map[string]interface{}{"pod_name": []byte("web-1")},
+		}}
+
+		logEntry := Log("kube.payments", entry)
+
+		assert.Equal(t, "kube.payments", logEntry.Source)
+		assert.Equal(t, "structured message", logEntry.Message)
+		assert.Equal(t, "error", logEntry.Level)
+		assert.Equal(t, eventTS, logEntry.Timestamp)
+		assert.Equal(t, "payment failed", logEntry.Attributes["log"])
+		assert.Equal(t, map[string]interface{}{"pod_name": "web-1"}, logEntry.Attributes["kubernetes"])
+		assert.Len(t, logEntry.ID, 32)
+		assert.Equal(t, logEntry.ID, Log("kube.payments", entry).ID, "must derive the same ID for a resent record")
+	})
+
+	t.Run("GIVEN a record with an id WHEN mapping THEN keep it", func(t *testing.T) {
+		logEntry := Log("app", Entry{Record: map[string]interface{}{"id": "abc", "msg": "hi"}})
+
+		assert.Equal(t, "abc", logEntry.ID)
+		assert.Equal(t, "hi", logEntry.Message)
+		assert.Nil(t, logEntry.Attributes)
+	})
+}
@@ -0,0 +1,193 @@
+package fluent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const DefaultDrainTimeout = time.Second
+
+// LogProcessor is the part of the Kafka processor the server feeds, so
+// records get the same retries, breaker pauses and WAL spill as Kafka
+// messages, and chunks are only acked once stored or spilled.
+type LogProcessor interface {
+	ProcessBatch(ctx context.Context, logEntries []service.Log) []error
+}
+
+// Server is a Fluent Forward input, the protocol Fluent Bit and Fluentd use
+// between nodes. Each message is processed as one batch; when the client
+// asks for an ack it is sent once the batch is stored, so a failed chunk is
+// resent by the client.
+type Server struct {
+	Addr string
+	// DrainTimeout is how long open connections may keep delivering the
+	// messages already on their way once Close is called.
+	DrainTimeout time.Duration
+	Processor    LogProcessor
+
+	mu        sync.Mutex
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewServer(addr string, processor LogProcessor) *Server {
+	return &Server{
+		Addr:         addr,
+		DrainTimeout: DefaultDrainTimeout,
+		Processor:    processor,
+		conns:        make(map[net.Conn]struct{}),
+	}
+}
+
+// Start binds Addr and serves it in the background. Messages already
+// received are still processed after ctx is cancelled; Close is what stops
+// the server.
+func (s *Server) Start(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return net.ErrClosed
+	}
+
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("listening on tcp %s: %w", s.Addr, err)
+	}
+	s.listener = listener
+	log.Printf("Fluent forward listening on tcp %s", listener.Addr())
+
+	s.wg.Add(1)
+	go s.serve(ctx, listener)
+	return nil
+}
+
+// LocalAddr reports the bound address, useful when the configured port is 0.
+func (s *Server) LocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops accepting connections and drains the open ones: each one
+// keeps reading for up to DrainTimeout, then the call returns once every
+// message read has been processed.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		if s.listener != nil {
+			s.listener.Close()
+		}
+		deadline := time.Now().Add(s.DrainTimeout)
+		for conn := range s.conns {
+			conn.SetReadDeadline(deadline)
+		}
+		s.mu.Unlock()
+
+		s.wg.Wait()
+	})
+	return nil
+}
+
+func (s *Server) serve(ctx context.Context, listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Fluent forward accept error: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(ctx, conn)
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	dec := newDecoder(bufio.NewReader(conn))
+	enc := msgpack.NewEncoder(conn)
+
+	for {
+		msg, err := ReadMessage(dec)
+		if err != nil {
+			var netErr net.Error
+			if !errors.Is(err, io.EOF) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+				log.Printf("Fluent forward connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		if !s.handleMessage(ctx, msg) || msg.Chunk == "" {
+			continue
+		}
+		if err := enc.Encode(map[string]string{"ack": msg.Chunk}); err != nil {
+			log.Printf("Error acking fluent forward chunk to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handleMessage reports whether the message can be acked: every entry was
+// stored, or refused for good as an invalid log.
+func (s *Server) handleMessage(ctx context.Context, msg Message) bool {
+	if len(msg.Entries) == 0 {
+		return true
+	}
+
+	logEntries := make([]service.Log, len(msg.Entries))
+	for i, entry := range msg.Entries {
+		logEntries[i] = Log(msg.Tag, entry)
+	}
+
+	ok := true
+	for i, err := range s.Processor.ProcessBatch(ctx, logEntries) {
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, service.ErrInvalidLog) {
+			log.Printf("Dropping invalid fluent forward record %s: %v", logEntries[i].ID, err)
+			continue
+		}
+		log.Printf("Error processing fluent forward record %s: %v", logEntries[i].ID, err)
+		ok = false
+	}
+	return ok
+}
@@ -0,0 +1,106 @@
+package fluent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type recordingProcessor struct {
+	mu   sync.Mutex
+	logs []service.Log
+	Err  error
+}
+
+func (p *recordingProcessor) ProcessBatch(ctx context.Context, logEntries []service.Log) []error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := make([]error, len(logEntries))
+	for i, logEntry := range logEntries {
+		if p.Err != nil {
+			errs[i] = p.Err
+			continue
+		}
+		p.logs = append(p.logs, logEntry)
+	}
+	return errs
+}
+
+func (p *recordingProcessor) Logs() []service.Log {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]service.Log{}, p.logs...)
+}
+
+func startServer(t *testing.T, processor *recordingProcessor) *Server {
+	server := NewServer("127.0.0.1:0", processor)
+	server.DrainTimeout = 100 * time.Millisecond
+	require.NoError(t, server.Start(context.Background()))
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func TestServer(t *testing.T) {
+	t.Run("GIVEN a chunk WHEN it is stored THEN ack it", func(t *testing.T) {
+		processor := &recordingProcessor{}
+		server := startServer(t, processor)
+
+		conn, err := net.Dial("tcp", server.LocalAddr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		entries := []interface{}{[]interface{}{eventTime(eventTS), map[string]interface{}{"log": "hello"}}}
+		require.NoError(t, msgpack.NewEncoder(conn).Encode([]interface{}{"app.web", entries, map[string]interface{}{"chunk": "abc"}}))
+
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var ack map[string]string
+		require.NoError(t, msgpack.NewDecoder(conn).Decode(&ack))
+
+		assert.Equal(t, map[string]string{"ack": "abc"}, ack)
+		require.Len(t, processor.Logs(), 1)
+		assert.Equal(t, "app.web", processor.Logs()[0].Source)
+		assert.Equal(t, "hello", processor.Logs()[0].Message)
+	})
+
+	t.Run("GIVEN a chunk WHEN storing fails THEN do not ack it", func(t *testing.T) {
+		processor := &recordingProcessor{Err: errors.New("ES down")}
+		server := startServer(t, processor)
+
+		conn, err := net.Dial("tcp", server.LocalAddr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, msgpack.NewEncoder(conn).Encode([]interface{}{"app.web", 1718013600, map[string]interface{}{"log": "hello"}, map[string]interface{}{"chunk": "abc"}}))
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		var ack map[string]string
+		err = msgpack.NewDecoder(conn).Decode(&ack)
+
+		var netErr net.Error
+		assert.True(t, errors.As(err, &netErr) && netErr.Timeout(), "must not ack, got %v", err)
+	})
+
+	t.Run("GIVEN an open connection WHEN the server closes THEN drain it", func(t *testing.T) {
+		processor := &recordingProcessor{}
+		server := startServer(t, processor)
+
+		conn, err := net.Dial("tcp", server.LocalAddr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		enc := msgpack.NewEncoder(conn)
+		for i := 0; i < 20; i++ {
+			require.NoError(t, enc.Encode([]interface{}{"app.web", eventTime(eventTS.Add(time.Duration(i))), map[string]interface{}{"log": "line"}}))
+		}
+
+		assert.NoError(t, server.Close())
+
+		assert.Len(t, processor.Logs(), 20)
+	})
+}
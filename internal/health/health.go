@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc reports whether a component is healthy, returning the reason
+// when it is not.
+type CheckFunc func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs the registered checks concurrently, each bounded by Timeout.
+// The report is up only when every component is.
+type Checker struct {
+	Timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{Timeout: timeout}
+}
+
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	results := make([]ComponentStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, chk.fn)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(checks))}
+	for i, chk := range checks {
+		report.Components[chk.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run waits for the check or the deadline, whichever comes first, so a check
+// ignoring its context cannot hold the probe.
+func run(ctx context.Context, fn CheckFunc) ComponentStatus {
+	errCh := make(chan error, 1)
+	go func() { errCh <- fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return ComponentStatus{Status: StatusDown, Error: err.Error()}
+	}
+	return ComponentStatus{Status: StatusUp}
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Check(t *testing.T) {
+	t.Run("GIVEN healthy components WHEN checking THEN report up", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Register("kafka", func(ctx context.Context) error { return nil })
+		checker.Register("elasticsearch", func(ctx context.Context) error { return nil })
+
+		report := checker.Check(context.Background())
+
+		assert.Equal(t, StatusUp, report.Status)
+		assert.Equal(t, ComponentStatus{Status: StatusUp}, report.Components["kafka"])
+		assert.Equal(t, ComponentStatus{Status: StatusUp}, report.Components["elasticsearch"])
+	})
+
+	t.Run("GIVEN a failing component WHEN checking THEN report down with its error", func(t *testing.T) {
+		checker := NewChecker(time.Second)
+		checker.Register("kafka", func(ctx context.Context) error { return nil })
+		checker.Register("elasticsearch", func(ctx context.Context) error { return errors.New("connection refused") })
+
+		report := checker.Check(context.Background())
+
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusUp, report.Components["kafka"].Status)
+		assert.Equal(t, ComponentStatus{Status: StatusDown, Error: "connection refused"}, report.Components["elasticsearch"])
+	})
+
+	t.Run("GIVEN a hanging check WHEN checking THEN report it down after the timeout", func(t *testing.T) {
+		checker := NewChecker(20 * time.Millisecond)
+		block := make(chan struct{})
+		defer close(block)
+		checker.Register("kafka", func(ctx context.Context) error {
+			<-block
+			return nil
+		})
+
+		start := time.Now()
+		report := checker.Check(context.Background())
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["kafka"].Error)
+	})
+}
@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"time"
+)
+
+// BatchConfig enables the bulk indexing path when MaxDocs is greater than
+// zero. A batch is flushed as soon as any of the limits is reached.
+type BatchConfig struct {
+	MaxDocs    int
+	MaxBytes   int
+	MaxLatency time.Duration
+}
+
+func (c BatchConfig) Enabled() bool {
+	return c.MaxDocs > 0
+}
+
+// runBatcher groups the events received on in and emits them on out. It
+// flushes whatever is pending and closes out once in is closed.
+func runBatcher(cfg BatchConfig, in <-chan event, out chan<- []event) {
+	defer close(out)
+
+	var (
+		batch []event
+		size  int
+		timer *time.Timer
+		timeC <-chan time.Time
+	)
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeC = nil, nil
+		}
+		if len(batch) == 0 {
+			return
+		}
+		out <- batch
+		batch, size = nil, 0
+	}
+
+	for {
+		select {
+		case e, ok := <-in:
+			if !ok {
+				flush()
+				return
+			}
+
+			batch = append(batch, e)
+			size += e.size()
+			if len(batch) == 1 && cfg.MaxLatency > 0 {
+				timer = time.NewTimer(cfg.MaxLatency)
+				timeC = timer.C
+			}
+
+			if len(batch) >= cfg.MaxDocs || (cfg.MaxBytes > 0 && size >= cfg.MaxBytes) {
+				flush()
+			}
+		case <-timeC:
+			timer, timeC = nil, nil
+			flush()
+		}
+	}
+}
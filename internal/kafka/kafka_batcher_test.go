@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBatcher(t *testing.T) {
+	t.Run("GIVEN max docs reached WHEN batching THEN flush immediately", func(t *testing.T) {
+		in := make(chan event)
+		out := make(chan []event, 10)
+		go runBatcher(BatchConfig{MaxDocs: 2, MaxLatency: time.Hour}, in, out)
+
+		in <- newEvent(kafka.Message{Value: []byte("a")})
+		in <- newEvent(kafka.Message{Value: []byte("b")})
+		in <- newEvent(kafka.Message{Value: []byte("c")})
+
+		assert.Len(t, <-out, 2)
+
+		close(in)
+		assert.Len(t, <-out, 1, "must flush the remainder on close")
+		_, open := <-out
+		assert.False(t, open)
+	})
+
+	t.Run("GIVEN max bytes reached WHEN batching THEN flush immediately", func(t *testing.T) {
+		in := make(chan event)
+		out := make(chan []event, 10)
+		go runBatcher(BatchConfig{MaxDocs: 100, MaxBytes: 5, MaxLatency: time.Hour}, in, out)
+
+		in <- newEvent(kafka.Message{Value: []byte("abc")})
+		in <- newEvent(kafka.Message{Value: []byte("def")})
+
+		assert.Len(t, <-out, 2)
+		close(in)
+	})
+
+	t.Run("GIVEN max latency elapsed WHEN batching THEN flush partial batch", func(t *testing.T) {
+		in := make(chan event)
+		out := make(chan []event, 10)
+		go runBatcher(BatchConfig{MaxDocs: 100, MaxLatency: 20 * time.Millisecond}, in, out)
+
+		in <- newEvent(kafka.Message{Value: []byte("a")})
+
+		select {
+		case batch := <-out:
+			assert.Len(t, batch, 1)
+		case <-time.After(time.Second):
+			t.Fatal("timeout: batch was not flushed")
+		}
+		close(in)
+	})
+}
@@ -2,19 +2,39 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+var ErrNotGroupMember = errors.New("consumer is not a member of its group")
+
 type KafkaConsumer struct {
-	Reader *kafka.Reader
-	Topic  string
+	Reader  *kafka.Reader
+	Topic   string
+	GroupID string
+
+	// ClientID identifies this consumer among the group members.
+	ClientID string
+
+	admin *kafka.Client
 }
 
 func NewKafkaConsumer(brokers []string, topic string, groupID string) *KafkaConsumer {
+	clientID := consumerClientID()
+
 	return &KafkaConsumer{
-		Topic: topic,
+		Topic:    topic,
+		GroupID:  groupID,
+		ClientID: clientID,
+		admin: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   5 * time.Second,
+			Transport: &kafka.Transport{ClientID: clientID},
+		},
 		Reader: kafka.NewReader(kafka.ReaderConfig{
 			Brokers:     brokers,
 			GroupID:     groupID,
@@ -23,18 +43,60 @@ func NewKafkaConsumer(brokers []string, topic string, groupID string) *KafkaCons
 			MinBytes:    10e3, // 10KB
 			MaxBytes:    10e6, // 10MB
 			MaxWait:     500 * time.Millisecond,
+			Dialer: &kafka.Dialer{
+				ClientID:  clientID,
+				Timeout:   10 * time.Second,
+				DualStack: true,
+			},
 		}),
 	}
 }
 
+func consumerClientID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("log-processor-%s-%d", host, os.Getpid())
+}
+
+// ReadMessage fetches the next message without committing it; offsets are
+// committed by the processor through CommitMessage once handled.
 func (c *KafkaConsumer) ReadMessage(ctx context.Context) (kafka.Message, error) {
-	return c.Reader.ReadMessage(ctx)
+	return c.Reader.FetchMessage(ctx)
 }
 
 func (c *KafkaConsumer) CommitMessage(msg kafka.Message) error {
 	return c.Reader.CommitMessages(context.Background(), msg)
 }
 
+// GroupMembership asks the group coordinator whether this consumer has
+// joined its group. A member may hold no partitions when the group has more
+// members than the topic has partitions, which still counts as joined.
+func (c *KafkaConsumer) GroupMembership(ctx context.Context) error {
+	res, err := c.admin.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{c.GroupID}})
+	if err != nil {
+		return err
+	}
+
+	for _, group := range res.Groups {
+		if group.Error != nil {
+			return group.Error
+		}
+		if group.GroupID != c.GroupID {
+			continue
+		}
+		for _, member := range group.Members {
+			if member.ClientID == c.ClientID {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w %s (state %s)", ErrNotGroupMember, c.GroupID, group.GroupState)
+	}
+
+	return fmt.Errorf("%w %s", ErrNotGroupMember, c.GroupID)
+}
+
 func (c *KafkaConsumer) Close() error {
 	return c.Reader.Close()
 }
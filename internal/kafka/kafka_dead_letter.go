@@ -0,0 +1,61 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderDeadLetterError     = "x-dead-letter-error"
+	HeaderDeadLetterAttempts  = "x-dead-letter-attempts"
+	HeaderDeadLetterTopic     = "x-dead-letter-source-topic"
+	HeaderDeadLetterPartition = "x-dead-letter-source-partition"
+	HeaderDeadLetterOffset    = "x-dead-letter-source-offset"
+	HeaderDeadLetterFailedAt  = "x-dead-letter-failed-at"
+)
+
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+func NewKafkaWriter(brokers []string, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.LeastBytes{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+}
+
+// newDeadLetterMessage keeps the original key, value and headers and appends
+// the failure details so the record can be inspected or replayed later.
+func newDeadLetterMessage(m kafka.Message, cause error, attempts int, failedAt time.Time) kafka.Message {
+	headers := make([]kafka.Header, 0, len(m.Headers)+6)
+	headers = append(headers, m.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(errorText(cause))},
+		kafka.Header{Key: HeaderDeadLetterAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderDeadLetterTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderDeadLetterPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderDeadLetterOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderDeadLetterFailedAt, Value: []byte(failedAt.UTC().Format(time.RFC3339Nano))},
+	)
+
+	return kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return "unknown error"
+	}
+	return err.Error()
+}
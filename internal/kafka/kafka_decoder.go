@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderLogFormat   = "log-format"
+	HeaderContentType = "content-type"
+)
+
+// decodeMessage parses a Kafka message with the parser picked from its
+// headers or topic. Logs without an ID take the message key, or a stable ID
+// built from the record coordinates so redeliveries overwrite the same
+// document.
+func decodeMessage(parsers *parser.Registry, m kafka.Message) service.Log {
+	logEntry := parsers.Parse(m.Value, parser.Hints{
+		Format:      headerValue(m.Headers, HeaderLogFormat),
+		ContentType: headerValue(m.Headers, HeaderContentType),
+		Topic:       m.Topic,
+	})
+
+	if logEntry.ID == "" {
+		logEntry.ID = string(m.Key)
+	}
+	if logEntry.ID == "" {
+		logEntry.ID = fmt.Sprintf("%s-%d-%d", m.Topic, m.Partition, m.Offset)
+	}
+
+	return logEntry
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
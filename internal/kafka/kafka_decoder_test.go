@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeMessage(t *testing.T) {
+	parsers := parser.NewRegistry()
+	assert.NoError(t, parsers.SetTopicFormat("edge-logs", parser.FormatAccessLog))
+
+	t.Run("GIVEN structured JSON WHEN decode THEN map fields", func(t *testing.T) {
+		msg := kafka.Message{Key: []byte("key-1"), Value: []byte(`{"id":"20240101-1","level":"ERROR","message":"boom"}`)}
+
+		logEntry := decodeMessage(parsers, msg)
+
+		assert.Equal(t, "20240101-1", logEntry.ID)
+		assert.Equal(t, "ERROR", logEntry.Level)
+		assert.Equal(t, "boom", logEntry.Message)
+	})
+
+	t.Run("GIVEN JSON without id WHEN decode THEN use message key", func(t *testing.T) {
+		msg := kafka.Message{Key: []byte("key-1"), Value: []byte(`{"level":"INFO","message":"hello"}`)}
+
+		logEntry := decodeMessage(parsers, msg)
+
+		assert.Equal(t, "key-1", logEntry.ID)
+	})
+
+	t.Run("GIVEN no id nor key WHEN decode THEN build id from record coordinates", func(t *testing.T) {
+		msg := kafka.Message{Topic: "logs", Partition: 1, Offset: 7, Value: []byte("plain text log")}
+
+		logEntry := decodeMessage(parsers, msg)
+
+		assert.Equal(t, "logs-1-7", logEntry.ID)
+		assert.Equal(t, "plain text log", logEntry.Message)
+		assert.Empty(t, logEntry.Tags)
+	})
+
+	t.Run("GIVEN log-format header WHEN decode THEN use that parser", func(t *testing.T) {
+		msg := kafka.Message{
+			Key:     []byte("1"),
+			Value:   []byte(`level=warn msg="disk almost full"`),
+			Headers: []kafka.Header{{Key: "Log-Format", Value: []byte("logfmt")}},
+		}
+
+		logEntry := decodeMessage(parsers, msg)
+
+		assert.Equal(t, "warn", logEntry.Level)
+		assert.Equal(t, "disk almost full", logEntry.Message)
+	})
+
+	t.Run("GIVEN topic format WHEN parse fails THEN tag the raw document", func(t *testing.T) {
+		msg := kafka.Message{Topic: "edge-logs", Key: []byte("1"), Value: []byte("not an access log")}
+
+		logEntry := decodeMessage(parsers, msg)
+
+		assert.Equal(t, "not an access log", logEntry.Message)
+		assert.Equal(t, []string{parser.TagParseFailure}, logEntry.Tags)
+		assert.Equal(t, parser.FormatAccessLog, logEntry.Attributes["parse_format"])
+	})
+}
@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"strings"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+)
+
+const DefaultSourceHeader = "source"
+
+// HeaderMapping controls which Kafka headers are copied into the document
+// metadata. An empty Allowlist copies every header; Renames maps a header
+// name to the metadata field it is stored under. Header names are matched
+// case-insensitively.
+type HeaderMapping struct {
+	Allowlist    []string
+	Renames      map[string]string
+	SourceHeader string
+}
+
+func (h HeaderMapping) metadata(headers []kafka.Header) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(h.Allowlist))
+	for _, name := range h.Allowlist {
+		allowed[strings.ToLower(name)] = true
+	}
+
+	metadata := make(map[string]string)
+	for _, header := range headers {
+		name := strings.ToLower(header.Key)
+		if len(allowed) > 0 && !allowed[name] {
+			continue
+		}
+
+		field := name
+		for from, to := range h.Renames {
+			if strings.EqualFold(from, name) {
+				field = to
+				break
+			}
+		}
+		metadata[field] = string(header.Value)
+	}
+
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// applyProvenance records where the log came from and fills Source and
+// Timestamp from the record when the payload did not carry them.
+func applyProvenance(logEntry *service.Log, m kafka.Message, mapping HeaderMapping) {
+	logEntry.Kafka = &service.KafkaRecord{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+	}
+
+	if metadata := mapping.metadata(m.Headers); metadata != nil {
+		if logEntry.Metadata == nil {
+			logEntry.Metadata = metadata
+		} else {
+			for k, v := range metadata {
+				logEntry.Metadata[k] = v
+			}
+		}
+	}
+
+	logEntry.Source = messageSource(*logEntry, m, mapping)
+	if logEntry.Source == "" {
+		logEntry.Source = m.Topic
+	}
+
+	if logEntry.Timestamp.IsZero() && !m.Time.IsZero() {
+		logEntry.Timestamp = m.Time.UTC()
+	}
+}
+
+// messageSource is the source the message itself carries, in its payload or
+// else in the source header, empty when it has none.
+func messageSource(logEntry service.Log, m kafka.Message, mapping HeaderMapping) string {
+	if logEntry.Source != "" {
+		return logEntry.Source
+	}
+
+	sourceHeader := mapping.SourceHeader
+	if sourceHeader == "" {
+		sourceHeader = DefaultSourceHeader
+	}
+	return headerValue(m.Headers, sourceHeader)
+}
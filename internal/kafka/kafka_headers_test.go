@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyProvenance(t *testing.T) {
+	msgTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := kafka.Message{
+		Topic:     "payments-logs",
+		Partition: 3,
+		Offset:    1200,
+		Time:      msgTime,
+		Headers: []kafka.Header{
+			{Key: "X-Request-ID", Value: []byte("req-1")},
+			{Key: "trace-id", Value: []byte("abc")},
+			{Key: "content-type", Value: []byte("application/json")},
+		},
+	}
+
+	t.Run("GIVEN no mapping WHEN apply THEN copy every header and default from the record", func(t *testing.T) {
+		logEntry := service.Log{}
+
+		applyProvenance(&logEntry, msg, HeaderMapping{})
+
+		assert.Equal(t, &service.KafkaRecord{Topic: "payments-logs", Partition: 3, Offset: 1200}, logEntry.Kafka)
+		assert.Equal(t, "req-1", logEntry.Metadata["x-request-id"])
+		assert.Len(t, logEntry.Metadata, 3)
+		assert.Equal(t, "payments-logs", logEntry.Source)
+		assert.Equal(t, msgTime, logEntry.Timestamp)
+	})
+
+	t.Run("GIVEN allowlist and renames WHEN apply THEN keep and rename only listed headers", func(t *testing.T) {
+		logEntry := service.Log{}
+		mapping := HeaderMapping{
+			Allowlist: []string{"x-request-id", "trace-id"},
+			Renames:   map[string]string{"x-request-id": "request_id"},
+		}
+
+		applyProvenance(&logEntry, msg, mapping)
+
+		assert.Equal(t, map[string]string{"request_id": "req-1", "trace-id": "abc"}, logEntry.Metadata)
+	})
+
+	t.Run("GIVEN source header WHEN apply THEN prefer it over the topic", func(t *testing.T) {
+		withSource := msg
+		withSource.Headers = append([]kafka.Header{{Key: "service", Value: []byte("checkout")}}, msg.Headers...)
+		logEntry := service.Log{}
+
+		applyProvenance(&logEntry, withSource, HeaderMapping{SourceHeader: "service"})
+
+		assert.Equal(t, "checkout", logEntry.Source)
+	})
+
+	t.Run("GIVEN payload values WHEN apply THEN keep them", func(t *testing.T) {
+		ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		logEntry := service.Log{Source: "api", Timestamp: ts}
+
+		applyProvenance(&logEntry, msg, HeaderMapping{})
+
+		assert.Equal(t, "api", logEntry.Source)
+		assert.Equal(t, ts, logEntry.Timestamp)
+	})
+}
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/metrics"
+	"github.com/segmentio/kafka-go"
+)
+
+func partitionLabels(topic string, partition int) []string {
+	return []string{topic, strconv.Itoa(partition)}
+}
+
+func observeRead(m kafka.Message) {
+	labels := partitionLabels(m.Topic, m.Partition)
+	metrics.MessagesRead.WithLabelValues(labels...).Inc()
+	if m.HighWaterMark > 0 {
+		metrics.ConsumerLag.WithLabelValues(labels...).Set(float64(max(m.HighWaterMark-m.Offset-1, 0)))
+	}
+}
+
+// observeOutcome records how a message ended once it no longer needs to be
+// handled: processed, or failed and handed to the dead-letter topic.
+func observeOutcome(m kafka.Message, err error, attempts int) {
+	labels := partitionLabels(m.Topic, m.Partition)
+	if attempts > 1 {
+		metrics.MessagesRetried.WithLabelValues(labels...).Add(float64(attempts - 1))
+	}
+	if err != nil {
+		metrics.MessagesFailed.WithLabelValues(labels...).Inc()
+		return
+	}
+	metrics.MessagesProcessed.WithLabelValues(labels...).Inc()
+}
+
+// trackWorker marks a worker as busy and returns the function that releases
+// it, recording how long the work took.
+func trackWorker() func() {
+	metrics.WorkersBusy.Inc()
+	start := time.Now()
+	return func() {
+		metrics.WorkersBusy.Dec()
+		metrics.ProcessingDuration.Observe(time.Since(start).Seconds())
+	}
+}
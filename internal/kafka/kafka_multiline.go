@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+)
+
+// event is what the workers process: one message, or the consecutive
+// messages multiline stitching joined into a single log. The offsets of all
+// of them are only marked done once the event is handled.
+type event struct {
+	msgs []kafka.Message
+	// stitched is the joined log; single messages are decoded by the worker.
+	stitched *service.Log
+}
+
+func newEvent(m kafka.Message) event {
+	return event{msgs: []kafka.Message{m}}
+}
+
+func (e event) size() int {
+	size := 0
+	for _, m := range e.msgs {
+		size += len(m.Value)
+	}
+	return size
+}
+
+// MultilineConfig joins consecutive messages sharing a source, or a key when
+// they have no source, into one log, as stack traces arrive one line per
+// message. A message continues the current event when it matches
+// ContinuationPattern, or when StartPattern is set and it does not match it;
+// setting either pattern enables stitching.
+type MultilineConfig struct {
+	StartPattern        *regexp.Regexp
+	ContinuationPattern *regexp.Regexp
+	// MaxLines and MaxBytes flush an event once reached.
+	MaxLines int
+	MaxBytes int
+	// FlushTimeout flushes an event that received no message for that long.
+	FlushTimeout time.Duration
+}
+
+func (c MultilineConfig) Enabled() bool {
+	return c.StartPattern != nil || c.ContinuationPattern != nil
+}
+
+func (c MultilineConfig) continues(message string) bool {
+	if c.ContinuationPattern != nil && c.ContinuationPattern.MatchString(message) {
+		return true
+	}
+	return c.StartPattern != nil && !c.StartPattern.MatchString(message)
+}
+
+type multilineEvent struct {
+	msgs     []kafka.Message
+	logs     []service.Log
+	size     int
+	deadline time.Time
+}
+
+// runMultiline groups the messages received on in into events and emits
+// them on out once complete. decode returns each log with the source its
+// message carried, as the topic Source falls back to groups nothing. Closing in means the processor is shutting
+// down: the events still being assembled are dropped, uncommitted, so they
+// are read again whole by the next consumer instead of stored in pieces.
+func runMultiline(cfg MultilineConfig, decode func(kafka.Message) (service.Log, string), in <-chan kafka.Message, out chan<- event) {
+	defer close(out)
+
+	pending := make(map[string]*multilineEvent)
+	var order []string
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	flush := func(key string) {
+		group := pending[key]
+		delete(pending, key)
+		for i, k := range order {
+			if k == key {
+				order = append(order[:i], order[i+1:]...)
+				break
+			}
+		}
+		out <- event{msgs: group.msgs, stitched: stitch(group.logs)}
+	}
+
+	resetTimer := func() {
+		timer.Stop()
+		if cfg.FlushTimeout <= 0 || len(order) == 0 {
+			return
+		}
+		next := pending[order[0]].deadline
+		for _, key := range order[1:] {
+			if deadline := pending[key].deadline; deadline.Before(next) {
+				next = deadline
+			}
+		}
+		timer.Reset(time.Until(next))
+	}
+
+	for {
+		select {
+		case m, ok := <-in:
+			if !ok {
+				if len(order) > 0 {
+					log.Printf("Shutting down, leaving %d partial multiline events uncommitted", len(order))
+				}
+				return
+			}
+
+			logEntry, source := decode(m)
+			key := multilineKey(m, source)
+			if _, ok := pending[key]; ok && !cfg.continues(logEntry.Message) {
+				flush(key)
+			}
+
+			group, ok := pending[key]
+			if !ok {
+				group = &multilineEvent{}
+				pending[key] = group
+				order = append(order, key)
+			}
+			group.msgs = append(group.msgs, m)
+			group.logs = append(group.logs, logEntry)
+			group.size += len(logEntry.Message)
+			group.deadline = time.Now().Add(cfg.FlushTimeout)
+
+			if (cfg.MaxLines > 0 && len(group.msgs) >= cfg.MaxLines) || (cfg.MaxBytes > 0 && group.size >= cfg.MaxBytes) {
+				flush(key)
+			}
+			resetTimer()
+		case <-timer.C:
+			now := time.Now()
+			for _, key := range append([]string{}, order...) {
+				if !pending[key].deadline.After(now) {
+					flush(key)
+				}
+			}
+			resetTimer()
+		}
+	}
+}
+
+// multilineKey keeps the events of each source, or of each key for the
+// messages without one, apart within a partition. Keys come second as
+// producers often key by log ID, which would never group anything.
+func multilineKey(m kafka.Message, source string) string {
+	if source != "" {
+		return fmt.Sprintf("%s/%d/s:%s", m.Topic, m.Partition, source)
+	}
+	return fmt.Sprintf("%s/%d/k:%s", m.Topic, m.Partition, m.Key)
+}
+
+// stitch keeps the first log, with the ID and timestamp of the event's first
+// line, and joins the messages of all of them.
+func stitch(logs []service.Log) *service.Log {
+	logEntry := logs[0]
+	if len(logs) == 1 {
+		return &logEntry
+	}
+
+	messages := make([]string, len(logs))
+	for i, l := range logs {
+		messages[i] = l.Message
+	}
+	logEntry.Message = strings.Join(messages, "\n")
+
+	attributes := make(map[string]interface{}, len(logEntry.Attributes)+1)
+	for key, value := range logEntry.Attributes {
+		attributes[key] = value
+	}
+	attributes["multiline_lines"] = len(logs)
+	logEntry.Attributes = attributes
+	return &logEntry
+}
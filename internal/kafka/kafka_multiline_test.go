@@ -0,0 +1,245 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRaw(m kafka.Message) (service.Log, string) {
+	return service.Log{ID: fmt.Sprint(m.Offset), Message: string(m.Value)}, ""
+}
+
+func startMultiline(cfg MultilineConfig) (chan<- kafka.Message, <-chan event) {
+	in := make(chan kafka.Message)
+	out := make(chan event, 10)
+	go runMultiline(cfg, decodeRaw, in, out)
+	return in, out
+}
+
+func receiveEvent(t *testing.T, out <-chan event) event {
+	select {
+	case e := <-out:
+		return e
+	case <-time.After(time.Second):
+		require.FailNow(t, "timeout: event was not flushed")
+		return event{}
+	}
+}
+
+func TestRunMultiline(t *testing.T) {
+	t.Run("GIVEN a start pattern WHEN the next event starts THEN flush the lines before it", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{StartPattern: regexp.MustCompile(`^\d{4}-`), FlushTimeout: time.Hour})
+
+		in <- kafka.Message{Offset: 0, Value: []byte("2024-05-01 ERROR boom")}
+		in <- kafka.Message{Offset: 1, Value: []byte("java.lang.IllegalStateException: boom")}
+		in <- kafka.Message{Offset: 2, Value: []byte("\tat com.acme.Main.run(Main.java:42)")}
+		in <- kafka.Message{Offset: 3, Value: []byte("2024-05-01 INFO next")}
+
+		e := receiveEvent(t, out)
+		assert.Len(t, e.msgs, 3)
+		assert.Equal(t, "0", e.stitched.ID)
+		assert.Equal(t, "2024-05-01 ERROR boom\njava.lang.IllegalStateException: boom\n\tat com.acme.Main.run(Main.java:42)", e.stitched.Message)
+		assert.Equal(t, 3, e.stitched.Attributes["multiline_lines"])
+		assert.Empty(t, out, "the event still open must not be flushed")
+		close(in)
+	})
+
+	t.Run("GIVEN a continuation pattern WHEN a line does not match THEN it starts a new event", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{ContinuationPattern: regexp.MustCompile(`^(\s+|Traceback|\w+Error:)`), FlushTimeout: time.Hour})
+
+		in <- kafka.Message{Offset: 0, Value: []byte("Traceback (most recent call last):")}
+		in <- kafka.Message{Offset: 1, Value: []byte(`  File "app.py", line 3`)}
+		in <- kafka.Message{Offset: 2, Value: []byte("ValueError: boom")}
+		in <- kafka.Message{Offset: 3, Value: []byte("request served")}
+		in <- kafka.Message{Offset: 4, Value: []byte("request served")}
+
+		assert.Len(t, receiveEvent(t, out).msgs, 3)
+		single := receiveEvent(t, out)
+		assert.Len(t, single.msgs, 1)
+		assert.Equal(t, "request served", single.stitched.Message)
+		assert.Nil(t, single.stitched.Attributes)
+		close(in)
+	})
+
+	t.Run("GIVEN interleaved keys WHEN stitching THEN keep their events apart", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{StartPattern: regexp.MustCompile(`^START`), FlushTimeout: time.Hour})
+
+		in <- kafka.Message{Offset: 0, Key: []byte("a"), Value: []byte("START a")}
+		in <- kafka.Message{Offset: 1, Key: []byte("b"), Value: []byte("START b")}
+		in <- kafka.Message{Offset: 2, Key: []byte("a"), Value: []byte("a1")}
+		in <- kafka.Message{Offset: 3, Key: []byte("b"), Value: []byte("b1")}
+		in <- kafka.Message{Offset: 4, Key: []byte("a"), Value: []byte("START a2")}
+
+		e := receiveEvent(t, out)
+		assert.Equal(t, "START a\na1", e.stitched.Message)
+		assert.Equal(t, []int64{0, 2}, []int64{e.msgs[0].Offset, e.msgs[1].Offset})
+		close(in)
+	})
+
+	t.Run("GIVEN max lines reached WHEN stitching THEN flush immediately", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{StartPattern: regexp.MustCompile(`^START`), MaxLines: 2, FlushTimeout: time.Hour})
+
+		in <- kafka.Message{Offset: 0, Value: []byte("START")}
+		in <- kafka.Message{Offset: 1, Value: []byte("line")}
+
+		assert.Len(t, receiveEvent(t, out).msgs, 2)
+		close(in)
+	})
+
+	t.Run("GIVEN max bytes reached WHEN stitching THEN flush immediately", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{StartPattern: regexp.MustCompile(`^START`), MaxBytes: 8, FlushTimeout: time.Hour})
+
+		in <- kafka.Message{Offset: 0, Value: []byte("START")}
+		in <- kafka.Message{Offset: 1, Value: []byte("line")}
+
+		assert.Len(t, receiveEvent(t, out).msgs, 2)
+		close(in)
+	})
+
+	t.Run("GIVEN no new line WHEN the flush timeout elapses THEN flush the event", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{StartPattern: regexp.MustCompile(`^START`), FlushTimeout: 20 * time.Millisecond})
+
+		in <- kafka.Message{Offset: 0, Value: []byte("START")}
+		in <- kafka.Message{Offset: 1, Value: []byte("line")}
+
+		assert.Len(t, receiveEvent(t, out).msgs, 2)
+		close(in)
+	})
+
+	t.Run("GIVEN a partial event WHEN shutting down THEN drop it", func(t *testing.T) {
+		in, out := startMultiline(MultilineConfig{StartPattern: regexp.MustCompile(`^START`), FlushTimeout: time.Hour})
+
+		in <- kafka.Message{Offset: 0, Value: []byte("START")}
+		close(in)
+
+		_, open := <-out
+		assert.False(t, open)
+	})
+}
+
+func TestMultilineKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      kafka.Message
+		source   string
+		expected string
+	}{
+		{"source wins over key", kafka.Message{Topic: "logs", Partition: 1, Key: []byte("id-1")}, "web-1", "logs/1/s:web-1"},
+		{"key without source", kafka.Message{Topic: "logs", Partition: 1, Key: []byte("web-1")}, "", "logs/1/k:web-1"},
+		{"neither groups the partition", kafka.Message{Topic: "logs", Partition: 2}, "", "logs/2/k:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, multilineKey(tt.msg, tt.source))
+		})
+	}
+}
+
+func TestProcessor_Multiline(t *testing.T) {
+	t.Run("GIVEN an event being assembled WHEN later messages are processed THEN commit nothing past its first line", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		messages := []kafka.Message{
+			{Offset: 0, Value: []byte("START one")},
+			{Offset: 1, Value: []byte("  at line")},
+			{Offset: 2, Value: []byte("START two")},
+			{Offset: 3, Value: []byte("  at line")},
+		}
+		mockReader := &MockKafkaReader{Messages: messages}
+		mockService := &MockLogService{}
+
+		processor := NewProcessor(mockReader, mockService, 2, 1, time.Millisecond)
+		require.NoError(t, processor.Parsers.SetDefault(parser.FormatRaw))
+		processor.Multiline = MultilineConfig{StartPattern: regexp.MustCompile(`^START`), FlushTimeout: time.Hour}
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return mockService.ProcessedCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+
+		assert.Equal(t, 1, mockService.ProcessedCount(), "the second event is incomplete")
+		assert.Equal(t, "START one\n  at line", mockService.Processed[0].Message)
+		assert.Equal(t, int64(1), mockReader.CommittedMsgs[len(mockReader.CommittedMsgs)-1].Offset)
+	})
+
+	t.Run("GIVEN keyed messages without a source interleaved WHEN stitching THEN keep the events of each key apart", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		messages := []kafka.Message{
+			{Topic: "logs", Offset: 0, Key: []byte("a"), Value: []byte("START a")},
+			{Topic: "logs", Offset: 1, Key: []byte("b"), Value: []byte("START b")},
+			{Topic: "logs", Offset: 2, Key: []byte("a"), Value: []byte("  at a")},
+			{Topic: "logs", Offset: 3, Key: []byte("b"), Value: []byte("  at b")},
+			{Topic: "logs", Offset: 4, Key: []byte("a"), Value: []byte("START a2")},
+			{Topic: "logs", Offset: 5, Key: []byte("b"), Value: []byte("START b2")},
+		}
+		mockReader := &MockKafkaReader{Messages: messages}
+		mockService := &MockLogService{}
+
+		processor := NewProcessor(mockReader, mockService, 1, 1, time.Millisecond)
+		require.NoError(t, processor.Parsers.SetDefault(parser.FormatRaw))
+		processor.Multiline = MultilineConfig{StartPattern: regexp.MustCompile(`^START`), FlushTimeout: time.Hour}
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return mockService.ProcessedCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		assert.Equal(t, "START a\n  at a", mockService.Processed[0].Message)
+		assert.Equal(t, "START b\n  at b", mockService.Processed[1].Message)
+		assert.Equal(t, "logs", mockService.Processed[0].Source)
+	})
+
+	t.Run("GIVEN batch mode WHEN stitching THEN index each event as one document", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		messages := []kafka.Message{
+			{Offset: 0, Value: []byte("START one")},
+			{Offset: 1, Value: []byte("  at line")},
+			{Offset: 2, Value: []byte("START two")},
+		}
+		mockReader := &MockKafkaReader{Messages: messages}
+		mockService := &MockLogService{}
+
+		processor := NewProcessor(mockReader, mockService, 1, 1, time.Millisecond)
+		require.NoError(t, processor.Parsers.SetDefault(parser.FormatRaw))
+		processor.Batch = BatchConfig{MaxDocs: 10, MaxLatency: 10 * time.Millisecond}
+		processor.Multiline = MultilineConfig{StartPattern: regexp.MustCompile(`^START`), FlushTimeout: 20 * time.Millisecond}
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return mockService.ProcessedCount() == 2 }, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		assert.Equal(t, int64(2), mockReader.CommittedMsgs[len(mockReader.CommittedMsgs)-1].Offset)
+	})
+}
@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"log"
+	"sync"
+
+	"github.com/rodrigogmartins/log-processor/internal/metrics"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+type partitionOffsets struct {
+	inFlight []kafka.Message
+	done     map[int64]bool
+}
+
+// offsetTracker keeps the messages read from each partition in read order and
+// only commits the highest offset below which every message has completed, so
+// a slow message is never skipped by a commit of a later one.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+	commit     func(kafka.Message) error
+}
+
+func newOffsetTracker(commit func(kafka.Message) error) *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[topicPartition]*partitionOffsets),
+		commit:     commit,
+	}
+}
+
+func (t *offsetTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: m.Topic, partition: m.Partition}
+	po, ok := t.partitions[key]
+	if !ok || (len(po.inFlight) > 0 && m.Offset <= po.inFlight[len(po.inFlight)-1].Offset) {
+		// First message of the partition, or the reader rewound after a
+		// rebalance and the previous in-flight messages will be delivered again.
+		po = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[key] = po
+	}
+
+	po.inFlight = append(po.inFlight, m)
+}
+
+func (t *offsetTracker) done(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	po, ok := t.partitions[topicPartition{topic: m.Topic, partition: m.Partition}]
+	if !ok {
+		return
+	}
+	po.done[m.Offset] = true
+
+	var watermark *kafka.Message
+	released := 0
+	for len(po.inFlight) > 0 && po.done[po.inFlight[0].Offset] {
+		head := po.inFlight[0]
+		delete(po.done, head.Offset)
+		po.inFlight = po.inFlight[1:]
+		watermark = &head
+		released++
+	}
+
+	if watermark == nil {
+		return
+	}
+
+	if err := t.commit(*watermark); err != nil {
+		log.Printf("Error committing offset %d on %s/%d: %v", watermark.Offset, watermark.Topic, watermark.Partition, err)
+		return
+	}
+	metrics.MessagesCommitted.WithLabelValues(partitionLabels(watermark.Topic, watermark.Partition)...).Add(float64(released))
+}
+
+// pending returns how many tracked messages are still waiting to be committed.
+func (t *offsetTracker) pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	total := 0
+	for _, po := range t.partitions {
+		total += len(po.inFlight)
+	}
+	return total
+}
@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	newMsg := func(partition int, offset int64) kafka.Message {
+		return kafka.Message{Topic: "logs", Partition: partition, Offset: offset}
+	}
+
+	t.Run("GIVEN out of order completion WHEN done THEN commit only the contiguous watermark", func(t *testing.T) {
+		var committed []int64
+		tracker := newOffsetTracker(func(m kafka.Message) error {
+			committed = append(committed, m.Offset)
+			return nil
+		})
+
+		for offset := int64(10); offset <= 13; offset++ {
+			tracker.track(newMsg(0, offset))
+		}
+
+		tracker.done(newMsg(0, 11))
+		tracker.done(newMsg(0, 12))
+		assert.Empty(t, committed, "must not commit past the slow offset 10")
+
+		tracker.done(newMsg(0, 10))
+		assert.Equal(t, []int64{12}, committed)
+
+		tracker.done(newMsg(0, 13))
+		assert.Equal(t, []int64{12, 13}, committed)
+		assert.Equal(t, 0, tracker.pending())
+	})
+
+	t.Run("GIVEN multiple partitions WHEN done THEN track them independently", func(t *testing.T) {
+		committed := map[int]int64{}
+		tracker := newOffsetTracker(func(m kafka.Message) error {
+			committed[m.Partition] = m.Offset
+			return nil
+		})
+
+		tracker.track(newMsg(0, 1))
+		tracker.track(newMsg(1, 1))
+		tracker.track(newMsg(0, 2))
+
+		tracker.done(newMsg(0, 2))
+		tracker.done(newMsg(1, 1))
+
+		assert.Equal(t, map[int]int64{1: 1}, committed)
+		assert.Equal(t, 2, tracker.pending())
+	})
+
+	t.Run("GIVEN reader rewinds WHEN track THEN reset partition state", func(t *testing.T) {
+		var committed []int64
+		tracker := newOffsetTracker(func(m kafka.Message) error {
+			committed = append(committed, m.Offset)
+			return nil
+		})
+
+		tracker.track(newMsg(0, 5))
+		tracker.track(newMsg(0, 6))
+		tracker.track(newMsg(0, 5))
+
+		tracker.done(newMsg(0, 5))
+		assert.Equal(t, []int64{5}, committed)
+		assert.Equal(t, 0, tracker.pending())
+	})
+}
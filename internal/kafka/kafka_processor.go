@@ -2,11 +2,19 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/metrics"
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/retry"
 	"github.com/rodrigogmartins/log-processor/internal/service"
 	"github.com/segmentio/kafka-go"
 )
@@ -17,29 +25,106 @@ type KafkaReader interface {
 	CommitMessage(msg kafka.Message) error
 }
 
+// LogBuffer durably stores logs that cannot reach Elasticsearch right now.
+type LogBuffer interface {
+	Append(logs ...service.Log) error
+}
+
 type Processor struct {
-	Reader       KafkaReader
-	LogService   service.LogServiceInterface
-	MaxWorkers   int
-	RetryMax     int
-	RetryBackoff time.Duration
+	Reader     KafkaReader
+	LogService service.LogServiceInterface
+	MaxWorkers int
+	Retry      retry.Policy
+
+	// DeadLetter receives the messages that failed permanently or ran out of
+	// retries. When nil the failure is only logged.
+	DeadLetter KafkaWriter
+
+	// Batch switches the processor to bulk indexing when enabled.
+	Batch BatchConfig
+
+	// OrderByKey processes messages sharing a key one after the other, in
+	// the order they were read. In batch mode it serializes the flushes.
+	OrderByKey bool
+
+	// Parsers picks the payload format per header or topic.
+	Parsers *parser.Registry
+
+	// Headers selects the Kafka headers stored as document metadata.
+	Headers HeaderMapping
+
+	// Multiline joins the lines of stack traces sent as separate messages.
+	// The offsets of an event are only committed once all of it is stored.
+	Multiline MultilineConfig
+
+	// Breaker guards Elasticsearch. While it is open the processor stops
+	// reading from Kafka and in-flight logs wait for it instead of failing.
+	Breaker *breaker.Breaker
+
+	// WAL, when set, takes the logs Elasticsearch cannot store so the
+	// processor keeps draining Kafka during outages instead of pausing.
+	// Their offsets are committed once the WAL has synced them to disk.
+	WAL LogBuffer
+
+	offsets *offsetTracker
+	state   atomic.Value
+	// deadLettering counts the messages waiting for the dead-letter topic to
+	// accept them.
+	deadLettering atomic.Int64
 }
 
+// Processor states reported by State.
+const (
+	StateIdle     = "idle"
+	StateRunning  = "running"
+	StateStopping = "stopping"
+	StateStopped  = "stopped"
+)
+
+const (
+	processTimeout = 5 * time.Second
+	batchTimeout   = 30 * time.Second
+	// deadLetterMinBackoff keeps a failing dead-letter topic from being
+	// retried in a tight loop when the policy has no backoff.
+	deadLetterMinBackoff = 100 * time.Millisecond
+)
+
 func NewProcessor(reader KafkaReader, logService service.LogServiceInterface, maxWorkers int, retryMax int, retryBackoff time.Duration) *Processor {
+	policy := retry.NewPolicy(retryMax, retryBackoff)
+	policy.IsRetryable = isRetryable
+
 	return &Processor{
-		Reader:       reader,
-		LogService:   logService,
-		MaxWorkers:   maxWorkers,
-		RetryMax:     retryMax,
-		RetryBackoff: retryBackoff,
+		Reader:     reader,
+		LogService: logService,
+		MaxWorkers: maxWorkers,
+		Retry:      policy,
+		Parsers:    parser.NewRegistry(),
 	}
 }
 
+// isRetryable never retries logs that failed validation, as they cannot
+// succeed on a second try, nor calls rejected by an open breaker, which are
+// resumed once the breaker lets them through.
+func isRetryable(err error) bool {
+	if errors.Is(err, service.ErrInvalidLog) || errors.Is(err, breaker.ErrOpen) {
+		return false
+	}
+	return retry.Transient(err)
+}
+
 func (p *Processor) Start(ctx context.Context) error {
-	sem := make(chan struct{}, p.MaxWorkers)
-	var wg sync.WaitGroup
+	p.offsets = newOffsetTracker(p.Reader.CommitMessage)
+	dispatch, wait := p.startWorkers(ctx)
+	p.state.Store(StateRunning)
+	defer p.state.Store(StateStopped)
 
 	for {
+		if p.WAL == nil {
+			if err := p.waitForBreaker(ctx); err != nil {
+				break
+			}
+		}
+
 		log.Println("Kafka processor reading message")
 		msg, err := p.Reader.ReadMessage(ctx)
 		log.Printf("read message: %v", msg)
@@ -56,39 +141,423 @@ func (p *Processor) Start(ctx context.Context) error {
 			continue
 		}
 
+		observeRead(msg)
+		p.offsets.track(msg)
+		dispatch(msg)
+	}
+
+	p.state.Store(StateStopping)
+	wait()
+	return p.Reader.Close()
+}
+
+func (p *Processor) State() string {
+	if state, ok := p.state.Load().(string); ok {
+		return state
+	}
+	return StateIdle
+}
+
+// Health fails unless the processor loop is running, so instances that have
+// not started yet or are shutting down stop receiving traffic. It also fails
+// while messages are stuck waiting for the dead-letter topic, as their
+// partitions cannot move on until they are written.
+func (p *Processor) Health(ctx context.Context) error {
+	if state := p.State(); state != StateRunning {
+		return fmt.Errorf("processor is %s", state)
+	}
+	if n := p.deadLettering.Load(); n > 0 {
+		return fmt.Errorf("%d messages waiting for the dead-letter topic", n)
+	}
+	return nil
+}
+
+// startWorkers returns the function used to hand a message over to the
+// workers and the one that waits for all of them to finish.
+func (p *Processor) startWorkers(ctx context.Context) (func(kafka.Message), func()) {
+	dispatch, wait := p.startEventWorkers(ctx)
+	if !p.Multiline.Enabled() {
+		return func(m kafka.Message) { dispatch(newEvent(m)) }, wait
+	}
+
+	in := make(chan kafka.Message)
+	events := make(chan event)
+	go runMultiline(p.Multiline, p.decodeSource, in, events)
+
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for e := range events {
+			dispatch(e)
+		}
+	}()
+
+	return func(m kafka.Message) { in <- m }, func() {
+		close(in)
+		<-forwarded
+		wait()
+	}
+}
+
+func (p *Processor) startEventWorkers(ctx context.Context) (func(event), func()) {
+	var wg sync.WaitGroup
+	workers := max(p.MaxWorkers, 1)
+	metrics.WorkersMax.Set(float64(workers))
+
+	if p.Batch.Enabled() {
+		return p.startBatchWorkers(ctx, workers)
+	}
+
+	if p.OrderByKey {
+		lanes := make([]chan event, workers)
+		for i := range lanes {
+			lanes[i] = make(chan event)
+			wg.Add(1)
+			go func(lane <-chan event) {
+				defer wg.Done()
+				for e := range lane {
+					p.handleEvent(ctx, e)
+				}
+			}(lanes[i])
+		}
+
+		dispatch := func(e event) {
+			lanes[laneFor(e.msgs[0], workers)] <- e
+		}
+		wait := func() {
+			for _, lane := range lanes {
+				close(lane)
+			}
+			wg.Wait()
+		}
+		return dispatch, wait
+	}
+
+	sem := make(chan struct{}, workers)
+	dispatch := func(e event) {
 		sem <- struct{}{}
 		wg.Add(1)
 
-		go func(m kafka.Message) {
+		go func() {
 			defer func() {
 				<-sem
 				wg.Done()
 			}()
 
-			logEntry := service.Log{
-				ID:      string(m.Key),
-				Message: string(m.Value),
+			p.handleEvent(ctx, e)
+		}()
+	}
+	return dispatch, wg.Wait
+}
+
+func (p *Processor) startBatchWorkers(ctx context.Context, workers int) (func(event), func()) {
+	var wg sync.WaitGroup
+
+	in := make(chan event)
+	batches := make(chan []event)
+	go runBatcher(p.Batch, in, batches)
+
+	if p.OrderByKey {
+		workers = 1
+		metrics.WorkersMax.Set(1)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				p.handleBatch(ctx, batch)
 			}
+		}()
+	}
 
-			for i := 0; i < p.RetryMax; i++ {
-				opCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	dispatch := func(e event) {
+		in <- e
+	}
+	wait := func() {
+		close(in)
+		wg.Wait()
+	}
+	return dispatch, wait
+}
 
-				err := p.LogService.Process(opCtx, logEntry)
-				cancel()
-				if err == nil {
-					break
-				}
+func (p *Processor) decode(m kafka.Message) service.Log {
+	logEntry, _ := p.decodeSource(m)
+	return logEntry
+}
+
+// decodeSource also returns the source the message carried itself, which is
+// empty when the log's Source only falls back to the topic.
+func (p *Processor) decodeSource(m kafka.Message) (service.Log, string) {
+	logEntry := decodeMessage(p.Parsers, m)
+	source := messageSource(logEntry, m, p.Headers)
+	applyProvenance(&logEntry, m, p.Headers)
+	return logEntry, source
+}
+
+func (p *Processor) eventLog(e event) service.Log {
+	if e.stitched != nil {
+		return *e.stitched
+	}
+	return p.decode(e.msgs[0])
+}
+
+// laneFor picks the worker for a message so that all messages sharing a key
+// are handled by the same one. Keyless messages stay ordered per partition.
+func laneFor(m kafka.Message, lanes int) int {
+	h := fnv.New32a()
+	if len(m.Key) > 0 {
+		h.Write(m.Key)
+	} else {
+		fmt.Fprintf(h, "%s/%d", m.Topic, m.Partition)
+	}
+	return int(h.Sum32() % uint32(lanes))
+}
+
+func (p *Processor) handleEvent(ctx context.Context, e event) {
+	defer trackWorker()()
+	logEntry := p.eventLog(e)
+	attempts, err := p.process(ctx, logEntry)
 
-				log.Printf("Retry %d: error processing log %s: %v", i+1, logEntry.ID, err)
-				time.Sleep(p.RetryBackoff * time.Duration(i+1))
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Shutting down, leaving log %s uncommitted", logEntry.ID)
+		return
+	}
+	p.complete(ctx, e, err, attempts)
+}
+
+// complete records how the event ended and marks its messages done, unless
+// a failed one could not be dead-lettered.
+func (p *Processor) complete(ctx context.Context, e event, err error, attempts int) {
+	for _, m := range e.msgs {
+		observeOutcome(m, err, attempts)
+		if err != nil && !p.deadLetter(ctx, m, err, attempts) {
+			continue
+		}
+		p.offsets.done(m)
+	}
+}
+
+// ProcessLog runs a log read from another input through the same retries,
+// breaker pauses and WAL spill as the Kafka messages. A nil error means the
+// log is stored, in Elasticsearch or in the WAL.
+func (p *Processor) ProcessLog(ctx context.Context, logEntry service.Log) error {
+	_, err := p.process(ctx, logEntry)
+	return err
+}
+
+// process retries the log until it is stored or fails permanently, waiting
+// out an open breaker without spending attempts.
+func (p *Processor) process(ctx context.Context, logEntry service.Log) (int, error) {
+	var attempts int
+	var err error
+	for {
+		attempts, err = p.Retry.Do(ctx, func(ctx context.Context) error {
+			opCtx, cancel := context.WithTimeout(ctx, processTimeout)
+			defer cancel()
+
+			err := p.LogService.Process(opCtx, logEntry)
+			if err != nil {
+				log.Printf("Error processing log %s: %v", logEntry.ID, err)
+			}
+			return err
+		})
+
+		if err != nil && ctx.Err() == nil && p.spill(err, logEntry) {
+			err = nil
+		}
+
+		if !errors.Is(err, breaker.ErrOpen) || p.waitForBreaker(ctx) != nil {
+			return attempts, err
+		}
+	}
+}
+
+// handleBatch indexes the events in bulk and commits the offsets once the
+// whole batch is acknowledged.
+func (p *Processor) handleBatch(ctx context.Context, events []event) {
+	defer trackWorker()()
+	logEntries := make([]service.Log, len(events))
+	for i, e := range events {
+		logEntries[i] = p.eventLog(e)
+	}
+
+	errs, attempts := p.processBatch(ctx, logEntries)
+
+	for i, e := range events {
+		if errs[i] != nil && ctx.Err() != nil {
+			log.Printf("Shutting down, leaving log %s uncommitted", logEntries[i].ID)
+			continue
+		}
+		p.complete(ctx, e, errs[i], attempts[i])
+	}
+}
+
+// ProcessBatch is ProcessLog for the inputs receiving logs in batches,
+// indexed in bulk. The errors are in the order of the logs.
+func (p *Processor) ProcessBatch(ctx context.Context, logEntries []service.Log) []error {
+	errs, _ := p.processBatch(ctx, logEntries)
+	return errs
+}
+
+// processBatch indexes the logs in bulk, retrying only the documents that
+// failed with a retryable error, and reports the error and the number of
+// attempts of each.
+func (p *Processor) processBatch(ctx context.Context, logEntries []service.Log) ([]error, []int) {
+	pending := make([]int, len(logEntries))
+	for i := range logEntries {
+		pending[i] = i
+	}
+
+	errs := make([]error, len(logEntries))
+	attempts := make([]int, len(logEntries))
+	start := time.Now()
+	for round := 1; len(pending) > 0; round++ {
+		batch := make([]service.Log, len(pending))
+		for j, i := range pending {
+			batch[j] = logEntries[i]
+		}
+
+		opCtx, cancel := context.WithTimeout(ctx, batchTimeout)
+		results := p.LogService.ProcessBatch(opCtx, batch)
+		cancel()
+
+		var retryable []int
+		blocked := false
+		for j, err := range results {
+			i := pending[j]
+			errs[i] = err
+			attempts[i] = round
+			if errors.Is(err, breaker.ErrOpen) {
+				blocked = true
+				retryable = append(retryable, i)
+			} else if err != nil && p.Retry.ShouldRetry(err) {
+				retryable = append(retryable, i)
+			}
+		}
+		pending = retryable
+
+		if len(pending) == 0 {
+			break
+		}
+
+		// Rejected by the breaker: spill to the WAL, or wait for
+		// Elasticsearch to come back without spending an attempt.
+		if blocked && p.WAL != nil {
+			break
+		}
+		if blocked {
+			if p.waitForBreaker(ctx) != nil {
+				break
 			}
+			round--
+			start = time.Now()
+			continue
+		}
+
+		delay, ok := p.Retry.NextDelay(round, time.Since(start))
+		if !ok {
+			break
+		}
 
-			if err := p.Reader.CommitMessage(m); err != nil {
-				log.Printf("Error committing message: %v", err)
+		log.Printf("Retry %d: %d of %d logs failed in bulk request: %v", round, len(pending), len(logEntries), errs[pending[0]])
+		if retry.Sleep(ctx, delay) != nil {
+			break
+		}
+	}
+
+	if ctx.Err() == nil {
+		var spilled []int
+		var spillLogs []service.Log
+		for i, err := range errs {
+			if err != nil && isUnavailable(err, p.Retry) {
+				spilled = append(spilled, i)
+				spillLogs = append(spillLogs, logEntries[i])
+			}
+		}
+		if len(spilled) > 0 && p.spill(errs[spilled[0]], spillLogs...) {
+			for _, i := range spilled {
+				errs[i] = nil
 			}
-		}(msg)
+		}
 	}
 
-	wg.Wait()
-	return p.Reader.Close()
+	return errs, attempts
+}
+
+// spill writes logs that failed because Elasticsearch is unavailable to the
+// WAL and reports whether they are safe to commit.
+func (p *Processor) spill(cause error, logEntries ...service.Log) bool {
+	if p.WAL == nil || !isUnavailable(cause, p.Retry) {
+		return false
+	}
+
+	for i := range logEntries {
+		if logEntries[i].Timestamp.IsZero() {
+			logEntries[i].Timestamp = time.Now().UTC()
+		}
+	}
+
+	if err := p.WAL.Append(logEntries...); err != nil {
+		log.Printf("Error writing %d logs to WAL: %v", len(logEntries), err)
+		return false
+	}
+
+	log.Printf("Elasticsearch unavailable (%v), %d logs written to WAL", cause, len(logEntries))
+	return true
+}
+
+// isUnavailable tells an unreachable Elasticsearch apart from a rejected
+// document.
+func isUnavailable(err error, policy retry.Policy) bool {
+	return errors.Is(err, breaker.ErrOpen) || policy.ShouldRetry(err)
+}
+
+func (p *Processor) waitForBreaker(ctx context.Context) error {
+	if p.Breaker == nil || p.Breaker.State() != breaker.Open {
+		return nil
+	}
+
+	log.Println("Elasticsearch circuit breaker is open, pausing until it recovers")
+	return p.Breaker.Wait(ctx)
+}
+
+// deadLetter reports whether the failed message is safe to commit. Writes to
+// the dead-letter topic are retried with the backoff of the retry policy
+// until they succeed: the message must not be skipped, and the partition
+// cannot commit past it anyway. Only a shutdown gives up, leaving it
+// uncommitted to be read again.
+func (p *Processor) deadLetter(ctx context.Context, m kafka.Message, cause error, attempts int) bool {
+	if p.DeadLetter == nil {
+		log.Printf("Dropping message at %s/%d/%d after %d attempts: %v", m.Topic, m.Partition, m.Offset, attempts, cause)
+		return true
+	}
+
+	dlqMsg := newDeadLetterMessage(m, cause, attempts, time.Now())
+	for attempt := 1; ; attempt++ {
+		err := p.writeDeadLetter(ctx, dlqMsg)
+		if err == nil {
+			break
+		}
+
+		if attempt == 1 {
+			p.deadLettering.Add(1)
+			defer p.deadLettering.Add(-1)
+		}
+		log.Printf("Error writing message at %s/%d/%d to dead-letter topic (attempt %d): %v", m.Topic, m.Partition, m.Offset, attempt, err)
+		if retry.Sleep(ctx, max(p.Retry.Backoff(attempt), deadLetterMinBackoff)) != nil {
+			log.Printf("Shutting down, leaving message at %s/%d/%d uncommitted", m.Topic, m.Partition, m.Offset)
+			return false
+		}
+	}
+
+	log.Printf("Message at %s/%d/%d sent to dead-letter topic after %d attempts: %v", m.Topic, m.Partition, m.Offset, attempts, cause)
+	return true
+}
+
+func (p *Processor) writeDeadLetter(ctx context.Context, m kafka.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, processTimeout)
+	defer cancel()
+	return p.DeadLetter.WriteMessages(ctx, m)
 }
@@ -2,33 +2,93 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rodrigogmartins/log-processor/internal/breaker"
+	"github.com/rodrigogmartins/log-processor/internal/metrics"
 	"github.com/rodrigogmartins/log-processor/internal/service"
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
 )
 
 type MockLogService struct {
+	mu         sync.Mutex
 	Processed  []service.Log
 	ShouldFail bool
+	// FailWith is returned by Process instead of the generic failure.
+	FailWith error
+	// FailBatchWith is returned for every entry by ProcessBatch.
+	FailBatchWith error
+	Calls         int
+	// FailTimes makes the given log ID fail that many times before succeeding.
+	FailTimes map[string]int
+	Batches   [][]service.Log
 }
 
 func (m *MockLogService) Process(ctx context.Context, logEntry service.Log) error {
 	time.Sleep(10 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls++
+	if m.FailWith != nil {
+		return m.FailWith
+	}
+	if m.ShouldFail {
+		return errors.New("processing failed")
+	}
+	if m.FailTimes[logEntry.ID] > 0 {
+		m.FailTimes[logEntry.ID]--
+		return errors.New("rejected")
+	}
 	m.Processed = append(m.Processed, logEntry)
 	return nil
 }
-func (m *MockLogService) SearchLogs(ctx context.Context, query map[string]interface{}, size int) ([]service.Log, error) {
-	if m.Processed == nil {
-		return []service.Log{}, nil
+
+func (m *MockLogService) ProcessBatch(ctx context.Context, logEntries []service.Log) []error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Batches = append(m.Batches, logEntries)
+	errs := make([]error, len(logEntries))
+	for i, logEntry := range logEntries {
+		if m.FailBatchWith != nil {
+			errs[i] = m.FailBatchWith
+			continue
+		}
+		if m.ShouldFail {
+			errs[i] = errors.New("processing failed")
+			continue
+		}
+		if m.FailTimes[logEntry.ID] > 0 {
+			m.FailTimes[logEntry.ID]--
+			errs[i] = errors.New("rejected")
+			continue
+		}
+		m.Processed = append(m.Processed, logEntry)
 	}
+	return errs
+}
 
-	if size > len(m.Processed) {
-		size = len(m.Processed)
+func (m *MockLogService) ProcessedCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Processed)
+}
+func (m *MockLogService) SearchLogs(ctx context.Context, query service.Query) (service.SearchResult, error) {
+	if m.Processed == nil {
+		return service.SearchResult{Logs: []service.Log{}}, nil
 	}
-	return m.Processed[:size], nil
+
+	size := min(query.Size, len(m.Processed))
+	return service.SearchResult{Logs: m.Processed[:size], Total: int64(len(m.Processed))}, nil
 }
 
 func TestProcessor_Start(t *testing.T) {
@@ -36,9 +96,9 @@ func TestProcessor_Start(t *testing.T) {
 	defer cancel()
 
 	messages := []kafka.Message{
-		{Key: []byte("1"), Value: []byte("msg1")},
-		{Key: []byte("2"), Value: []byte("msg2")},
-		{Key: []byte("3"), Value: []byte("msg3")},
+		{Offset: 0, Key: []byte("1"), Value: []byte("msg1")},
+		{Offset: 1, Key: []byte("2"), Value: []byte("msg2")},
+		{Offset: 2, Key: []byte("3"), Value: []byte("msg3")},
 	}
 
 	mockReader := &MockKafkaReader{Messages: messages}
@@ -72,11 +132,11 @@ func TestProcessor_WorkerPool(t *testing.T) {
 	defer cancel()
 
 	messages := []kafka.Message{
-		{Key: []byte("1"), Value: []byte("msg1")},
-		{Key: []byte("2"), Value: []byte("msg2")},
-		{Key: []byte("3"), Value: []byte("msg3")},
-		{Key: []byte("4"), Value: []byte("msg4")},
-		{Key: []byte("5"), Value: []byte("msg5")},
+		{Offset: 0, Key: []byte("1"), Value: []byte("msg1")},
+		{Offset: 1, Key: []byte("2"), Value: []byte("msg2")},
+		{Offset: 2, Key: []byte("3"), Value: []byte("msg3")},
+		{Offset: 3, Key: []byte("4"), Value: []byte("msg4")},
+		{Offset: 4, Key: []byte("5"), Value: []byte("msg5")},
 	}
 
 	mockReader := &MockKafkaReader{Messages: messages}
@@ -107,6 +167,434 @@ func TestProcessor_WorkerPool(t *testing.T) {
 	<-done
 
 	assert.Len(t, mockService.Processed, 5)
-	assert.Len(t, mockReader.CommittedMsgs, 5)
+	assert.NotEmpty(t, mockReader.CommittedMsgs)
+	assert.Equal(t, int64(4), mockReader.CommittedMsgs[len(mockReader.CommittedMsgs)-1].Offset)
+	for i := 1; i < len(mockReader.CommittedMsgs); i++ {
+		assert.Greater(t, mockReader.CommittedMsgs[i].Offset, mockReader.CommittedMsgs[i-1].Offset, "must commit in offset order")
+	}
 	assert.True(t, mockReader.CloseCalled)
 }
+
+func TestProcessor_DeadLetter(t *testing.T) {
+	t.Run("GIVEN retries exhausted WHEN processing THEN write to dead-letter topic and commit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		msg := kafka.Message{
+			Topic:     "logs",
+			Partition: 2,
+			Offset:    41,
+			Key:       []byte("1"),
+			Value:     []byte("msg1"),
+			Headers:   []kafka.Header{{Key: "trace-id", Value: []byte("abc")}},
+		}
+		mockReader := &MockKafkaReader{Messages: []kafka.Message{msg}}
+		mockService := &MockLogService{ShouldFail: true}
+		mockWriter := &MockKafkaWriter{}
+
+		processor := NewProcessor(mockReader, mockService, 1, 2, time.Millisecond)
+		processor.DeadLetter = mockWriter
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return len(mockWriter.Messages()) == 1 }, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		dlqMsg := mockWriter.Messages()[0]
+		headers := map[string]string{}
+		for _, h := range dlqMsg.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+
+		assert.Equal(t, []byte("1"), dlqMsg.Key)
+		assert.Equal(t, []byte("msg1"), dlqMsg.Value)
+		assert.Equal(t, "abc", headers["trace-id"])
+		assert.Equal(t, "processing failed", headers[HeaderDeadLetterError])
+		assert.Equal(t, "2", headers[HeaderDeadLetterAttempts])
+		assert.Equal(t, "logs", headers[HeaderDeadLetterTopic])
+		assert.Equal(t, "2", headers[HeaderDeadLetterPartition])
+		assert.Equal(t, "41", headers[HeaderDeadLetterOffset])
+		assert.NotEmpty(t, headers[HeaderDeadLetterFailedAt])
+		assert.Len(t, mockReader.CommittedMsgs, 1)
+	})
+
+	t.Run("GIVEN invalid log WHEN processing THEN dead-letter without retrying", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockReader := &MockKafkaReader{Messages: []kafka.Message{{Key: []byte("1"), Value: []byte(`{"level":"INFO"}`)}}}
+		mockService := &MockLogService{FailWith: service.ErrInvalidLog}
+		mockWriter := &MockKafkaWriter{}
+
+		processor := NewProcessor(mockReader, mockService, 1, 5, time.Millisecond)
+		processor.DeadLetter = mockWriter
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return len(mockWriter.Messages()) == 1 }, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		assert.Equal(t, 1, mockService.Calls)
+		assert.Len(t, mockReader.CommittedMsgs, 1)
+	})
+
+	t.Run("GIVEN dead-letter write fails WHEN processing THEN do not commit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockReader := &MockKafkaReader{Messages: []kafka.Message{{Offset: 0, Key: []byte("1"), Value: []byte("msg1")}}}
+		mockService := &MockLogService{ShouldFail: true}
+		mockWriter := &MockKafkaWriter{Err: errors.New("broker down")}
+
+		processor := NewProcessor(mockReader, mockService, 1, 1, time.Millisecond)
+		processor.DeadLetter = mockWriter
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		assert.Error(t, processor.Health(ctx))
+		cancel()
+		<-done
+
+		assert.Empty(t, mockReader.CommittedMsgs)
+	})
+
+	t.Run("GIVEN dead-letter write fails for a while WHEN processing THEN retry it until written and commit", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mockReader := &MockKafkaReader{Messages: []kafka.Message{{Offset: 0, Key: []byte("1"), Value: []byte("msg1")}}}
+		mockService := &MockLogService{ShouldFail: true}
+		mockWriter := &MockKafkaWriter{FailTimes: 2}
+
+		processor := NewProcessor(mockReader, mockService, 1, 1, time.Millisecond)
+		processor.DeadLetter = mockWriter
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return len(mockWriter.Messages()) == 1 }, 5*time.Second, 10*time.Millisecond)
+		assert.NoError(t, processor.Health(ctx))
+		cancel()
+		<-done
+
+		assert.Len(t, mockReader.CommittedMsgs, 1)
+	})
+}
+
+func TestProcessor_Batch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := []kafka.Message{
+		{Offset: 0, Key: []byte("1"), Value: []byte("msg1")},
+		{Offset: 1, Key: []byte("2"), Value: []byte("msg2")},
+		{Offset: 2, Key: []byte("3"), Value: []byte("msg3")},
+	}
+
+	mockReader := &MockKafkaReader{Messages: messages}
+	mockService := &MockLogService{FailTimes: map[string]int{"2": 1}}
+
+	processor := NewProcessor(mockReader, mockService, 1, 3, time.Millisecond)
+	processor.Batch = BatchConfig{MaxDocs: 3, MaxLatency: time.Second}
+
+	done := make(chan struct{})
+	go func() {
+		_ = processor.Start(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return mockService.ProcessedCount() == 3 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Len(t, mockService.Batches, 2)
+	assert.Len(t, mockService.Batches[0], 3)
+	assert.Len(t, mockService.Batches[1], 1, "must only retry the failed document")
+	assert.Equal(t, "2", mockService.Batches[1][0].ID)
+	assert.Len(t, mockReader.CommittedMsgs, 3)
+}
+
+func TestProcessor_OrderByKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var messages []kafka.Message
+	for i := 0; i < 6; i++ {
+		key := []string{"a", "b"}[i%2]
+		messages = append(messages, kafka.Message{
+			Offset: int64(i),
+			Key:    []byte(key),
+			Value:  []byte(fmt.Sprintf(`{"id":"%s-%d","message":"msg"}`, key, i)),
+		})
+	}
+
+	mockReader := &MockKafkaReader{Messages: messages}
+	mockService := &MockLogService{}
+
+	processor := NewProcessor(mockReader, mockService, 4, 1, time.Millisecond)
+	processor.OrderByKey = true
+
+	done := make(chan struct{})
+	go func() {
+		_ = processor.Start(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return mockService.ProcessedCount() == len(messages) }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	var orderA, orderB []string
+	for _, l := range mockService.Processed {
+		if strings.HasPrefix(l.ID, "a-") {
+			orderA = append(orderA, l.ID)
+		} else {
+			orderB = append(orderB, l.ID)
+		}
+	}
+	assert.Equal(t, []string{"a-0", "a-2", "a-4"}, orderA)
+	assert.Equal(t, []string{"b-1", "b-3", "b-5"}, orderB)
+	assert.Equal(t, int64(5), mockReader.CommittedMsgs[len(mockReader.CommittedMsgs)-1].Offset)
+}
+
+func TestProcessor_BreakerOpen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var healthy atomic.Bool
+	esBreaker := breaker.New("elasticsearch", 1, 5*time.Millisecond, func(ctx context.Context) error {
+		if healthy.Load() {
+			return nil
+		}
+		return errors.New("down")
+	})
+	esBreaker.Record(errors.New("down"))
+	go esBreaker.Run(ctx)
+
+	mockReader := &MockKafkaReader{Messages: []kafka.Message{{Offset: 0, Key: []byte("1"), Value: []byte("msg1")}}}
+	mockService := &MockLogService{}
+
+	processor := NewProcessor(mockReader, mockService, 1, 1, time.Millisecond)
+	processor.Breaker = esBreaker
+
+	done := make(chan struct{})
+	go func() {
+		_ = processor.Start(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, mockReader.ReadCount(), "must not read while the breaker is open")
+
+	healthy.Store(true)
+	assert.Eventually(t, func() bool { return mockService.ProcessedCount() == 1 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Len(t, mockReader.CommittedMsgs, 1)
+}
+
+type MockLogBuffer struct {
+	mu       sync.Mutex
+	Appended []service.Log
+}
+
+func (m *MockLogBuffer) Append(logs ...service.Log) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Appended = append(m.Appended, logs...)
+	return nil
+}
+
+func (m *MockLogBuffer) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Appended)
+}
+
+func TestProcessor_WAL(t *testing.T) {
+	for _, batch := range []BatchConfig{{}, {MaxDocs: 2, MaxLatency: 10 * time.Millisecond}} {
+		t.Run(fmt.Sprintf("GIVEN breaker open and batch %d WHEN processing THEN spill to WAL and commit", batch.MaxDocs), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			esBreaker := breaker.New("elasticsearch", 1, time.Hour, nil)
+			esBreaker.Record(errors.New("down"))
+
+			messages := []kafka.Message{
+				{Offset: 0, Key: []byte("1"), Value: []byte("msg1")},
+				{Offset: 1, Key: []byte("2"), Value: []byte("msg2")},
+			}
+			mockReader := &MockKafkaReader{Messages: messages}
+			mockService := &MockLogService{FailWith: breaker.ErrOpen, FailBatchWith: breaker.ErrOpen}
+			mockWAL := &MockLogBuffer{}
+
+			processor := NewProcessor(mockReader, mockService, 1, 3, time.Millisecond)
+			processor.Breaker = esBreaker
+			processor.WAL = mockWAL
+			processor.Batch = batch
+
+			done := make(chan struct{})
+			go func() {
+				_ = processor.Start(ctx)
+				close(done)
+			}()
+
+			assert.Eventually(t, func() bool { return mockWAL.Count() == 2 }, 5*time.Second, 10*time.Millisecond)
+			cancel()
+			<-done
+
+			assert.False(t, mockWAL.Appended[0].Timestamp.IsZero())
+			assert.Equal(t, int64(1), mockReader.CommittedMsgs[len(mockReader.CommittedMsgs)-1].Offset)
+		})
+	}
+}
+
+func TestProcessor_ProcessLog(t *testing.T) {
+	t.Run("GIVEN a transient failure WHEN processing a log from another input THEN retry it", func(t *testing.T) {
+		mockService := &MockLogService{FailTimes: map[string]int{"1": 2}}
+		processor := NewProcessor(&MockKafkaReader{}, mockService, 1, 3, time.Millisecond)
+
+		err := processor.ProcessLog(context.Background(), service.Log{ID: "1", Message: "msg1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, mockService.Calls)
+		assert.Equal(t, 1, mockService.ProcessedCount())
+	})
+
+	t.Run("GIVEN an invalid log WHEN processing THEN fail without retrying", func(t *testing.T) {
+		mockService := &MockLogService{FailWith: service.ErrInvalidLog}
+		processor := NewProcessor(&MockKafkaReader{}, mockService, 1, 3, time.Millisecond)
+
+		err := processor.ProcessLog(context.Background(), service.Log{ID: "1"})
+
+		assert.ErrorIs(t, err, service.ErrInvalidLog)
+		assert.Equal(t, 1, mockService.Calls)
+	})
+
+	t.Run("GIVEN breaker open and a WAL WHEN processing THEN spill the log", func(t *testing.T) {
+		esBreaker := breaker.New("elasticsearch", 1, time.Hour, nil)
+		esBreaker.Record(errors.New("down"))
+		mockWAL := &MockLogBuffer{}
+
+		processor := NewProcessor(&MockKafkaReader{}, &MockLogService{FailWith: breaker.ErrOpen}, 1, 3, time.Millisecond)
+		processor.Breaker = esBreaker
+		processor.WAL = mockWAL
+
+		err := processor.ProcessLog(context.Background(), service.Log{ID: "1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, mockWAL.Count())
+	})
+}
+
+func TestProcessor_ProcessBatch(t *testing.T) {
+	t.Run("GIVEN a batch from another input WHEN processing THEN retry only the failed logs in bulk", func(t *testing.T) {
+		mockService := &MockLogService{FailTimes: map[string]int{"2": 1}}
+		processor := NewProcessor(&MockKafkaReader{}, mockService, 1, 3, time.Millisecond)
+
+		errs := processor.ProcessBatch(context.Background(), []service.Log{
+			{ID: "1", Message: "msg1"},
+			{ID: "2", Message: "msg2"},
+		})
+
+		assert.Equal(t, []error{nil, nil}, errs)
+		assert.Len(t, mockService.Batches, 2)
+		assert.Len(t, mockService.Batches[1], 1)
+		assert.Equal(t, 2, mockService.ProcessedCount())
+	})
+
+	t.Run("GIVEN breaker open and a WAL WHEN processing a batch THEN spill it", func(t *testing.T) {
+		esBreaker := breaker.New("elasticsearch", 1, time.Hour, nil)
+		esBreaker.Record(errors.New("down"))
+		mockWAL := &MockLogBuffer{}
+
+		processor := NewProcessor(&MockKafkaReader{}, &MockLogService{FailBatchWith: breaker.ErrOpen}, 1, 3, time.Millisecond)
+		processor.Breaker = esBreaker
+		processor.WAL = mockWAL
+
+		errs := processor.ProcessBatch(context.Background(), []service.Log{{ID: "1"}, {ID: "2"}})
+
+		assert.Equal(t, []error{nil, nil}, errs)
+		assert.Equal(t, 2, mockWAL.Count())
+	})
+}
+
+func TestProcessor_Metrics(t *testing.T) {
+	t.Run("GIVEN processed and failed messages WHEN processing THEN count them per partition", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		labels := []string{"metrics-test", "3"}
+		mockReader := &MockKafkaReader{Messages: []kafka.Message{
+			{Topic: "metrics-test", Partition: 3, Offset: 0, HighWaterMark: 10, Key: []byte("1"), Value: []byte("msg1")},
+			{Topic: "metrics-test", Partition: 3, Offset: 1, HighWaterMark: 10, Key: []byte("2"), Value: []byte("msg2")},
+		}}
+		mockService := &MockLogService{FailTimes: map[string]int{"1": 1, "2": 10}}
+
+		processor := NewProcessor(mockReader, mockService, 1, 3, time.Millisecond)
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(metrics.MessagesCommitted.WithLabelValues(labels...)) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		assert.Equal(t, 2.0, testutil.ToFloat64(metrics.MessagesRead.WithLabelValues(labels...)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.MessagesProcessed.WithLabelValues(labels...)))
+		assert.Equal(t, 1.0, testutil.ToFloat64(metrics.MessagesFailed.WithLabelValues(labels...)))
+		assert.Equal(t, 3.0, testutil.ToFloat64(metrics.MessagesRetried.WithLabelValues(labels...)))
+		assert.Equal(t, 8.0, testutil.ToFloat64(metrics.ConsumerLag.WithLabelValues(labels...)))
+		assert.Equal(t, 0.0, testutil.ToFloat64(metrics.WorkersBusy))
+	})
+}
+
+func TestProcessor_State(t *testing.T) {
+	t.Run("GIVEN the processor lifecycle WHEN checking health THEN only report healthy while running", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		processor := NewProcessor(&MockKafkaReader{}, &MockLogService{}, 1, 1, time.Millisecond)
+		assert.Equal(t, StateIdle, processor.State())
+		assert.EqualError(t, processor.Health(ctx), "processor is idle")
+
+		done := make(chan struct{})
+		go func() {
+			_ = processor.Start(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return processor.State() == StateRunning }, time.Second, 5*time.Millisecond)
+		assert.NoError(t, processor.Health(ctx))
+
+		cancel()
+		<-done
+
+		assert.Equal(t, StateStopped, processor.State())
+		assert.Error(t, processor.Health(context.Background()))
+	})
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+)
+
+// Producer publishes logs to the topic the processor consumes, for sources
+// that cannot write to Kafka themselves.
+type Producer struct {
+	Writer KafkaWriter
+}
+
+func NewProducer(writer KafkaWriter) *Producer {
+	return &Producer{Writer: writer}
+}
+
+// ProcessBatch validates the entries like LogService does and publishes the
+// valid ones keyed by ID. The returned slice is aligned with logEntries and
+// holds nil for every entry that was published.
+func (p *Producer) ProcessBatch(ctx context.Context, logEntries []service.Log) []error {
+	errs := make([]error, len(logEntries))
+	msgs := make([]kafka.Message, 0, len(logEntries))
+	positions := make([]int, 0, len(logEntries))
+
+	for i, logEntry := range logEntries {
+		logEntry, err := service.PrepareLog(logEntry)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		value, err := service.EncodeLog(logEntry)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		msgs = append(msgs, kafka.Message{
+			Key:     []byte(logEntry.ID),
+			Value:   value,
+			Headers: []kafka.Header{{Key: HeaderLogFormat, Value: []byte(parser.FormatJSON)}},
+		})
+		positions = append(positions, i)
+	}
+
+	if len(msgs) == 0 {
+		return errs
+	}
+
+	err := p.Writer.WriteMessages(ctx, msgs...)
+	if err == nil {
+		return errs
+	}
+
+	// The writer reports partial failures message by message.
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == len(msgs) {
+		for i, writeErr := range writeErrs {
+			errs[positions[i]] = writeErr
+		}
+		return errs
+	}
+
+	for _, pos := range positions {
+		errs[pos] = err
+	}
+	return errs
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type partialWriter struct {
+	MockKafkaWriter
+	Errs kafka.WriteErrors
+}
+
+func (w *partialWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.MockKafkaWriter.WriteMessages(ctx, msgs...)
+	return w.Errs
+}
+
+func TestProducer_ProcessBatch(t *testing.T) {
+	t.Run("GIVEN valid and invalid logs WHEN publishing THEN write only the valid ones keyed by ID", func(t *testing.T) {
+		writer := &MockKafkaWriter{}
+		producer := NewProducer(writer)
+
+		errs := producer.ProcessBatch(context.Background(), []service.Log{
+			{ID: "1", Level: "INFO", Message: "first"},
+			{ID: "", Message: "no id"},
+			{ID: "3", Level: "ERROR", Message: "third"},
+		})
+
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], service.ErrInvalidLog)
+		assert.NoError(t, errs[2])
+
+		msgs := writer.Messages()
+		assert.Len(t, msgs, 2)
+		assert.Equal(t, "3", string(msgs[1].Key))
+		assert.Equal(t, parser.FormatJSON, headerValue(msgs[1].Headers, HeaderLogFormat))
+
+		var published service.Log
+		assert.NoError(t, json.Unmarshal(msgs[1].Value, &published))
+		assert.Equal(t, "third", published.Message)
+		assert.False(t, published.Timestamp.IsZero(), "must default the timestamp")
+	})
+
+	t.Run("GIVEN a structured log WHEN publishing and consuming it THEN keep its structure", func(t *testing.T) {
+		writer := &MockKafkaWriter{}
+		producer := NewProducer(writer)
+		sent := service.Log{
+			ID:         "1",
+			Level:      "ERROR",
+			Message:    "boom",
+			Source:     "checkout",
+			Timestamp:  time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			Attributes: map[string]interface{}{"trace_id": "abc", "span_id": "def"},
+			Tags:       []string{"t"},
+			Metadata:   map[string]string{"tenant": "acme"},
+		}
+
+		errs := producer.ProcessBatch(context.Background(), []service.Log{sent})
+		assert.NoError(t, errs[0])
+
+		msg := writer.Messages()[0]
+		msg.Topic = "logs"
+		processor := NewProcessor(&MockKafkaReader{}, &MockLogService{}, 1, 0, 0)
+		received := processor.decode(msg)
+
+		assert.Equal(t, parser.FormatJSON, received.Metadata[HeaderLogFormat], "headers are kept as metadata")
+		delete(received.Metadata, HeaderLogFormat)
+		received.Kafka = nil
+		assert.Equal(t, sent, received)
+	})
+
+	t.Run("GIVEN the writer fails some messages WHEN publishing THEN report them per entry", func(t *testing.T) {
+		writer := &partialWriter{Errs: kafka.WriteErrors{nil, kafka.NotEnoughReplicas}}
+		producer := NewProducer(writer)
+
+		errs := producer.ProcessBatch(context.Background(), []service.Log{
+			{ID: "1", Message: "first"},
+			{ID: "", Message: "no id"},
+			{ID: "3", Message: "third"},
+		})
+
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], service.ErrInvalidLog)
+		assert.ErrorIs(t, errs[2], kafka.NotEnoughReplicas)
+	})
+
+	t.Run("GIVEN the writer fails WHEN publishing THEN fail every valid entry", func(t *testing.T) {
+		producer := NewProducer(&MockKafkaWriter{Err: errors.New("broker down")})
+
+		errs := producer.ProcessBatch(context.Background(), []service.Log{{ID: "1", Message: "first"}, {ID: "2", Message: "second"}})
+
+		assert.EqualError(t, errs[0], "broker down")
+		assert.EqualError(t, errs[1], "broker down")
+	})
+}
@@ -3,12 +3,14 @@ package kafka
 import (
 	"context"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
 type MockKafkaReader struct {
+	mu            sync.Mutex
 	Messages      []kafka.Message
 	Index         int
 	CommittedMsgs []kafka.Message
@@ -16,6 +18,9 @@ type MockKafkaReader struct {
 }
 
 func (m *MockKafkaReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.Index >= len(m.Messages) {
 		// simula loop do Kafka sem travar o teste
 		select {
@@ -31,6 +36,12 @@ func (m *MockKafkaReader) ReadMessage(ctx context.Context) (kafka.Message, error
 	return msg, nil
 }
 
+func (m *MockKafkaReader) ReadCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Index
+}
+
 func (m *MockKafkaReader) CommitMessage(msg kafka.Message) error {
 	m.CommittedMsgs = append(m.CommittedMsgs, msg)
 	return nil
@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type MockKafkaWriter struct {
+	mu      sync.Mutex
+	Written []kafka.Message
+	Err     error
+	// FailTimes makes that many writes fail before they succeed.
+	FailTimes   int
+	CloseCalled bool
+}
+
+func (m *MockKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+	if m.FailTimes > 0 {
+		m.FailTimes--
+		return errors.New("broker down")
+	}
+
+	m.Written = append(m.Written, msgs...)
+	return nil
+}
+
+func (m *MockKafkaWriter) Messages() []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message{}, m.Written...)
+}
+
+func (m *MockKafkaWriter) Close() error {
+	m.CloseCalled = true
+	return nil
+}
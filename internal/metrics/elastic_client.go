@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// ElasticSearchClient records the latency of every call to the wrapped client.
+type ElasticSearchClient struct {
+	Next service.ElasticSearchClient
+}
+
+func NewElasticSearchClient(next service.ElasticSearchClient) *ElasticSearchClient {
+	return &ElasticSearchClient{Next: next}
+}
+
+func (c *ElasticSearchClient) Index(ctx context.Context, index string, id string, body interface{}) error {
+	start := time.Now()
+	err := c.Next.Index(ctx, index, id, body)
+	observeElastic("index", start, err)
+	return err
+}
+
+func (c *ElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	start := time.Now()
+	results, err := c.Next.BulkIndex(ctx, index, docs)
+	observeElastic("bulk", start, err)
+	return results, err
+}
+
+func (c *ElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	start := time.Now()
+	result, err := c.Next.SearchLogs(ctx, index, query)
+	observeElastic("search", start, err)
+	return result, err
+}
+
+func (c *ElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	start := time.Now()
+	err := c.Next.ClosePointInTime(ctx, id)
+	observeElastic("close_pit", start, err)
+	return err
+}
+
+func (c *ElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	start := time.Now()
+	result, err := c.Next.AggregateLogs(ctx, index, where, aggs)
+	observeElastic("aggregate", start, err)
+	return result, err
+}
+
+func observeElastic(operation string, start time.Time, err error) {
+	ElasticRequestDuration.WithLabelValues(operation, outcome(err)).Observe(time.Since(start).Seconds())
+}
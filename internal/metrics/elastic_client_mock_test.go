@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+type MockElasticSearchClient struct {
+	Err error
+}
+
+func (m *MockElasticSearchClient) Index(ctx context.Context, index string, id string, body interface{}) error {
+	return m.Err
+}
+
+func (m *MockElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	return nil, m.Err
+}
+
+func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	return service.SearchResult{}, m.Err
+}
+
+func (m *MockElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	return m.Err
+}
+
+func (m *MockElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	return service.AggregationResult{}, m.Err
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElasticSearchClient(t *testing.T) {
+	t.Run("GIVEN requests WHEN they complete THEN observe their latency per operation and outcome", func(t *testing.T) {
+		ElasticRequestDuration.Reset()
+		client := NewElasticSearchClient(&MockElasticSearchClient{})
+
+		assert.NoError(t, client.Index(context.Background(), "logs", "1", nil))
+		_, err := client.SearchLogs(context.Background(), "logs", service.Query{Size: 10})
+		assert.NoError(t, err)
+
+		client.Next = &MockElasticSearchClient{Err: errors.New("connection refused")}
+		_, err = client.BulkIndex(context.Background(), "logs", nil)
+		assert.Error(t, err)
+
+		assert.Equal(t, 3, testutil.CollectAndCount(ElasticRequestDuration))
+	})
+}
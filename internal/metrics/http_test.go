@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Run("GIVEN a parameterized route WHEN serving THEN count the request under its template", func(t *testing.T) {
+		r := mux.NewRouter()
+		r.Use(Middleware)
+		r.HandleFunc("/test/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}).Methods("GET")
+
+		for _, id := range []string{"1", "2"} {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test/"+id, nil))
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		}
+
+		assert.Equal(t, 2.0, testutil.ToFloat64(HTTPRequests.WithLabelValues("/test/{id}", "GET", "404")))
+		assert.Equal(t, 1, testutil.CollectAndCount(HTTPRequestDuration, "log_processor_http_request_duration_seconds"))
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("GIVEN registered collectors WHEN scraping THEN expose them", func(t *testing.T) {
+		MessagesRead.WithLabelValues("handler-test", "0").Inc()
+
+		rec := httptest.NewRecorder()
+		Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.True(t, strings.Contains(body, `log_processor_messages_read_total{partition="0",topic="handler-test"} 1`))
+		assert.True(t, strings.Contains(body, "go_goroutines"))
+	})
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "log_processor"
+
+// Registry holds every collector exposed on /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	MessagesRead = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_read_total",
+		Help:      "Messages read from Kafka.",
+	}, []string{"topic", "partition"})
+
+	MessagesProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_processed_total",
+		Help:      "Messages indexed in Elasticsearch or written to the WAL.",
+	}, []string{"topic", "partition"})
+
+	MessagesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_failed_total",
+		Help:      "Messages that failed permanently or ran out of retries.",
+	}, []string{"topic", "partition"})
+
+	MessagesRetried = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_retried_total",
+		Help:      "Retries spent on messages, not counting the first attempt.",
+	}, []string{"topic", "partition"})
+
+	MessagesCommitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_committed_total",
+		Help:      "Messages whose offset was committed to Kafka.",
+	}, []string{"topic", "partition"})
+
+	ConsumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "consumer_lag",
+		Help:      "Messages left in the partition after the last one read.",
+	}, []string{"topic", "partition"})
+
+	WorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_busy",
+		Help:      "Workers currently handling a message or a batch.",
+	})
+
+	WorkersMax = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workers_max",
+		Help:      "Size of the worker pool.",
+	})
+
+	ProcessingDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "processing_duration_seconds",
+		Help:      "Time spent handling a message, or a whole batch in bulk mode, retries included.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	})
+
+	ElasticRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "elasticsearch_request_duration_seconds",
+		Help:      "Latency of the requests sent to Elasticsearch.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "API requests served.",
+	}, []string{"route", "method", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesRead,
+		MessagesProcessed,
+		MessagesFailed,
+		MessagesRetried,
+		MessagesCommitted,
+		ConsumerLag,
+		WorkersBusy,
+		WorkersMax,
+		ProcessingDuration,
+		ElasticRequestDuration,
+		HTTPRequests,
+		HTTPRequestDuration,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,105 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// DecodeProtobuf decodes the binary encoding of an export request.
+func DecodeProtobuf(data []byte) (*collogspb.ExportLogsServiceRequest, error) {
+	req := &collogspb.ExportLogsServiceRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// DecodeJSON decodes the JSON encoding of an export request. It is the
+// Protobuf JSON mapping except for trace and span IDs, which OTLP sends as
+// hex instead of base64, so they are converted before protojson reads them.
+func DecodeJSON(data []byte) (*collogspb.ExportLogsServiceRequest, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	for _, resourceLogs := range objects(field(doc, "resourceLogs", "resource_logs")) {
+		for _, scopeLogs := range objects(field(resourceLogs, "scopeLogs", "scope_logs")) {
+			for _, record := range objects(field(scopeLogs, "logRecords", "log_records")) {
+				for _, key := range []string{"traceId", "trace_id", "spanId", "span_id"} {
+					if err := hexToBase64(record, key); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(converted, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// EncodeResponse encodes resp the same way the request was encoded.
+func EncodeResponse(resp proto.Message, contentType string) ([]byte, error) {
+	if contentType == ContentTypeJSON {
+		return protojson.Marshal(resp)
+	}
+	return proto.Marshal(resp)
+}
+
+func field(obj map[string]interface{}, names ...string) interface{} {
+	for _, name := range names {
+		if value, ok := obj[name]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+func objects(value interface{}) []map[string]interface{} {
+	items, _ := value.([]interface{})
+	objs := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(map[string]interface{}); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+func hexToBase64(obj map[string]interface{}, key string) error {
+	value, ok := obj[key].(string)
+	if !ok || value == "" {
+		return nil
+	}
+
+	id, err := hex.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: must be hex encoded", key, value)
+	}
+	obj[key] = base64.StdEncoding.EncodeToString(id)
+	return nil
+}
@@ -0,0 +1,167 @@
+package otlp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	// AttributeRecordUID is the semantic convention attribute that, when
+	// present, becomes the log ID.
+	AttributeRecordUID = "log.record.uid"
+	// AttributeServiceName is the resource attribute the source is read from.
+	AttributeServiceName = "service.name"
+)
+
+// Logs flattens an export request into one log per record. Resource and
+// scope details are kept on every log as attributes: the record attributes
+// at the top level, then otel_resource, otel_scope, trace_id, span_id and
+// the original otel_severity_number and otel_severity_text.
+func Logs(req *collogspb.ExportLogsServiceRequest) []service.Log {
+	var logEntries []service.Log
+	for _, resourceLogs := range req.GetResourceLogs() {
+		resource := resourceLogs.GetResource()
+		resourceAttrs := attributes(resource.GetAttributes())
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			scope := scopeLogs.GetScope()
+			for _, record := range scopeLogs.GetLogRecords() {
+				logEntries = append(logEntries, newLog(resource, resourceAttrs, scope, record))
+			}
+		}
+	}
+	return logEntries
+}
+
+func newLog(resource *resourcepb.Resource, resourceAttrs map[string]interface{}, scope *commonpb.InstrumentationScope, record *logspb.LogRecord) service.Log {
+	logEntry := service.Log{
+		ID:         recordID(resource, scope, record),
+		Level:      SeverityLevel(record.GetSeverityNumber(), record.GetSeverityText()),
+		Message:    bodyText(record.GetBody()),
+		Attributes: attributes(record.GetAttributes()),
+	}
+
+	if source, ok := resourceAttrs[AttributeServiceName].(string); ok {
+		logEntry.Source = source
+	}
+
+	if ts := record.GetTimeUnixNano(); ts != 0 {
+		logEntry.Timestamp = time.Unix(0, int64(ts)).UTC()
+	} else if ts := record.GetObservedTimeUnixNano(); ts != 0 {
+		logEntry.Timestamp = time.Unix(0, int64(ts)).UTC()
+	}
+
+	if logEntry.Attributes == nil {
+		logEntry.Attributes = make(map[string]interface{})
+	}
+	if len(resourceAttrs) > 0 {
+		logEntry.Attributes["otel_resource"] = resourceAttrs
+	}
+	if scope != nil {
+		scopeAttrs := map[string]interface{}{"name": scope.GetName()}
+		if scope.GetVersion() != "" {
+			scopeAttrs["version"] = scope.GetVersion()
+		}
+		if attrs := attributes(scope.GetAttributes()); len(attrs) > 0 {
+			scopeAttrs["attributes"] = attrs
+		}
+		logEntry.Attributes["otel_scope"] = scopeAttrs
+	}
+	if len(record.GetTraceId()) > 0 {
+		logEntry.Attributes["trace_id"] = hex.EncodeToString(record.GetTraceId())
+	}
+	if len(record.GetSpanId()) > 0 {
+		logEntry.Attributes["span_id"] = hex.EncodeToString(record.GetSpanId())
+	}
+	if record.GetSeverityNumber() != logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED {
+		logEntry.Attributes["otel_severity_number"] = int32(record.GetSeverityNumber())
+	}
+	if record.GetSeverityText() != "" {
+		logEntry.Attributes["otel_severity_text"] = record.GetSeverityText()
+	}
+
+	return logEntry
+}
+
+// recordID uses log.record.uid when the sender sets it. Otherwise the ID is
+// a hash of the record and where it came from, so a retried export
+// overwrites the logs it already stored instead of duplicating them.
+func recordID(resource *resourcepb.Resource, scope *commonpb.InstrumentationScope, record *logspb.LogRecord) string {
+	for _, kv := range record.GetAttributes() {
+		if kv.GetKey() == AttributeRecordUID && kv.GetValue().GetStringValue() != "" {
+			return kv.GetValue().GetStringValue()
+		}
+	}
+
+	hash := sha256.New()
+	opts := proto.MarshalOptions{Deterministic: true}
+	for _, msg := range []proto.Message{resource, scope, record} {
+		data, _ := opts.Marshal(msg)
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// bodyText is the message of the log: string bodies as they are, anything
+// else as JSON.
+func bodyText(body *commonpb.AnyValue) string {
+	if body == nil {
+		return ""
+	}
+	if s, ok := body.GetValue().(*commonpb.AnyValue_StringValue); ok {
+		return s.StringValue
+	}
+
+	data, err := json.Marshal(anyValue(body))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func attributes(kvs []*commonpb.KeyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.GetKey()] = anyValue(kv.GetValue())
+	}
+	return attrs
+}
+
+func anyValue(value *commonpb.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		items := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			items = append(items, anyValue(item))
+		}
+		return items
+	case *commonpb.AnyValue_KvlistValue:
+		return attributes(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
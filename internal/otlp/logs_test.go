@@ -0,0 +1,140 @@
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func exportRequest(records ...*logspb.LogRecord) *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				{Key: "service.name", Value: stringValue("checkout")},
+				{Key: "host.name", Value: stringValue("node-1")},
+			}},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: "payments-logger", Version: "1.2.0"},
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+const jsonRequest = `{
+  "resourceLogs": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+    "scopeLogs": [{
+      "scope": {"name": "payments-logger"},
+      "logRecords": [{
+        "timeUnixNano": "1718013600000000000",
+        "severityNumber": 17,
+        "severityText": "Error",
+        "traceId": "5b8efff798038103d269b633813fc60c",
+        "spanId": "eee19b7ec3c1b174",
+        "body": {"stringValue": "card declined"},
+        "attributes": [
+          {"key": "retries", "value": {"intValue": "3"}},
+          {"key": "log.record.uid", "value": {"stringValue": "01J0ABC"}}
+        ]
+      }]
+    }]
+  }]
+}`
+
+func TestLogs(t *testing.T) {
+	t.Run("GIVEN a record WHEN converting THEN map severity, body, source, IDs and attributes", func(t *testing.T) {
+		record := &logspb.LogRecord{
+			TimeUnixNano:   uint64(time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC).UnixNano()),
+			SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_WARN2,
+			SeverityText:   "WARN2",
+			Body:           stringValue("slow query"),
+			TraceId:        []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c},
+			SpanId:         []byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74},
+			Attributes: []*commonpb.KeyValue{
+				{Key: "db.system", Value: stringValue("postgresql")},
+				{Key: "duration_ms", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 812.5}}},
+			},
+		}
+
+		logEntries := Logs(exportRequest(record))
+
+		require.Len(t, logEntries, 1)
+		logEntry := logEntries[0]
+		assert.Len(t, logEntry.ID, 32)
+		assert.Equal(t, "WARN", logEntry.Level)
+		assert.Equal(t, "slow query", logEntry.Message)
+		assert.Equal(t, "checkout", logEntry.Source)
+		assert.Equal(t, time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC), logEntry.Timestamp)
+		assert.Equal(t, "postgresql", logEntry.Attributes["db.system"])
+		assert.Equal(t, 812.5, logEntry.Attributes["duration_ms"])
+		assert.Equal(t, "5b8efff798038103d269b633813fc60c", logEntry.Attributes["trace_id"])
+		assert.Equal(t, "eee19b7ec3c1b174", logEntry.Attributes["span_id"])
+		assert.Equal(t, int32(14), logEntry.Attributes["otel_severity_number"])
+		assert.Equal(t, "WARN2", logEntry.Attributes["otel_severity_text"])
+		assert.Equal(t, map[string]interface{}{"service.name": "checkout", "host.name": "node-1"}, logEntry.Attributes["otel_resource"])
+		assert.Equal(t, map[string]interface{}{"name": "payments-logger", "version": "1.2.0"}, logEntry.Attributes["otel_scope"])
+
+		again := Logs(exportRequest(proto.Clone(record).(*logspb.LogRecord)))
+		assert.Equal(t, logEntry.ID, again[0].ID, "must derive the same ID for a retried export")
+	})
+
+	t.Run("GIVEN a structured body and no timestamp WHEN converting THEN encode the body and use the observed time", func(t *testing.T) {
+		observed := time.Date(2024, 6, 10, 11, 0, 0, 0, time.UTC)
+		record := &logspb.LogRecord{
+			ObservedTimeUnixNano: uint64(observed.UnixNano()),
+			Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{
+				Values: []*commonpb.KeyValue{{Key: "event", Value: stringValue("login")}},
+			}}},
+		}
+
+		logEntry := Logs(exportRequest(record))[0]
+
+		assert.Equal(t, `{"event":"login"}`, logEntry.Message)
+		assert.Equal(t, observed, logEntry.Timestamp)
+		assert.Equal(t, "INFO", logEntry.Level)
+	})
+
+	t.Run("GIVEN the JSON encoding WHEN decoding THEN read hex IDs and int64 strings", func(t *testing.T) {
+		req, err := DecodeJSON([]byte(jsonRequest))
+		require.NoError(t, err)
+
+		logEntry := Logs(req)[0]
+
+		assert.Equal(t, "01J0ABC", logEntry.ID)
+		assert.Equal(t, "ERROR", logEntry.Level)
+		assert.Equal(t, "card declined", logEntry.Message)
+		assert.Equal(t, "checkout", logEntry.Source)
+		assert.Equal(t, time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC), logEntry.Timestamp)
+		assert.Equal(t, int64(3), logEntry.Attributes["retries"])
+		assert.Equal(t, "5b8efff798038103d269b633813fc60c", logEntry.Attributes["trace_id"])
+		assert.Equal(t, "eee19b7ec3c1b174", logEntry.Attributes["span_id"])
+	})
+
+	t.Run("GIVEN a trace ID that is not hex WHEN decoding JSON THEN fail", func(t *testing.T) {
+		_, err := DecodeJSON([]byte(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"traceId":"not-hex"}]}]}]}`))
+
+		assert.ErrorContains(t, err, "traceId")
+	})
+
+	t.Run("GIVEN the protobuf encoding WHEN decoding THEN round trip the request", func(t *testing.T) {
+		data, err := proto.Marshal(exportRequest(&logspb.LogRecord{Body: stringValue("hello")}))
+		require.NoError(t, err)
+
+		req, err := DecodeProtobuf(data)
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello", Logs(req)[0].Message)
+	})
+}
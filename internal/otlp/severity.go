@@ -0,0 +1,61 @@
+package otlp
+
+import (
+	"strings"
+
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// SeverityLevel maps an OpenTelemetry severity onto the log levels used by
+// the rest of the pipeline:
+//
+//	number   OTEL name       level
+//	1-4      TRACE..TRACE4   DEBUG
+//	5-8      DEBUG..DEBUG4   DEBUG
+//	9-12     INFO..INFO4     INFO
+//	13-16    WARN..WARN4     WARN
+//	17-20    ERROR..ERROR4   ERROR
+//	21-24    FATAL..FATAL4   FATAL
+//
+// TRACE folds into DEBUG because the pipeline has no finer level; the
+// original number is kept on the log as an attribute. When the number is
+// unspecified (0) or out of range, the severity text is used if it names a
+// known level, and INFO otherwise.
+func SeverityLevel(number logspb.SeverityNumber, text string) string {
+	switch {
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE && number <= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG4:
+		return "DEBUG"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_INFO && number <= logspb.SeverityNumber_SEVERITY_NUMBER_INFO4:
+		return "INFO"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN && number <= logspb.SeverityNumber_SEVERITY_NUMBER_WARN4:
+		return "WARN"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR && number <= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4:
+		return "ERROR"
+	case number >= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL && number <= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4:
+		return "FATAL"
+	}
+
+	if level, ok := severityTexts[strings.ToUpper(strings.TrimSpace(text))]; ok {
+		return level
+	}
+	return "INFO"
+}
+
+// severityTexts holds the severity texts commonly sent without a number.
+var severityTexts = map[string]string{
+	"TRACE":       "DEBUG",
+	"DEBUG":       "DEBUG",
+	"INFO":        "INFO",
+	"INFORMATION": "INFO",
+	"NOTICE":      "INFO",
+	"WARN":        "WARN",
+	"WARNING":     "WARN",
+	"ERROR":       "ERROR",
+	"ERR":         "ERROR",
+	"FATAL":       "FATAL",
+	"CRITICAL":    "FATAL",
+	"CRIT":        "FATAL",
+	"ALERT":       "FATAL",
+	"EMERGENCY":   "FATAL",
+	"PANIC":       "FATAL",
+}
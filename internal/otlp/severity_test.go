@@ -0,0 +1,43 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestSeverityLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		number   logspb.SeverityNumber
+		text     string
+		expected string
+	}{
+		{"trace folds into debug", logspb.SeverityNumber_SEVERITY_NUMBER_TRACE, "", "DEBUG"},
+		{"trace4", logspb.SeverityNumber_SEVERITY_NUMBER_TRACE4, "", "DEBUG"},
+		{"debug", logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG, "", "DEBUG"},
+		{"debug4", logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG4, "", "DEBUG"},
+		{"info", logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "", "INFO"},
+		{"info4", logspb.SeverityNumber_SEVERITY_NUMBER_INFO4, "", "INFO"},
+		{"warn", logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "", "WARN"},
+		{"warn3", logspb.SeverityNumber_SEVERITY_NUMBER_WARN3, "", "WARN"},
+		{"error", logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "", "ERROR"},
+		{"error2", logspb.SeverityNumber_SEVERITY_NUMBER_ERROR2, "", "ERROR"},
+		{"fatal", logspb.SeverityNumber_SEVERITY_NUMBER_FATAL, "", "FATAL"},
+		{"fatal4", logspb.SeverityNumber_SEVERITY_NUMBER_FATAL4, "", "FATAL"},
+		{"number wins over text", logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, "info", "ERROR"},
+		{"unspecified uses text", logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "Warning", "WARN"},
+		{"unspecified critical", logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "critical", "FATAL"},
+		{"unspecified trace text", logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, " TRACE ", "DEBUG"},
+		{"unknown text defaults to info", logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "verbose", "INFO"},
+		{"nothing defaults to info", logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "", "INFO"},
+		{"out of range uses text", logspb.SeverityNumber(25), "error", "ERROR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, SeverityLevel(tt.number, tt.text))
+		})
+	}
+}
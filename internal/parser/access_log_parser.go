@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Matches the common log format, optionally followed by the referer and user
+// agent of the combined format used by both nginx and apache.
+var accessLogPattern = regexp.MustCompile(
+	`^(\S+) (\S+) (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-)(?: "([^"]*)" "([^"]*)")?`,
+)
+
+var errNotAccessLog = errors.New("payload does not match the common/combined access log format")
+
+// AccessLogParser parses nginx and apache access log lines. The level is
+// derived from the response status.
+type AccessLogParser struct{}
+
+func (AccessLogParser) Parse(data []byte) (service.Log, error) {
+	line := strings.TrimSpace(string(data))
+	match := accessLogPattern.FindStringSubmatch(line)
+	if match == nil {
+		return service.Log{}, errNotAccessLog
+	}
+
+	ts, err := time.Parse(accessLogTimeLayout, match[4])
+	if err != nil {
+		return service.Log{}, err
+	}
+	status, _ := strconv.Atoi(match[6])
+
+	logEntry := service.Log{
+		Timestamp: ts.UTC(),
+		Level:     levelForStatus(status),
+		Message:   line,
+		Attributes: map[string]interface{}{
+			"client_ip": match[1],
+			"status":    status,
+		},
+	}
+
+	optional := map[string]string{
+		"ident":      match[2],
+		"user":       match[3],
+		"referer":    match[8],
+		"user_agent": match[9],
+	}
+	for key, value := range optional {
+		if value != "" && value != "-" {
+			logEntry.Attributes[key] = value
+		}
+	}
+
+	if bytes, err := strconv.Atoi(match[7]); err == nil {
+		logEntry.Attributes["bytes"] = bytes
+	}
+
+	if parts := strings.Fields(match[5]); len(parts) == 3 {
+		logEntry.Attributes["method"] = parts[0]
+		logEntry.Attributes["path"] = parts[1]
+		logEntry.Attributes["protocol"] = parts[2]
+	} else {
+		logEntry.Attributes["request"] = match[5]
+	}
+
+	return logEntry, nil
+}
+
+func levelForStatus(status int) string {
+	switch {
+	case status >= 500:
+		return "ERROR"
+	case status >= 400:
+		return "WARN"
+	default:
+		return "INFO"
+	}
+}
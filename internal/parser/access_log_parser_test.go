@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogParser_Parse(t *testing.T) {
+	t.Run("GIVEN combined log line WHEN parse THEN extract request fields", func(t *testing.T) {
+		line := `203.0.113.9 - frank [10/Oct/2024:13:55:36 -0700] "GET /checkout?id=1 HTTP/1.1" 502 2326 "https://example.com/" "Mozilla/5.0"`
+
+		logEntry, err := AccessLogParser{}.Parse([]byte(line))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ERROR", logEntry.Level)
+		assert.Equal(t, line, logEntry.Message)
+		assert.Equal(t, time.Date(2024, 10, 10, 20, 55, 36, 0, time.UTC), logEntry.Timestamp)
+		assert.Equal(t, "203.0.113.9", logEntry.Attributes["client_ip"])
+		assert.Equal(t, "frank", logEntry.Attributes["user"])
+		assert.Equal(t, "GET", logEntry.Attributes["method"])
+		assert.Equal(t, "/checkout?id=1", logEntry.Attributes["path"])
+		assert.Equal(t, 502, logEntry.Attributes["status"])
+		assert.Equal(t, 2326, logEntry.Attributes["bytes"])
+		assert.Equal(t, "Mozilla/5.0", logEntry.Attributes["user_agent"])
+		assert.NotContains(t, logEntry.Attributes, "ident")
+	})
+
+	t.Run("GIVEN common log line WHEN parse THEN referer is optional", func(t *testing.T) {
+		line := `127.0.0.1 - - [10/Oct/2024:13:55:36 +0000] "GET /missing HTTP/1.0" 404 -`
+
+		logEntry, err := AccessLogParser{}.Parse([]byte(line))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "WARN", logEntry.Level)
+		assert.NotContains(t, logEntry.Attributes, "bytes")
+		assert.NotContains(t, logEntry.Attributes, "referer")
+	})
+
+	t.Run("GIVEN other text WHEN parse THEN return error", func(t *testing.T) {
+		_, err := AccessLogParser{}.Parse([]byte("hello world"))
+		assert.Error(t, err)
+	})
+}
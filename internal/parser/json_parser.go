@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+var errNotJSONObject = errors.New("payload is not a JSON object")
+
+// JSONParser maps the id, level, message (or msg), source and timestamp keys of a JSON
+// object onto the log and keeps every other key as an attribute. The
+// attributes, tags and metadata keys of an encoded service.Log, as published
+// by kafka.Producer, are read back into their fields. An object
+// without a message keeps the raw payload as message, tagged like a payload
+// that could not be parsed.
+type JSONParser struct{}
+
+func (JSONParser) Parse(data []byte) (service.Log, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return service.Log{}, errNotJSONObject
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return service.Log{}, err
+	}
+
+	var logEntry service.Log
+	for key, value := range fields {
+		switch key {
+		case "id":
+			logEntry.ID = stringValue(value)
+		case "level":
+			logEntry.Level = stringValue(value)
+		case "message":
+			logEntry.Message = stringValue(value)
+		case "msg":
+			if _, ok := fields["message"]; ok {
+				setAttribute(&logEntry, key, value)
+				continue
+			}
+			logEntry.Message = stringValue(value)
+		case "source":
+			logEntry.Source = stringValue(value)
+		case "timestamp":
+			if ts, ok := timeValue(value); ok {
+				logEntry.Timestamp = ts
+				continue
+			}
+			setAttribute(&logEntry, key, value)
+		case "attributes":
+			if attributes, ok := value.(map[string]interface{}); ok {
+				for k, v := range attributes {
+					setAttribute(&logEntry, k, v)
+				}
+				continue
+			}
+			setAttribute(&logEntry, key, value)
+		case "tags":
+			if tags, ok := stringSlice(value); ok {
+				logEntry.Tags = append(logEntry.Tags, tags...)
+				continue
+			}
+			setAttribute(&logEntry, key, value)
+		case "metadata":
+			if metadata, ok := stringMap(value); ok {
+				logEntry.Metadata = metadata
+				continue
+			}
+			setAttribute(&logEntry, key, value)
+		default:
+			setAttribute(&logEntry, key, value)
+		}
+	}
+
+	keepRawMessage(&logEntry, data, FormatJSON)
+	return logEntry, nil
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+func stringSlice(value interface{}) ([]string, bool) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, s)
+	}
+	return strs, true
+}
+
+func stringMap(value interface{}) (map[string]string, bool) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	strs := make(map[string]string, len(values))
+	for k, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		strs[k] = s
+	}
+	return strs, true
+}
+
+// timeValue accepts RFC 3339 strings and numeric Unix epochs in milliseconds.
+func timeValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return ts.UTC(), true
+	case json.Number:
+		ms, err := strconv.ParseInt(v.String(), 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.UnixMilli(ms).UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
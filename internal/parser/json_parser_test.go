@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONParser_Parse(t *testing.T) {
+	t.Run("GIVEN structured JSON WHEN parse THEN map fields and attributes", func(t *testing.T) {
+		data := []byte(`{"id":"20240101-1","level":"ERROR","message":"boom","timestamp":"2024-01-01T10:00:00Z","user_id":42,"labels":["a"]}`)
+
+		logEntry, err := JSONParser{}.Parse(data)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "20240101-1", logEntry.ID)
+		assert.Equal(t, "ERROR", logEntry.Level)
+		assert.Equal(t, "boom", logEntry.Message)
+		assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), logEntry.Timestamp)
+		assert.Equal(t, json.Number("42"), logEntry.Attributes["user_id"])
+		assert.Equal(t, []interface{}{"a"}, logEntry.Attributes["labels"])
+	})
+
+	t.Run("GIVEN an encoded log WHEN parse THEN read attributes, tags and metadata back into their fields", func(t *testing.T) {
+		data := []byte(`{"message":"boom","attributes":{"trace_id":"abc"},"tags":["t"],"metadata":{"tenant":"acme"},"user_id":42}`)
+
+		logEntry, err := JSONParser{}.Parse(data)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"trace_id": "abc", "user_id": json.Number("42")}, logEntry.Attributes)
+		assert.Equal(t, []string{"t"}, logEntry.Tags)
+		assert.Equal(t, map[string]string{"tenant": "acme"}, logEntry.Metadata)
+	})
+
+	t.Run("GIVEN attributes, tags or metadata of another shape WHEN parse THEN keep them as attributes", func(t *testing.T) {
+		logEntry, err := JSONParser{}.Parse([]byte(`{"message":"boom","attributes":"none","tags":[1],"metadata":{"retries":3}}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "none", logEntry.Attributes["attributes"])
+		assert.Equal(t, []interface{}{json.Number("1")}, logEntry.Attributes["tags"])
+		assert.Equal(t, map[string]interface{}{"retries": json.Number("3")}, logEntry.Attributes["metadata"])
+		assert.Empty(t, logEntry.Tags)
+		assert.Empty(t, logEntry.Metadata)
+	})
+
+	t.Run("GIVEN unparseable timestamp WHEN parse THEN keep it as attribute", func(t *testing.T) {
+		logEntry, err := JSONParser{}.Parse([]byte(`{"message":"hello","timestamp":"yesterday"}`))
+
+		assert.NoError(t, err)
+		assert.True(t, logEntry.Timestamp.IsZero())
+		assert.Equal(t, "yesterday", logEntry.Attributes["timestamp"])
+	})
+
+	t.Run("GIVEN epoch millis timestamp WHEN parse THEN convert it", func(t *testing.T) {
+		logEntry, err := JSONParser{}.Parse([]byte(`{"message":"hello","timestamp":1704103200000}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), logEntry.Timestamp)
+	})
+
+	t.Run("GIVEN JSON without message WHEN parse THEN keep the raw payload tagged as a parse failure", func(t *testing.T) {
+		data := `{"id":"20240101-1","level":"INFO","text":"hello"}`
+
+		logEntry, err := JSONParser{}.Parse([]byte(data))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "20240101-1", logEntry.ID)
+		assert.Equal(t, "INFO", logEntry.Level)
+		assert.Equal(t, data, logEntry.Message)
+		assert.Equal(t, []string{TagParseFailure}, logEntry.Tags)
+		assert.Equal(t, "payload has no message", logEntry.Attributes["parse_error"])
+		assert.Equal(t, FormatJSON, logEntry.Attributes["parse_format"])
+		assert.Equal(t, "hello", logEntry.Attributes["text"])
+	})
+
+	t.Run("GIVEN msg instead of message WHEN parse THEN use it as message", func(t *testing.T) {
+		logEntry, err := JSONParser{}.Parse([]byte(`{"level":"INFO","msg":"hello"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", logEntry.Message)
+		assert.Empty(t, logEntry.Tags)
+	})
+
+	t.Run("GIVEN both msg and message WHEN parse THEN prefer message", func(t *testing.T) {
+		logEntry, err := JSONParser{}.Parse([]byte(`{"msg":"short","message":"hello"}`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", logEntry.Message)
+		assert.Equal(t, "short", logEntry.Attributes["msg"])
+	})
+
+	t.Run("GIVEN plain text or broken JSON WHEN parse THEN return error", func(t *testing.T) {
+		_, err := JSONParser{}.Parse([]byte("plain text log"))
+		assert.Error(t, err)
+
+		_, err = JSONParser{}.Parse([]byte(`{"message":`))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+var errEmptyLogfmt = errors.New("no logfmt pairs found")
+
+// LogfmtParser parses key=value lines such as
+// `level=error msg="payment failed" user=42`.
+type LogfmtParser struct{}
+
+func (LogfmtParser) Parse(data []byte) (service.Log, error) {
+	line := strings.TrimSpace(string(data))
+	pairs, err := splitLogfmt(line)
+	if err != nil {
+		return service.Log{}, err
+	}
+	if len(pairs) == 0 {
+		return service.Log{}, errEmptyLogfmt
+	}
+
+	var logEntry service.Log
+	for _, kv := range pairs {
+		key, value := kv[0], kv[1]
+		switch strings.ToLower(key) {
+		case "id":
+			logEntry.ID = value
+		case "level", "lvl", "severity":
+			logEntry.Level = value
+		case "msg", "message":
+			logEntry.Message = value
+		case "source":
+			logEntry.Source = value
+		case "time", "ts", "timestamp":
+			if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				logEntry.Timestamp = ts.UTC()
+				continue
+			}
+			setAttribute(&logEntry, key, value)
+		default:
+			setAttribute(&logEntry, key, value)
+		}
+	}
+
+	keepRawMessage(&logEntry, []byte(line), FormatLogfmt)
+	return logEntry, nil
+}
+
+func splitLogfmt(line string) ([][2]string, error) {
+	var pairs [][2]string
+
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("empty key at position %d", start)
+		}
+
+		if i >= len(line) || line[i] == ' ' {
+			pairs = append(pairs, [2]string{key, "true"})
+			continue
+		}
+		i++ // '='
+
+		if i < len(line) && line[i] == '"' {
+			value, next, err := readQuoted(line, i)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, [2]string{key, value})
+			i = next
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		pairs = append(pairs, [2]string{key, line[start:i]})
+	}
+
+	return pairs, nil
+}
+
+// readQuoted reads the quoted string starting at line[start] and returns its
+// unescaped value and the position right after the closing quote.
+func readQuoted(line string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if i+1 < len(line) {
+				i++
+				switch line[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(line[i])
+				}
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted value at position %d", start)
+}
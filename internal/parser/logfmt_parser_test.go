@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogfmtParser_Parse(t *testing.T) {
+	t.Run("GIVEN logfmt line WHEN parse THEN map known keys and attributes", func(t *testing.T) {
+		data := []byte(`ts=2024-01-01T10:00:00Z level=error msg="payment \"failed\"" user=42 retry`)
+
+		logEntry, err := LogfmtParser{}.Parse(data)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "error", logEntry.Level)
+		assert.Equal(t, `payment "failed"`, logEntry.Message)
+		assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), logEntry.Timestamp)
+		assert.Equal(t, "42", logEntry.Attributes["user"])
+		assert.Equal(t, "true", logEntry.Attributes["retry"])
+	})
+
+	t.Run("GIVEN logfmt line without msg WHEN parse THEN keep the raw line tagged as a parse failure", func(t *testing.T) {
+		logEntry, err := LogfmtParser{}.Parse([]byte("level=info user=42\n"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "info", logEntry.Level)
+		assert.Equal(t, "level=info user=42", logEntry.Message)
+		assert.Equal(t, []string{TagParseFailure}, logEntry.Tags)
+		assert.Equal(t, FormatLogfmt, logEntry.Attributes["parse_format"])
+	})
+
+	t.Run("GIVEN unterminated quote WHEN parse THEN return error", func(t *testing.T) {
+		_, err := LogfmtParser{}.Parse([]byte(`msg="oops`))
+		assert.Error(t, err)
+	})
+
+	t.Run("GIVEN empty line WHEN parse THEN return error", func(t *testing.T) {
+		_, err := LogfmtParser{}.Parse([]byte("   "))
+		assert.Error(t, err)
+	})
+}
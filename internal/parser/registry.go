@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	FormatAuto      = "auto"
+	FormatJSON      = "json"
+	FormatLogfmt    = "logfmt"
+	FormatSyslog    = "syslog"
+	FormatAccessLog = "access"
+	FormatRaw       = "raw"
+
+	// TagParseFailure is added to the documents whose payload could not be
+	// parsed with the selected format. The raw payload is kept as message.
+	TagParseFailure = "_parse_failure"
+)
+
+var errMissingMessage = errors.New("payload has no message")
+
+type Parser interface {
+	Parse(data []byte) (service.Log, error)
+}
+
+// Hints carries what is known about a payload's origin. The format is picked
+// from, in order: Format, ContentType, the format registered for Topic and
+// finally the registry default.
+type Hints struct {
+	Format      string
+	ContentType string
+	Topic       string
+}
+
+type Registry struct {
+	mu            sync.RWMutex
+	parsers       map[string]Parser
+	topicFormats  map[string]string
+	defaultFormat string
+}
+
+var contentTypeFormats = map[string]string{
+	"application/json":     FormatJSON,
+	"text/json":            FormatJSON,
+	"application/logfmt":   FormatLogfmt,
+	"text/x-logfmt":        FormatLogfmt,
+	"application/syslog":   FormatSyslog,
+	"text/syslog":          FormatSyslog,
+	"text/x-access-log":    FormatAccessLog,
+	"text/plain":           FormatRaw,
+	"application/x-ndjson": FormatJSON,
+}
+
+// NewRegistry returns a registry with every built-in parser registered and
+// FormatAuto as default.
+func NewRegistry() *Registry {
+	r := &Registry{
+		parsers:       make(map[string]Parser),
+		topicFormats:  make(map[string]string),
+		defaultFormat: FormatAuto,
+	}
+
+	r.Register(FormatAuto, AutoParser{})
+	r.Register(FormatJSON, JSONParser{})
+	r.Register(FormatLogfmt, LogfmtParser{})
+	r.Register(FormatSyslog, SyslogParser{})
+	r.Register(FormatAccessLog, AccessLogParser{})
+	r.Register(FormatRaw, RawParser{})
+
+	return r
+}
+
+func (r *Registry) Register(format string, p Parser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsers[normalize(format)] = p
+}
+
+func (r *Registry) SetTopicFormat(topic, format string) error {
+	if _, ok := r.lookup(format); !ok {
+		return fmt.Errorf("unknown log format %q for topic %s", format, topic)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topicFormats[topic] = normalize(format)
+	return nil
+}
+
+func (r *Registry) SetDefault(format string) error {
+	if _, ok := r.lookup(format); !ok {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultFormat = normalize(format)
+	return nil
+}
+
+// Resolve returns the format and parser to use for a payload.
+func (r *Registry) Resolve(hints Hints) (string, Parser) {
+	if p, ok := r.lookup(hints.Format); ok {
+		return normalize(hints.Format), p
+	}
+
+	if hints.ContentType != "" {
+		mediaType := normalize(strings.SplitN(hints.ContentType, ";", 2)[0])
+		if format, ok := contentTypeFormats[mediaType]; ok {
+			if p, ok := r.lookup(format); ok {
+				return format, p
+			}
+		}
+	}
+
+	r.mu.RLock()
+	format, ok := r.topicFormats[hints.Topic]
+	if !ok {
+		format = r.defaultFormat
+	}
+	r.mu.RUnlock()
+
+	if p, ok := r.lookup(format); ok {
+		return format, p
+	}
+	return FormatRaw, RawParser{}
+}
+
+// Parse never drops a payload: when the selected parser fails the raw payload
+// is returned as message, tagged with TagParseFailure and the error.
+func (r *Registry) Parse(data []byte, hints Hints) service.Log {
+	format, p := r.Resolve(hints)
+
+	logEntry, err := p.Parse(data)
+	if err == nil {
+		return logEntry
+	}
+
+	return service.Log{
+		Message: string(data),
+		Tags:    []string{TagParseFailure},
+		Attributes: map[string]interface{}{
+			"parse_error":  err.Error(),
+			"parse_format": format,
+		},
+	}
+}
+
+func (r *Registry) lookup(format string) (Parser, bool) {
+	if format == "" {
+		return nil, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.parsers[normalize(format)]
+	return p, ok
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// keepRawMessage makes a log whose payload had no message keep the raw
+// payload as message, tagged like a payload that could not be parsed.
+func keepRawMessage(logEntry *service.Log, data []byte, format string) {
+	if logEntry.Message != "" {
+		return
+	}
+
+	logEntry.Message = string(data)
+	logEntry.Tags = append(logEntry.Tags, TagParseFailure)
+	setAttribute(logEntry, "parse_error", errMissingMessage.Error())
+	setAttribute(logEntry, "parse_format", format)
+}
+
+func setAttribute(logEntry *service.Log, key string, value interface{}) {
+	if logEntry.Attributes == nil {
+		logEntry.Attributes = make(map[string]interface{})
+	}
+	logEntry.Attributes[key] = value
+}
+
+// RawParser stores the whole payload as message.
+type RawParser struct{}
+
+func (RawParser) Parse(data []byte) (service.Log, error) {
+	return service.Log{Message: string(data)}, nil
+}
+
+// AutoParser decodes JSON objects and stores anything else raw.
+type AutoParser struct{}
+
+func (AutoParser) Parse(data []byte) (service.Log, error) {
+	if logEntry, err := (JSONParser{}).Parse(data); err == nil {
+		return logEntry, nil
+	}
+	return RawParser{}.Parse(data)
+}
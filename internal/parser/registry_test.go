@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Resolve(t *testing.T) {
+	registry := NewRegistry()
+	assert.NoError(t, registry.SetTopicFormat("syslog-topic", FormatSyslog))
+	assert.Error(t, registry.SetTopicFormat("other", "xml"))
+
+	tests := []struct {
+		name     string
+		hints    Hints
+		expected string
+	}{
+		{"explicit format wins", Hints{Format: "LOGFMT", ContentType: "application/json", Topic: "syslog-topic"}, FormatLogfmt},
+		{"content type with params", Hints{ContentType: "application/json; charset=utf-8"}, FormatJSON},
+		{"unknown format falls through to topic", Hints{Format: "xml", Topic: "syslog-topic"}, FormatSyslog},
+		{"default", Hints{Topic: "unknown"}, FormatAuto},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, _ := registry.Resolve(tt.hints)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestRegistry_Parse(t *testing.T) {
+	registry := NewRegistry()
+
+	t.Run("GIVEN default format WHEN payload is not JSON THEN keep it raw without tags", func(t *testing.T) {
+		logEntry := registry.Parse([]byte("plain text"), Hints{})
+
+		assert.Equal(t, "plain text", logEntry.Message)
+		assert.Empty(t, logEntry.Tags)
+	})
+
+	t.Run("GIVEN explicit format WHEN parse fails THEN tag the document", func(t *testing.T) {
+		logEntry := registry.Parse([]byte("plain text"), Hints{Format: FormatSyslog})
+
+		assert.Equal(t, "plain text", logEntry.Message)
+		assert.Equal(t, []string{TagParseFailure}, logEntry.Tags)
+		assert.Equal(t, FormatSyslog, logEntry.Attributes["parse_format"])
+		assert.NotEmpty(t, logEntry.Attributes["parse_error"])
+	})
+
+	t.Run("GIVEN custom parser WHEN registered THEN use it", func(t *testing.T) {
+		registry.Register("upper", RawParser{})
+		assert.NoError(t, registry.SetDefault("upper"))
+
+		format, _ := registry.Resolve(Hints{})
+		assert.Equal(t, "upper", format)
+	})
+}
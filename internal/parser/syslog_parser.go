@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const rfc3164TimeLayout = "Jan _2 15:04:05"
+
+var (
+	errMissingPriority = errors.New("syslog message must start with <PRI>")
+	errTruncatedSyslog = errors.New("truncated syslog header")
+)
+
+// severityLevels maps the syslog severities (0-7) onto the log levels used by
+// the rest of the pipeline.
+var severityLevels = [8]string{"FATAL", "FATAL", "FATAL", "ERROR", "WARN", "INFO", "INFO", "DEBUG"}
+
+// SyslogParser handles both RFC 5424 and the legacy BSD format of RFC 3164,
+// telling them apart by the version digit that follows the priority.
+type SyslogParser struct {
+	// Now is used to infer the year of RFC 3164 timestamps. Defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+func (p SyslogParser) Parse(data []byte) (service.Log, error) {
+	line := strings.TrimRight(string(data), "\r\n")
+
+	pri, rest, err := parsePriority(line)
+	if err != nil {
+		return service.Log{}, err
+	}
+
+	var logEntry service.Log
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		logEntry, err = parseRFC5424(rest)
+	} else {
+		logEntry, err = p.parseRFC3164(rest)
+	}
+	if err != nil {
+		return service.Log{}, err
+	}
+
+	keepRawMessage(&logEntry, []byte(line), FormatSyslog)
+
+	facility, severity := pri/8, pri%8
+	logEntry.Level = SeverityLevel(severity)
+	setAttribute(&logEntry, "syslog_facility", facility)
+	setAttribute(&logEntry, "syslog_severity", severity)
+
+	return logEntry, nil
+}
+
+// SeverityLevel returns the log level for a syslog severity.
+func SeverityLevel(severity int) string {
+	if severity < 0 || severity >= len(severityLevels) {
+		return "INFO"
+	}
+	return severityLevels[severity]
+}
+
+func parsePriority(line string) (int, string, error) {
+	if !strings.HasPrefix(line, "<") {
+		return 0, "", errMissingPriority
+	}
+
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", errMissingPriority
+	}
+
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return 0, "", fmt.Errorf("invalid syslog priority %q", line[1:end])
+	}
+
+	return pri, line[end+1:], nil
+}
+
+// parseRFC5424 parses "VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD [MSG]".
+func parseRFC5424(rest string) (service.Log, error) {
+	fields := make([]string, 0, 6)
+	for len(fields) < 6 {
+		end := strings.IndexByte(rest, ' ')
+		if end < 0 {
+			return service.Log{}, errTruncatedSyslog
+		}
+		fields = append(fields, rest[:end])
+		rest = rest[end+1:]
+	}
+
+	var logEntry service.Log
+	if fields[1] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return service.Log{}, fmt.Errorf("invalid RFC 5424 timestamp: %w", err)
+		}
+		logEntry.Timestamp = ts.UTC()
+	}
+
+	header := map[string]string{
+		"hostname": fields[2],
+		"appname":  fields[3],
+		"procid":   fields[4],
+		"msgid":    fields[5],
+	}
+	for key, value := range header {
+		if value != "-" {
+			setAttribute(&logEntry, key, value)
+		}
+	}
+
+	sd, msg, err := parseStructuredData(rest)
+	if err != nil {
+		return service.Log{}, err
+	}
+	if len(sd) > 0 {
+		setAttribute(&logEntry, "structured_data", sd)
+	}
+
+	logEntry.Message = strings.TrimPrefix(msg, "\ufeff")
+	logEntry.Source = firstNonEmpty(fields[3], fields[2])
+	return logEntry, nil
+}
+
+// parseStructuredData parses the SD-ELEMENTs at the start of s and returns
+// them with the remaining message.
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return nil, strings.TrimPrefix(s[1:], " "), nil
+	}
+
+	sd := make(map[string]map[string]string)
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		if i >= len(s) {
+			return nil, "", errors.New("unterminated structured data element")
+		}
+		params := make(map[string]string)
+		sd[s[start:i]] = params
+
+		for i < len(s) && s[i] == ' ' {
+			i++
+			start = i
+			for i < len(s) && s[i] != '=' {
+				i++
+			}
+			if i+1 >= len(s) || s[i+1] != '"' {
+				return nil, "", errors.New("invalid structured data parameter")
+			}
+			name := s[start:i]
+
+			var value strings.Builder
+			i += 2
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, "", errors.New("unterminated structured data value")
+			}
+			params[name] = value.String()
+			i++ // closing quote
+		}
+
+		if i >= len(s) || s[i] != ']' {
+			return nil, "", errors.New("unterminated structured data element")
+		}
+		i++
+	}
+
+	if i == 0 {
+		return nil, "", errors.New("missing structured data")
+	}
+	return sd, strings.TrimPrefix(s[i:], " "), nil
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG". The hostname
+// is optional, as many senders leave it out.
+func (p SyslogParser) parseRFC3164(rest string) (service.Log, error) {
+	if len(rest) < len(rfc3164TimeLayout)+1 {
+		return service.Log{}, errTruncatedSyslog
+	}
+
+	ts, err := time.Parse(rfc3164TimeLayout, rest[:len(rfc3164TimeLayout)])
+	if err != nil {
+		return service.Log{}, fmt.Errorf("invalid RFC 3164 timestamp: %w", err)
+	}
+	rest = strings.TrimPrefix(rest[len(rfc3164TimeLayout):], " ")
+
+	var logEntry service.Log
+	logEntry.Timestamp = p.inferYear(ts)
+
+	if host, after, ok := strings.Cut(rest, " "); ok && !isSyslogTag(host) {
+		setAttribute(&logEntry, "hostname", host)
+		logEntry.Source = host
+		rest = after
+	}
+
+	if tag, msg, ok := strings.Cut(rest, ": "); ok && isSyslogTag(tag+":") {
+		appname, procid, _ := strings.Cut(strings.TrimSuffix(tag, "]"), "[")
+		setAttribute(&logEntry, "appname", appname)
+		if procid != "" {
+			setAttribute(&logEntry, "procid", procid)
+		}
+		logEntry.Source = appname
+		rest = msg
+	}
+
+	logEntry.Message = rest
+	return logEntry, nil
+}
+
+func (p SyslogParser) inferYear(ts time.Time) time.Time {
+	now := time.Now()
+	if p.Now != nil {
+		now = p.Now()
+	}
+
+	ts = time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, time.Local)
+	// A December message read in January belongs to the previous year.
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts.UTC()
+}
+
+func isSyslogTag(token string) bool {
+	if !strings.HasSuffix(token, ":") || len(token) < 2 {
+		return false
+	}
+	return !strings.ContainsAny(token[:len(token)-1], " :")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" && v != "-" {
+			return v
+		}
+	}
+	return ""
+}
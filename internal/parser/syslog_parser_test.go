@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyslogParser_Parse(t *testing.T) {
+	now := func() time.Time { return time.Date(2024, 1, 5, 0, 0, 0, 0, time.Local) }
+	p := SyslogParser{Now: now}
+
+	t.Run("GIVEN RFC 5424 message WHEN parse THEN extract header and structured data", func(t *testing.T) {
+		line := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\]lication"][meta seq="1"] ` + "\ufeff" + `An application event`
+
+		logEntry, err := p.Parse([]byte(line))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "INFO", logEntry.Level)
+		assert.Equal(t, "An application event", logEntry.Message)
+		assert.Equal(t, "evntslog", logEntry.Source)
+		assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), logEntry.Timestamp)
+		assert.Equal(t, 20, logEntry.Attributes["syslog_facility"])
+		assert.Equal(t, 5, logEntry.Attributes["syslog_severity"])
+		assert.Equal(t, "mymachine.example.com", logEntry.Attributes["hostname"])
+		assert.Equal(t, "ID47", logEntry.Attributes["msgid"])
+		assert.NotContains(t, logEntry.Attributes, "procid")
+
+		sd := logEntry.Attributes["structured_data"].(map[string]map[string]string)
+		assert.Equal(t, "App]lication", sd["exampleSDID@32473"]["eventSource"])
+		assert.Equal(t, "1", sd["meta"]["seq"])
+	})
+
+	t.Run("GIVEN RFC 5424 without structured data WHEN parse THEN keep message", func(t *testing.T) {
+		logEntry, err := p.Parse([]byte(`<11>1 - host app 123 - - disk failure`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ERROR", logEntry.Level)
+		assert.Equal(t, "disk failure", logEntry.Message)
+		assert.Equal(t, "123", logEntry.Attributes["procid"])
+		assert.True(t, logEntry.Timestamp.IsZero())
+	})
+
+	t.Run("GIVEN RFC 5424 without MSG WHEN parse THEN keep the raw frame tagged as a parse failure", func(t *testing.T) {
+		logEntry, err := p.Parse([]byte("<14>1 - host app - - -\n"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "INFO", logEntry.Level)
+		assert.Equal(t, "<14>1 - host app - - -", logEntry.Message)
+		assert.Equal(t, "app", logEntry.Source)
+		assert.Equal(t, []string{TagParseFailure}, logEntry.Tags)
+		assert.Equal(t, FormatSyslog, logEntry.Attributes["parse_format"])
+	})
+
+	t.Run("GIVEN RFC 3164 message WHEN parse THEN extract tag and pid", func(t *testing.T) {
+		logEntry, err := p.Parse([]byte(`<34>Oct 11 22:14:15 mymachine sshd[1234]: 'su root' failed`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "FATAL", logEntry.Level)
+		assert.Equal(t, "'su root' failed", logEntry.Message)
+		assert.Equal(t, "sshd", logEntry.Source)
+		assert.Equal(t, "mymachine", logEntry.Attributes["hostname"])
+		assert.Equal(t, "1234", logEntry.Attributes["procid"])
+		assert.Equal(t, 2023, logEntry.Timestamp.Year(), "October seen in January belongs to last year")
+	})
+
+	t.Run("GIVEN RFC 3164 without hostname WHEN parse THEN use tag as source", func(t *testing.T) {
+		logEntry, err := p.Parse([]byte(`<13>Jan  4 10:00:00 cron: job done`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "cron", logEntry.Source)
+		assert.Equal(t, "job done", logEntry.Message)
+		assert.NotContains(t, logEntry.Attributes, "hostname")
+	})
+
+	t.Run("GIVEN invalid messages WHEN parse THEN return error", func(t *testing.T) {
+		for _, line := range []string{"no priority", "<999>1 - - - - - -", "<13>1 2024-01-01", "<13>1 - h a p m [unterminated"} {
+			_, err := p.Parse([]byte(line))
+			assert.Error(t, err, line)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package querylang
+
+// Node is a node of the syntax tree produced by Parse.
+type Node interface {
+	Position() int
+}
+
+// AndNode matches when every operand does. Juxtaposed clauses are joined
+// with AND, as in `error timeout`.
+type AndNode struct {
+	Pos      int
+	Operands []Node
+}
+
+type OrNode struct {
+	Pos      int
+	Operands []Node
+}
+
+type NotNode struct {
+	Pos     int
+	Operand Node
+}
+
+// TermNode is a value, optionally bound to a field. Values without a field
+// search the message.
+type TermNode struct {
+	Pos      int
+	Field    string
+	Value    string
+	Phrase   bool
+	Wildcard bool
+}
+
+// RangeNode is field:[from TO to], with braces for exclusive bounds. A *
+// bound is open.
+type RangeNode struct {
+	Pos           int
+	Field         string
+	From          string
+	To            string
+	FromExclusive bool
+	ToExclusive   bool
+}
+
+func (n *AndNode) Position() int   { return n.Pos }
+func (n *OrNode) Position() int    { return n.Pos }
+func (n *NotNode) Position() int   { return n.Pos }
+func (n *TermNode) Position() int  { return n.Pos }
+func (n *RangeNode) Position() int { return n.Pos }
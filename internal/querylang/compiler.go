@@ -0,0 +1,92 @@
+package querylang
+
+import (
+	"fmt"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// DefaultField is searched by values written without a field.
+const DefaultField = "message"
+
+// textFields are searched word by word. Values on any other field must match
+// the whole value.
+var textFields = map[string]bool{
+	"message": true,
+}
+
+// Compile turns the syntax tree into the query model.
+func Compile(node Node) (service.Condition, error) {
+	switch n := node.(type) {
+	case *AndNode:
+		and := make(service.And, 0, len(n.Operands))
+		for _, operand := range n.Operands {
+			c, err := Compile(operand)
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, c)
+		}
+		return and, nil
+
+	case *OrNode:
+		or := make(service.Or, 0, len(n.Operands))
+		for _, operand := range n.Operands {
+			c, err := Compile(operand)
+			if err != nil {
+				return nil, err
+			}
+			or = append(or, c)
+		}
+		return or, nil
+
+	case *NotNode:
+		c, err := Compile(n.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return service.Not{Condition: c}, nil
+
+	case *TermNode:
+		return compileTerm(n), nil
+
+	case *RangeNode:
+		return service.Range{
+			Field:         n.Field,
+			From:          n.From,
+			To:            n.To,
+			FromExclusive: n.FromExclusive,
+			ToExclusive:   n.ToExclusive,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported node %T", node)
+}
+
+func compileTerm(n *TermNode) service.Condition {
+	field := n.Field
+	if field == "" {
+		field = DefaultField
+	}
+
+	switch {
+	case n.Wildcard && n.Value == "*":
+		return service.Exists{Field: field}
+	case n.Wildcard:
+		return service.Wildcard{Field: field, Pattern: n.Value}
+	case textFields[field] && n.Phrase:
+		return service.Phrase{Field: field, Text: n.Value}
+	case textFields[field]:
+		return service.Match{Field: field, Text: n.Value}
+	}
+	return service.Term{Field: field, Values: []string{n.Value}}
+}
+
+// ParseCondition parses and compiles a query in one go.
+func ParseCondition(input string) (service.Condition, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(node)
+}
@@ -0,0 +1,62 @@
+package querylang
+
+import (
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected service.Condition
+	}{
+		{
+			name:  "GIVEN the search box example THEN compile exact fields and a negated phrase",
+			query: `level:ERROR AND source:checkout AND NOT message:"timeout"`,
+			expected: service.And{
+				service.Term{Field: "level", Values: []string{"ERROR"}},
+				service.Term{Field: "source", Values: []string{"checkout"}},
+				service.Not{Condition: service.Phrase{Field: "message", Text: "timeout"}},
+			},
+		},
+		{
+			name:     "GIVEN a bare word THEN search the message",
+			query:    `timeout`,
+			expected: service.Match{Field: "message", Text: "timeout"},
+		},
+		{
+			name:     "GIVEN a wildcard THEN compile a wildcard",
+			query:    `source:check*`,
+			expected: service.Wildcard{Field: "source", Pattern: "check*"},
+		},
+		{
+			name:     "GIVEN a lone star THEN require the field",
+			query:    `metadata.trace-id:*`,
+			expected: service.Exists{Field: "metadata.trace-id"},
+		},
+		{
+			name:     "GIVEN a range THEN compile a range",
+			query:    `timestamp:[now-1h TO now}`,
+			expected: service.Range{Field: "timestamp", From: "now-1h", To: "now", ToExclusive: true},
+		},
+		{
+			name:  "GIVEN a field group THEN compile an or of terms",
+			query: `level:(ERROR OR WARN)`,
+			expected: service.Or{
+				service.Term{Field: "level", Values: []string{"ERROR"}},
+				service.Term{Field: "level", Values: []string{"WARN"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, err := ParseCondition(tt.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, condition)
+		})
+	}
+}
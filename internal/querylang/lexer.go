@@ -0,0 +1,216 @@
+package querylang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenWord
+	TokenPhrase
+	TokenColon
+	TokenLParen
+	TokenRParen
+	TokenLBracket
+	TokenRBracket
+	TokenLBrace
+	TokenRBrace
+	TokenAnd
+	TokenOr
+	TokenNot
+	TokenTo
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenEOF:
+		return "end of query"
+	case TokenWord:
+		return "word"
+	case TokenPhrase:
+		return "quoted phrase"
+	case TokenColon:
+		return `":"`
+	case TokenLParen:
+		return `"("`
+	case TokenRParen:
+		return `")"`
+	case TokenLBracket:
+		return `"["`
+	case TokenRBracket:
+		return `"]"`
+	case TokenLBrace:
+		return `"{"`
+	case TokenRBrace:
+		return `"}"`
+	case TokenAnd:
+		return "AND"
+	case TokenOr:
+		return "OR"
+	case TokenNot:
+		return "NOT"
+	case TokenTo:
+		return "TO"
+	}
+	return fmt.Sprintf("token(%d)", int(k))
+}
+
+// Token is a lexeme of the query. Pos is the 1-based position of its first
+// character, counted in characters rather than bytes.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  int
+
+	// Wildcard is set on words holding an unescaped * or ?.
+	Wildcard bool
+}
+
+// SyntaxError reports where the query stopped making sense.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+const specialChars = `():[]{}"\`
+
+// Lex splits the query into tokens. Inside range brackets colons belong to
+// the bounds, so timestamps such as 2024-06-10T10:00:00Z need no escaping.
+func Lex(input string) ([]Token, error) {
+	var tokens []Token
+	runes := []rune(input)
+	inRange := false
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, Token{Kind: TokenLParen, Text: "(", Pos: pos})
+		case r == ')':
+			tokens = append(tokens, Token{Kind: TokenRParen, Text: ")", Pos: pos})
+		case r == '[' || r == '{':
+			kind := TokenLBracket
+			if r == '{' {
+				kind = TokenLBrace
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: string(r), Pos: pos})
+			inRange = true
+		case r == ']' || r == '}':
+			kind := TokenRBracket
+			if r == '}' {
+				kind = TokenRBrace
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: string(r), Pos: pos})
+			inRange = false
+		case r == ':' && !inRange:
+			tokens = append(tokens, Token{Kind: TokenColon, Text: ":", Pos: pos})
+		case r == '"':
+			text, next, err := lexPhrase(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: TokenPhrase, Text: text, Pos: pos})
+			i = next
+			continue
+		default:
+			tok, next, err := lexWord(runes, i, inRange)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = next
+			continue
+		}
+		i++
+	}
+
+	return append(tokens, Token{Kind: TokenEOF, Pos: len(runes) + 1}), nil
+}
+
+func lexPhrase(runes []rune, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 == len(runes) {
+				return "", 0, &SyntaxError{Pos: i + 1, Msg: "escape character at end of query"}
+			}
+			i++
+			b.WriteRune(runes[i])
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, &SyntaxError{Pos: start + 1, Msg: "unterminated quoted phrase"}
+}
+
+func lexWord(runes []rune, start int, inRange bool) (Token, int, error) {
+	// plain holds the unescaped word, pattern keeps escaped wildcards
+	// escaped so they match literally when the word is a wildcard.
+	var plain, pattern strings.Builder
+	tok := Token{Kind: TokenWord, Pos: start + 1}
+	escaped := false
+
+	i := start
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if unicode.IsSpace(r) || (strings.ContainsRune(specialChars, r) && r != '\\' && !(r == ':' && inRange)) {
+			break
+		}
+		if r == '\\' {
+			if i+1 == len(runes) {
+				return tok, 0, &SyntaxError{Pos: i + 1, Msg: "escape character at end of query"}
+			}
+			i++
+			escaped = true
+			if runes[i] == '*' || runes[i] == '?' || runes[i] == '\\' {
+				pattern.WriteRune('\\')
+			}
+			pattern.WriteRune(runes[i])
+			plain.WriteRune(runes[i])
+			continue
+		}
+		if r == '*' || r == '?' {
+			tok.Wildcard = true
+		}
+		pattern.WriteRune(r)
+		plain.WriteRune(r)
+	}
+
+	tok.Text = plain.String()
+	if tok.Wildcard {
+		tok.Text = pattern.String()
+	}
+	if escaped {
+		return tok, i, nil
+	}
+
+	switch tok.Text {
+	case "AND", "&&":
+		tok.Kind = TokenAnd
+	case "OR", "||":
+		tok.Kind = TokenOr
+	case "NOT":
+		tok.Kind = TokenNot
+	case "TO":
+		if inRange {
+			tok.Kind = TokenTo
+		}
+	}
+	return tok, i, nil
+}
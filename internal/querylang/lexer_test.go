@@ -0,0 +1,60 @@
+package querylang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLex(t *testing.T) {
+	t.Run("GIVEN fields, phrases and operators WHEN lexing THEN return tokens with positions", func(t *testing.T) {
+		tokens, err := Lex(`level:ERROR AND NOT message:"time out"`)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []Token{
+			{Kind: TokenWord, Text: "level", Pos: 1},
+			{Kind: TokenColon, Text: ":", Pos: 6},
+			{Kind: TokenWord, Text: "ERROR", Pos: 7},
+			{Kind: TokenAnd, Text: "AND", Pos: 13},
+			{Kind: TokenNot, Text: "NOT", Pos: 17},
+			{Kind: TokenWord, Text: "message", Pos: 21},
+			{Kind: TokenColon, Text: ":", Pos: 28},
+			{Kind: TokenPhrase, Text: "time out", Pos: 29},
+			{Kind: TokenEOF, Pos: 39},
+		}, tokens)
+	})
+
+	t.Run("GIVEN a range WHEN lexing THEN keep colons inside the bounds", func(t *testing.T) {
+		tokens, err := Lex(`timestamp:[2024-06-10T10:00:00Z TO now}`)
+		assert.NoError(t, err)
+
+		kinds := make([]TokenKind, len(tokens))
+		for i, tok := range tokens {
+			kinds[i] = tok.Kind
+		}
+		assert.Equal(t, []TokenKind{TokenWord, TokenColon, TokenLBracket, TokenWord, TokenTo, TokenWord, TokenRBrace, TokenEOF}, kinds)
+		assert.Equal(t, "2024-06-10T10:00:00Z", tokens[3].Text)
+	})
+
+	t.Run("GIVEN wildcards and escapes WHEN lexing THEN flag only unescaped wildcards", func(t *testing.T) {
+		tokens, err := Lex(`check* 100\% a\*b\*c* a\:b "say \"hi\""`)
+		assert.NoError(t, err)
+
+		assert.Equal(t, Token{Kind: TokenWord, Text: "check*", Pos: 1, Wildcard: true}, tokens[0])
+		assert.Equal(t, Token{Kind: TokenWord, Text: "100%", Pos: 8}, tokens[1])
+		assert.Equal(t, Token{Kind: TokenWord, Text: `a\*b\*c*`, Pos: 14, Wildcard: true}, tokens[2])
+		assert.Equal(t, Token{Kind: TokenWord, Text: "a:b", Pos: 23}, tokens[3])
+		assert.Equal(t, Token{Kind: TokenPhrase, Text: `say "hi"`, Pos: 28}, tokens[4])
+	})
+
+	t.Run("GIVEN TO outside a range WHEN lexing THEN treat it as a word", func(t *testing.T) {
+		tokens, err := Lex(`TO`)
+		assert.NoError(t, err)
+		assert.Equal(t, TokenWord, tokens[0].Kind)
+	})
+
+	t.Run("GIVEN an unterminated phrase WHEN lexing THEN report where it starts", func(t *testing.T) {
+		_, err := Lex(`level:ERROR "time out`)
+		assert.Equal(t, &SyntaxError{Pos: 13, Msg: "unterminated quoted phrase"}, err)
+	})
+}
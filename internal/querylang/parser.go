@@ -0,0 +1,239 @@
+package querylang
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var fieldName = regexp.MustCompile(`^[A-Za-z_@][A-Za-z0-9_.@-]*$`)
+
+// Parse builds the syntax tree of a query such as
+//
+//	level:ERROR AND source:checkout AND NOT message:"timeout"
+//
+// Operators, from loosest to tightest: OR, AND (also implied between
+// clauses), NOT. Parentheses group clauses, and a field may apply to a group
+// of values, as in level:(ERROR OR WARN).
+func Parse(input string) (Node, error) {
+	tokens, err := Lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().Kind == TokenEOF {
+		return nil, &SyntaxError{Pos: 1, Msg: "empty query"}
+	}
+
+	node, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.Kind != TokenEOF {
+		return nil, unexpected(tok)
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []Token
+	pos    int
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() Token {
+	tok := p.tokens[p.pos]
+	if tok.Kind != TokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind TokenKind) (Token, error) {
+	tok := p.next()
+	if tok.Kind != kind {
+		return tok, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("expected %s, found %s", kind, describe(tok))}
+	}
+	return tok, nil
+}
+
+// The field argument carries the field of an enclosing field:( ... ) group to
+// the values inside it.
+
+func (p *parser) parseOr(field string) (Node, error) {
+	first, err := p.parseAnd(field)
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Node{first}
+	for p.peek().Kind == TokenOr {
+		p.next()
+		operand, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &OrNode{Pos: first.Position(), Operands: operands}, nil
+}
+
+func (p *parser) parseAnd(field string) (Node, error) {
+	first, err := p.parseNot(field)
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []Node{first}
+	for {
+		switch p.peek().Kind {
+		case TokenAnd:
+			p.next()
+		case TokenEOF, TokenOr, TokenRParen:
+			if len(operands) == 1 {
+				return first, nil
+			}
+			return &AndNode{Pos: first.Position(), Operands: operands}, nil
+		}
+
+		operand, err := p.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+}
+
+func (p *parser) parseNot(field string) (Node, error) {
+	if tok := p.peek(); tok.Kind == TokenNot {
+		p.next()
+		operand, err := p.parseNot(field)
+		if err != nil {
+			return nil, err
+		}
+		return &NotNode{Pos: tok.Pos, Operand: operand}, nil
+	}
+	return p.parsePrimary(field)
+}
+
+func (p *parser) parsePrimary(field string) (Node, error) {
+	tok := p.next()
+
+	switch tok.Kind {
+	case TokenLParen:
+		node, err := p.parseOr(field)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+		return node, nil
+
+	case TokenPhrase:
+		return &TermNode{Pos: tok.Pos, Field: field, Value: tok.Text, Phrase: true}, nil
+
+	case TokenWord:
+		if p.peek().Kind != TokenColon {
+			return &TermNode{Pos: tok.Pos, Field: field, Value: tok.Text, Wildcard: tok.Wildcard}, nil
+		}
+
+		if field != "" {
+			return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("field %q inside the values of field %q", tok.Text, field)}
+		}
+		if !fieldName.MatchString(tok.Text) || tok.Wildcard {
+			return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("invalid field name %q", tok.Text)}
+		}
+		p.next()
+		return p.parseFieldValue(tok)
+	}
+
+	return nil, unexpected(tok)
+}
+
+func (p *parser) parseFieldValue(fieldTok Token) (Node, error) {
+	field := fieldTok.Text
+	tok := p.peek()
+
+	switch tok.Kind {
+	case TokenLBracket, TokenLBrace:
+		return p.parseRange(fieldTok)
+	case TokenLParen:
+		return p.parsePrimary(field)
+	case TokenWord, TokenPhrase:
+		node, err := p.parsePrimary(field)
+		if err != nil {
+			return nil, err
+		}
+		node.(*TermNode).Pos = fieldTok.Pos
+		return node, nil
+	}
+
+	return nil, &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("expected a value for field %q, found %s", field, describe(tok))}
+}
+
+func (p *parser) parseRange(fieldTok Token) (Node, error) {
+	open := p.next()
+	node := &RangeNode{Pos: fieldTok.Pos, Field: fieldTok.Text, FromExclusive: open.Kind == TokenLBrace}
+
+	from, err := p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenTo); err != nil {
+		return nil, err
+	}
+	to, err := p.parseBound()
+	if err != nil {
+		return nil, err
+	}
+
+	closing := p.next()
+	switch closing.Kind {
+	case TokenRBracket:
+	case TokenRBrace:
+		node.ToExclusive = true
+	default:
+		return nil, &SyntaxError{Pos: closing.Pos, Msg: fmt.Sprintf(`expected "]" or "}", found %s`, describe(closing))}
+	}
+
+	if from == "*" && to == "*" {
+		return nil, &SyntaxError{Pos: open.Pos, Msg: "range needs at least one bound"}
+	}
+	if from != "*" {
+		node.From = from
+	}
+	if to != "*" {
+		node.To = to
+	}
+	return node, nil
+}
+
+func (p *parser) parseBound() (string, error) {
+	tok := p.next()
+	if tok.Kind != TokenWord && tok.Kind != TokenPhrase {
+		return "", &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("expected a range bound, found %s", describe(tok))}
+	}
+	return tok.Text, nil
+}
+
+func unexpected(tok Token) error {
+	return &SyntaxError{Pos: tok.Pos, Msg: fmt.Sprintf("unexpected %s", describe(tok))}
+}
+
+func describe(tok Token) string {
+	switch tok.Kind {
+	case TokenWord:
+		return fmt.Sprintf("%q", tok.Text)
+	case TokenPhrase:
+		return fmt.Sprintf("phrase %q", tok.Text)
+	}
+	return tok.Kind.String()
+}
@@ -0,0 +1,78 @@
+package querylang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("GIVEN boolean operators WHEN parsing THEN bind NOT tighter than AND and AND tighter than OR", func(t *testing.T) {
+		node, err := Parse(`level:ERROR OR level:WARN AND NOT source:api`)
+		assert.NoError(t, err)
+
+		assert.Equal(t, &OrNode{Pos: 1, Operands: []Node{
+			&TermNode{Pos: 1, Field: "level", Value: "ERROR"},
+			&AndNode{Pos: 16, Operands: []Node{
+				&TermNode{Pos: 16, Field: "level", Value: "WARN"},
+				&NotNode{Pos: 31, Operand: &TermNode{Pos: 35, Field: "source", Value: "api"}},
+			}},
+		}}, node)
+	})
+
+	t.Run("GIVEN juxtaposed clauses and groups WHEN parsing THEN join them with AND", func(t *testing.T) {
+		node, err := Parse(`(error OR warn) "disk full"`)
+		assert.NoError(t, err)
+
+		assert.Equal(t, &AndNode{Pos: 2, Operands: []Node{
+			&OrNode{Pos: 2, Operands: []Node{
+				&TermNode{Pos: 2, Value: "error"},
+				&TermNode{Pos: 11, Value: "warn"},
+			}},
+			&TermNode{Pos: 17, Value: "disk full", Phrase: true},
+		}}, node)
+	})
+
+	t.Run("GIVEN a field applied to a group WHEN parsing THEN bind every value to it", func(t *testing.T) {
+		node, err := Parse(`level:(ERROR OR WARN)`)
+		assert.NoError(t, err)
+
+		assert.Equal(t, &OrNode{Pos: 8, Operands: []Node{
+			&TermNode{Pos: 8, Field: "level", Value: "ERROR"},
+			&TermNode{Pos: 17, Field: "level", Value: "WARN"},
+		}}, node)
+	})
+
+	t.Run("GIVEN ranges WHEN parsing THEN read bounds and exclusivity", func(t *testing.T) {
+		node, err := Parse(`timestamp:[now-1h TO now]`)
+		assert.NoError(t, err)
+		assert.Equal(t, &RangeNode{Pos: 1, Field: "timestamp", From: "now-1h", To: "now"}, node)
+
+		node, err = Parse(`kafka.offset:{100 TO *]`)
+		assert.NoError(t, err)
+		assert.Equal(t, &RangeNode{Pos: 1, Field: "kafka.offset", From: "100", FromExclusive: true}, node)
+	})
+
+	t.Run("GIVEN invalid queries WHEN parsing THEN report the position of the problem", func(t *testing.T) {
+		tests := []struct {
+			query string
+			err   *SyntaxError
+		}{
+			{``, &SyntaxError{Pos: 1, Msg: "empty query"}},
+			{`level:`, &SyntaxError{Pos: 7, Msg: `expected a value for field "level", found end of query`}},
+			{`level:ERROR AND`, &SyntaxError{Pos: 16, Msg: "unexpected end of query"}},
+			{`(level:ERROR`, &SyntaxError{Pos: 13, Msg: `expected ")", found end of query`}},
+			{`level:ERROR)`, &SyntaxError{Pos: 12, Msg: `unexpected ")"`}},
+			{`timestamp:[now-1h now]`, &SyntaxError{Pos: 19, Msg: `expected TO, found "now"`}},
+			{`timestamp:[now-1h TO now`, &SyntaxError{Pos: 25, Msg: `expected "]" or "}", found end of query`}},
+			{`timestamp:[* TO *]`, &SyntaxError{Pos: 11, Msg: "range needs at least one bound"}},
+			{`level:(source:api)`, &SyntaxError{Pos: 8, Msg: `field "source" inside the values of field "level"`}},
+			{`lev*l:ERROR`, &SyntaxError{Pos: 1, Msg: `invalid field name "lev*l"`}},
+		}
+
+		for _, tt := range tests {
+			_, err := Parse(tt.query)
+			assert.Equal(t, tt.err, err, tt.query)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Policy retries operations with exponential backoff and full jitter: the
+// n-th wait is a random duration between zero and
+// min(MaxInterval, InitialInterval * Multiplier^(n-1)).
+type Policy struct {
+	// MaxAttempts counts the first call. Values below one mean a single call.
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// MaxElapsedTime stops retrying once the next wait would go past it,
+	// measured from the first call. Zero means no limit.
+	MaxElapsedTime time.Duration
+	// IsRetryable classifies errors. Defaults to Transient.
+	IsRetryable func(error) bool
+}
+
+func NewPolicy(maxAttempts int, initialInterval time.Duration) Policy {
+	return Policy{
+		MaxAttempts:     maxAttempts,
+		InitialInterval: initialInterval,
+		MaxInterval:     30 * time.Second,
+		Multiplier:      2,
+	}
+}
+
+// Do calls fn until it succeeds, returns a permanent error or the policy
+// gives up, and reports how many calls were made with the last error. Waits
+// are interrupted when ctx is done.
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) (int, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return attempt, nil
+		}
+
+		if !p.ShouldRetry(err) {
+			return attempt, err
+		}
+
+		delay, ok := p.NextDelay(attempt, time.Since(start))
+		if !ok {
+			return attempt, err
+		}
+
+		if Sleep(ctx, delay) != nil {
+			return attempt, err
+		}
+	}
+}
+
+func (p Policy) ShouldRetry(err error) bool {
+	if p.IsRetryable != nil {
+		return p.IsRetryable(err)
+	}
+	return Transient(err)
+}
+
+// NextDelay returns how long to wait after the given attempt, or false when
+// no attempt is left.
+func (p Policy) NextDelay(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	delay := p.Backoff(attempt)
+	if p.MaxElapsedTime > 0 && elapsed+delay > p.MaxElapsedTime {
+		return 0, false
+	}
+	return delay, true
+}
+
+// Backoff returns the jittered wait after the given attempt.
+func (p Policy) Backoff(attempt int) time.Duration {
+	ceiling := p.ceiling(attempt)
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+func (p Policy) ceiling(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	ceiling := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && ceiling > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+	if ceiling > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(ceiling)
+}
+
+// Sleep waits for d or until ctx is done, in which case it returns ctx.Err().
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Transient is the default classification: timeouts, throttling (429) and
+// server side (5xx) failures are retried, as is any error it knows nothing
+// about, such as a refused connection. Permanent errors, cancelled contexts,
+// other HTTP statuses, and the JSON and certificate errors that fail the
+// same way on every call are not.
+func Transient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var status interface{ HTTPStatus() int }
+	if errors.As(err, &status) {
+		code := status.HTTPStatus()
+		return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+	}
+
+	var (
+		syntaxErr      *json.SyntaxError
+		typeErr        *json.UnmarshalTypeError
+		unsupportedErr *json.UnsupportedTypeError
+		valueErr       *json.UnsupportedValueError
+		certErr        *tls.CertificateVerificationError
+	)
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &unsupportedErr) ||
+		errors.As(err, &valueErr) || errors.As(err, &certErr) {
+		return false
+	}
+
+	return true
+}
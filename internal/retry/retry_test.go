@@ -0,0 +1,133 @@
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type statusError int
+
+func (e statusError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusError) HTTPStatus() int { return int(e) }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"nil", nil, false},
+		{"permanent", Permanent(errors.New("bad input")), false},
+		{"wrapped permanent", fmt.Errorf("indexing: %w", Permanent(errors.New("bad input"))), false},
+		{"canceled", context.Canceled, false},
+		{"deadline", fmt.Errorf("op: %w", context.DeadlineExceeded), true},
+		{"too many requests", statusError(429), true},
+		{"server error", fmt.Errorf("indexing: %w", statusError(503)), true},
+		{"bad request", statusError(400), false},
+		{"conflict", statusError(409), false},
+		{"network timeout", &net.OpError{Op: "dial", Err: timeoutError{}}, true},
+		{"refused connection", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"invalid json", fmt.Errorf("decoding: %w", &json.SyntaxError{}), false},
+		{"unencodable value", &json.UnsupportedValueError{Str: "NaN"}, false},
+		{"untrusted certificate", &tls.CertificateVerificationError{Err: errors.New("unknown authority")}, false},
+		{"unknown", errors.New("connection refused"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Transient(tt.err))
+		})
+	}
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		ceiling := min(time.Second, 100*time.Millisecond<<(attempt-1))
+		for i := 0; i < 50; i++ {
+			delay := p.Backoff(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, ceiling)
+		}
+	}
+}
+
+func TestPolicy_NextDelay(t *testing.T) {
+	p := Policy{MaxAttempts: 3, InitialInterval: 10 * time.Millisecond, Multiplier: 2, MaxElapsedTime: time.Second}
+
+	_, ok := p.NextDelay(1, 0)
+	assert.True(t, ok)
+
+	_, ok = p.NextDelay(3, 0)
+	assert.False(t, ok, "must stop after MaxAttempts")
+
+	_, ok = p.NextDelay(1, 2*time.Second)
+	assert.False(t, ok, "must stop after MaxElapsedTime")
+}
+
+func TestPolicy_Do(t *testing.T) {
+	p := NewPolicy(4, time.Millisecond)
+
+	t.Run("GIVEN transient errors WHEN Do THEN retry until success", func(t *testing.T) {
+		calls := 0
+		attempts, err := p.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return statusError(503)
+			}
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("GIVEN permanent error WHEN Do THEN stop at once", func(t *testing.T) {
+		attempts, err := p.Do(context.Background(), func(ctx context.Context) error {
+			return statusError(400)
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("GIVEN attempts exhausted WHEN Do THEN return last error", func(t *testing.T) {
+		attempts, err := p.Do(context.Background(), func(ctx context.Context) error {
+			return errors.New("still down")
+		})
+
+		assert.EqualError(t, err, "still down")
+		assert.Equal(t, 4, attempts)
+	})
+
+	t.Run("GIVEN context cancelled WHEN waiting THEN stop sleeping", func(t *testing.T) {
+		slow := NewPolicy(5, time.Hour)
+		slow.MaxInterval = time.Hour
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		attempts, err := slow.Do(ctx, func(ctx context.Context) error {
+			return errors.New("down")
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -6,7 +6,7 @@ import (
 
 type MockElasticSearchClient struct {
 	IndexedLogs map[string]Log
-	SearchFunc  func(ctx context.Context, index string, query map[string]interface{}, size int) ([]Log, error)
+	SearchFunc  func(ctx context.Context, index string, query Query) (SearchResult, error)
 }
 
 func NewMockElasticSearchClient() *MockElasticSearchClient {
@@ -24,17 +24,37 @@ func (m *MockElasticSearchClient) Index(ctx context.Context, index string, id st
 	return nil
 }
 
-func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query map[string]interface{}, size int) ([]Log, error) {
+func (m *MockElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []BulkDocument) ([]BulkItemResult, error) {
+	results := make([]BulkItemResult, len(docs))
+	for i, doc := range docs {
+		if logEntry, ok := doc.Body.(Log); ok {
+			m.IndexedLogs[doc.ID] = logEntry
+		}
+		results[i] = BulkItemResult{ID: doc.ID, Status: 201}
+	}
+	return results, nil
+}
+
+func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query Query) (SearchResult, error) {
 	if m.SearchFunc != nil {
-		return m.SearchFunc(ctx, index, query, size)
+		return m.SearchFunc(ctx, index, query)
 	}
 
 	logs := make([]Log, 0, len(m.IndexedLogs))
 	for _, l := range m.IndexedLogs {
 		logs = append(logs, l)
 	}
-	if len(logs) > size {
-		logs = logs[:size]
+	total := int64(len(logs))
+	if len(logs) > query.Size {
+		logs = logs[:query.Size]
 	}
-	return logs, nil
+	return SearchResult{Logs: logs, Total: total}, nil
+}
+
+func (m *MockElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockElasticSearchClient) AggregateLogs(ctx context.Context, index string, where Condition, aggs []Aggregation) (AggregationResult, error) {
+	return AggregationResult{}, nil
 }
@@ -4,22 +4,68 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 )
 
+var ErrInvalidLog = errors.New("invalid log: empty ID or message")
+
 type ElasticSearchClient interface {
 	Index(ctx context.Context, index string, id string, body interface{}) error
-	SearchLogs(ctx context.Context, index string, query map[string]interface{}, size int) ([]Log, error)
+	BulkIndex(ctx context.Context, index string, docs []BulkDocument) ([]BulkItemResult, error)
+	SearchLogs(ctx context.Context, index string, query Query) (SearchResult, error)
+	ClosePointInTime(ctx context.Context, id string) error
+	AggregateLogs(ctx context.Context, index string, where Condition, aggs []Aggregation) (AggregationResult, error)
 }
 
 type LogServiceInterface interface {
 	Process(ctx context.Context, logEntry Log) error
-	SearchLogs(ctx context.Context, query map[string]interface{}, size int) ([]Log, error)
+	ProcessBatch(ctx context.Context, logEntries []Log) []error
+	SearchLogs(ctx context.Context, query Query) (SearchResult, error)
+}
+
+type BulkDocument struct {
+	ID   string
+	Body interface{}
+}
+
+// BulkItemResult is reported for every document sent in a bulk request, in
+// the same order. Error is empty when the document was indexed.
+type BulkItemResult struct {
+	ID     string
+	Status int
+	Error  string
+}
+
+func (r BulkItemResult) Failed() bool {
+	return r.Error != ""
+}
+
+// BulkItemError is the error reported for a document rejected inside a bulk
+// request.
+type BulkItemError struct {
+	ID     string
+	Status int
+	Reason string
+}
+
+func (e *BulkItemError) Error() string {
+	return fmt.Sprintf("error indexing document ID %s: status %d: %s", e.ID, e.Status, e.Reason)
+}
+
+func (e *BulkItemError) HTTPStatus() int {
+	return e.Status
+}
+
+// Publisher is told about every log once it is indexed.
+type Publisher interface {
+	Publish(logEntry Log)
 }
 
 type LogService struct {
-	esClient ElasticSearchClient
-	index    string
+	esClient  ElasticSearchClient
+	index     string
+	publisher Publisher
 }
 
 type Log struct {
@@ -28,6 +74,18 @@ type Log struct {
 	Level     string    `json:"level"`
 	Message   string    `json:"message"`
 	Source    string    `json:"source"`
+
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Tags       []string               `json:"tags,omitempty"`
+	Metadata   map[string]string      `json:"metadata,omitempty"`
+	Kafka      *KafkaRecord           `json:"kafka,omitempty"`
+}
+
+// KafkaRecord points back to the exact Kafka record a log was read from.
+type KafkaRecord struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
 }
 
 func NewLogService(esClient ElasticSearchClient, index string) *LogService {
@@ -37,24 +95,92 @@ func NewLogService(esClient ElasticSearchClient, index string) *LogService {
 	}
 }
 
-func (s *LogService) Process(ctx context.Context, logEntry Log) error {
-	if logEntry.ID == "" || logEntry.Message == "" {
-		return errors.New("invalid log: empty ID or message")
-	}
+// SetPublisher makes the service publish the logs it indexes, e.g. to the
+// live tail.
+func (s *LogService) SetPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
 
-	if logEntry.Timestamp.IsZero() {
-		logEntry.Timestamp = time.Now().UTC()
+func (s *LogService) Process(ctx context.Context, logEntry Log) error {
+	logEntry, err := PrepareLog(logEntry)
+	if err != nil {
+		return err
 	}
 
 	if err := s.esClient.Index(ctx, s.index, logEntry.ID, logEntry); err != nil {
 		return err
 	}
 
+	s.publish(logEntry)
 	return nil
 }
 
-func (s *LogService) SearchLogs(ctx context.Context, query map[string]interface{}, size int) ([]Log, error) {
-	return s.esClient.SearchLogs(ctx, s.index, query, size)
+// ProcessBatch indexes the valid entries in a single bulk request. The
+// returned slice is aligned with logEntries and holds nil for every entry
+// that was indexed.
+func (s *LogService) ProcessBatch(ctx context.Context, logEntries []Log) []error {
+	errs := make([]error, len(logEntries))
+	docs := make([]BulkDocument, 0, len(logEntries))
+	positions := make([]int, 0, len(logEntries))
+
+	for i, logEntry := range logEntries {
+		logEntry, err := PrepareLog(logEntry)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		docs = append(docs, BulkDocument{ID: logEntry.ID, Body: logEntry})
+		positions = append(positions, i)
+	}
+
+	if len(docs) == 0 {
+		return errs
+	}
+
+	results, err := s.esClient.BulkIndex(ctx, s.index, docs)
+	if err == nil && len(results) != len(docs) {
+		err = fmt.Errorf("bulk response has %d items, expected %d", len(results), len(docs))
+	}
+	if err != nil {
+		for _, pos := range positions {
+			errs[pos] = err
+		}
+		return errs
+	}
+
+	for i, result := range results {
+		if result.Failed() {
+			errs[positions[i]] = &BulkItemError{ID: result.ID, Status: result.Status, Reason: result.Error}
+			continue
+		}
+		s.publish(docs[i].Body.(Log))
+	}
+
+	return errs
+}
+
+func (s *LogService) SearchLogs(ctx context.Context, query Query) (SearchResult, error) {
+	return s.esClient.SearchLogs(ctx, s.index, query)
+}
+
+func (s *LogService) publish(logEntry Log) {
+	if s.publisher != nil {
+		s.publisher.Publish(logEntry)
+	}
+}
+
+// PrepareLog applies the rules every log must pass before it is stored: ID
+// and message are required and a missing timestamp defaults to now.
+func PrepareLog(logEntry Log) (Log, error) {
+	if logEntry.ID == "" || logEntry.Message == "" {
+		return logEntry, ErrInvalidLog
+	}
+
+	if logEntry.Timestamp.IsZero() {
+		logEntry.Timestamp = time.Now().UTC()
+	}
+
+	return logEntry, nil
 }
 
 func EncodeLog(logEntry Log) ([]byte, error) {
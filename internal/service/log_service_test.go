@@ -11,8 +11,9 @@ import (
 
 type MockElasticSearch struct {
 	Indexed    []Log
-	SearchFunc func(ctx context.Context, index string, query map[string]interface{}, size int) ([]Log, error)
+	SearchFunc func(ctx context.Context, index string, query Query) (SearchResult, error)
 	Err        error
+	FailIDs    map[string]bool
 }
 
 func (m *MockElasticSearch) Index(ctx context.Context, index string, id string, body interface{}) error {
@@ -30,17 +31,43 @@ func (m *MockElasticSearch) Index(ctx context.Context, index string, id string,
 	return nil
 }
 
-func (m *MockElasticSearch) SearchLogs(ctx context.Context, index string, query map[string]interface{}, size int) ([]Log, error) {
+func (m *MockElasticSearch) BulkIndex(ctx context.Context, index string, docs []BulkDocument) ([]BulkItemResult, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	results := make([]BulkItemResult, len(docs))
+	for i, doc := range docs {
+		if m.FailIDs[doc.ID] {
+			results[i] = BulkItemResult{ID: doc.ID, Status: 429, Error: "es_rejected_execution_exception: queue full"}
+			continue
+		}
+		m.Indexed = append(m.Indexed, doc.Body.(Log))
+		results[i] = BulkItemResult{ID: doc.ID, Status: 201}
+	}
+	return results, nil
+}
+
+func (m *MockElasticSearch) SearchLogs(ctx context.Context, index string, query Query) (SearchResult, error) {
 	if m.SearchFunc != nil {
-		return m.SearchFunc(ctx, index, query, size)
+		return m.SearchFunc(ctx, index, query)
 	}
 
 	logs := append([]Log{}, m.Indexed...)
 
-	if len(logs) > size {
-		logs = logs[:size]
+	total := int64(len(logs))
+	if len(logs) > query.Size {
+		logs = logs[:query.Size]
 	}
-	return logs, nil
+	return SearchResult{Logs: logs, Total: total}, nil
+}
+
+func (m *MockElasticSearch) ClosePointInTime(ctx context.Context, id string) error {
+	return m.Err
+}
+
+func (m *MockElasticSearch) AggregateLogs(ctx context.Context, index string, where Condition, aggs []Aggregation) (AggregationResult, error) {
+	return AggregationResult{}, m.Err
 }
 
 func TestProcess(t *testing.T) {
@@ -90,6 +117,41 @@ func TestProcess(t *testing.T) {
 	})
 }
 
+func TestProcessBatch(t *testing.T) {
+
+	t.Run("GIVEN mixed batch WHEN call ProcessBatch THEN report errors per item", func(t *testing.T) {
+		mockES := &MockElasticSearch{FailIDs: map[string]bool{"3": true}}
+		service := NewLogService(mockES, "logs-index")
+		logEntries := []Log{
+			{ID: "1", Message: "first"},
+			{ID: "", Message: "invalid"},
+			{ID: "3", Message: "rejected"},
+			{ID: "4", Message: "fourth"},
+		}
+
+		errs := service.ProcessBatch(context.Background(), logEntries)
+
+		assert.Len(t, errs, 4)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], ErrInvalidLog)
+		assert.ErrorContains(t, errs[2], "status 429")
+		assert.NoError(t, errs[3])
+		assert.Len(t, mockES.Indexed, 2)
+		assert.False(t, mockES.Indexed[0].Timestamp.IsZero(), "must default the timestamp")
+	})
+
+	t.Run("GIVEN bulk request fails WHEN call ProcessBatch THEN fail every valid item", func(t *testing.T) {
+		mockES := &MockElasticSearch{Err: errors.New("ES down")}
+		service := NewLogService(mockES, "logs-index")
+		logEntries := []Log{{ID: "1", Message: "first"}, {ID: "2", Message: "second"}}
+
+		errs := service.ProcessBatch(context.Background(), logEntries)
+
+		assert.EqualError(t, errs[0], "ES down")
+		assert.EqualError(t, errs[1], "ES down")
+	})
+}
+
 func TestLogService_SearchLogs(t *testing.T) {
 	ctx := context.Background()
 	mockES := NewMockElasticSearchClient()
@@ -108,10 +170,50 @@ func TestLogService_SearchLogs(t *testing.T) {
 	}
 
 	// realiza busca
-	query := map[string]interface{}{
-		"match": map[string]interface{}{"level": "INFO"},
+	query := Query{
+		Where: Match{Field: "level", Text: "INFO"},
+		Size:  10,
 	}
-	logs, err := logService.SearchLogs(ctx, query, 10)
+	result, err := logService.SearchLogs(ctx, query)
 	assert.NoError(t, err)
-	assert.Len(t, logs, 5)
+	assert.Len(t, result.Logs, 5)
+	assert.Equal(t, int64(5), result.Total)
+}
+
+type recordingPublisher struct {
+	Published []Log
+}
+
+func (p *recordingPublisher) Publish(logEntry Log) {
+	p.Published = append(p.Published, logEntry)
+}
+
+func TestLogService_Publisher(t *testing.T) {
+	t.Run("GIVEN a publisher WHEN logs are processed THEN publish only the indexed ones", func(t *testing.T) {
+		mockES := &MockElasticSearch{FailIDs: map[string]bool{"3": true}}
+		publisher := &recordingPublisher{}
+		service := NewLogService(mockES, "logs-index")
+		service.SetPublisher(publisher)
+
+		assert.NoError(t, service.Process(context.Background(), Log{ID: "1", Message: "single"}))
+		service.ProcessBatch(context.Background(), []Log{
+			{ID: "2", Message: "indexed"},
+			{ID: "", Message: "invalid"},
+			{ID: "3", Message: "rejected"},
+		})
+
+		assert.Len(t, publisher.Published, 2)
+		assert.Equal(t, "1", publisher.Published[0].ID)
+		assert.Equal(t, "2", publisher.Published[1].ID)
+		assert.False(t, publisher.Published[1].Timestamp.IsZero(), "must publish the prepared log")
+	})
+
+	t.Run("GIVEN Elasticsearch fails WHEN a log is processed THEN publish nothing", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		service := NewLogService(&MockElasticSearch{Err: errors.New("ES down")}, "logs-index")
+		service.SetPublisher(publisher)
+
+		assert.Error(t, service.Process(context.Background(), Log{ID: "1", Message: "single"}))
+		assert.Empty(t, publisher.Published)
+	})
 }
@@ -0,0 +1,145 @@
+package service
+
+// Query describes a log search without tying it to the storage engine. Field
+// names are the JSON names of Log, with dots to reach into nested fields such
+// as metadata.trace-id or kafka.partition.
+type Query struct {
+	// Where selects the logs. A nil condition matches every log.
+	Where Condition
+
+	Sort []SortField
+	Size int
+
+	// SearchAfter holds the sort values of the last hit of the previous page.
+	SearchAfter []interface{}
+
+	// PointInTime pins the search to a snapshot of the index so pages stay
+	// consistent while new logs arrive. An empty ID opens a new one.
+	PointInTime *PointInTime
+
+	TrackTotalHits bool
+}
+
+// Condition is a node of the filter tree: one of Term, Match, Phrase,
+// Wildcard, Range, Exists, And, Or or Not.
+type Condition interface {
+	condition()
+}
+
+// Term matches logs whose field is exactly one of the values.
+type Term struct {
+	Field  string
+	Values []string
+}
+
+// Match is a full-text match requiring every word of Text.
+type Match struct {
+	Field string
+	Text  string
+}
+
+// Phrase is a full-text match of the words of Text in that order.
+type Phrase struct {
+	Field string
+	Text  string
+}
+
+// Wildcard matches values against a pattern where * stands for any run of
+// characters and ? for a single one, ignoring case.
+type Wildcard struct {
+	Field   string
+	Pattern string
+}
+
+// Range matches logs whose field lies between the bounds, inclusive unless
+// marked exclusive. An empty bound is open. Timestamps may use relative
+// expressions such as now-15m.
+type Range struct {
+	Field         string
+	From          string
+	To            string
+	FromExclusive bool
+	ToExclusive   bool
+}
+
+// Exists matches logs that have a value for the field.
+type Exists struct {
+	Field string
+}
+
+// And matches logs satisfying every condition. An empty And matches all logs.
+type And []Condition
+
+// Or matches logs satisfying at least one condition.
+type Or []Condition
+
+// Not matches logs that do not satisfy the condition.
+type Not struct {
+	Condition Condition
+}
+
+func (Term) condition()     {}
+func (Match) condition()    {}
+func (Phrase) condition()   {}
+func (Wildcard) condition() {}
+func (Range) condition()    {}
+func (Exists) condition()   {}
+func (And) condition()      {}
+func (Or) condition()       {}
+func (Not) condition()      {}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+type AggregationKind string
+
+const (
+	// AggregateTerms counts the logs per distinct value of a field.
+	AggregateTerms AggregationKind = "terms"
+	// AggregateDateHistogram counts the logs per time bucket of a field.
+	AggregateDateHistogram AggregationKind = "date_histogram"
+)
+
+type Aggregation struct {
+	Name  string
+	Kind  AggregationKind
+	Field string
+
+	// Size caps the number of buckets of a terms aggregation.
+	Size int
+
+	// Interval is the bucket width of a date histogram, such as 1m or 1h.
+	Interval string
+}
+
+type PointInTime struct {
+	ID        string
+	KeepAlive string
+}
+
+type SearchResult struct {
+	Logs  []Log
+	Total int64
+
+	// SearchAfter holds the sort values of the last hit, used to request the
+	// next page.
+	SearchAfter []interface{}
+
+	// PointInTimeID is the ID to send with the next page, as Elasticsearch may
+	// change it between searches.
+	PointInTimeID string
+}
+
+// AggregationResult holds the buckets of each requested aggregation by name,
+// and the count of all the logs aggregated.
+type AggregationResult struct {
+	Total   int64
+	Buckets map[string][]Bucket
+}
+
+type Bucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
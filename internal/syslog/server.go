@@ -0,0 +1,280 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/parser"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	DefaultMaxMessageBytes = 64 << 10
+	DefaultDrainTimeout    = time.Second
+)
+
+// LogProcessor is the part of the Kafka processor the server feeds, so
+// syslog messages get the same retries, breaker pauses and WAL spill as
+// Kafka messages. While Elasticsearch is down TCP senders are slowed down
+// instead of losing messages.
+type LogProcessor interface {
+	ProcessLog(ctx context.Context, logEntry service.Log) error
+}
+
+// Server receives syslog messages over UDP, one per datagram, and TCP, framed
+// by octet counting or by newlines (RFC 6587). RFC 5424 and RFC 3164 are both
+// accepted; messages that parse as neither are kept raw and tagged like any
+// other parse failure.
+type Server struct {
+	UDPAddr         string
+	TCPAddr         string
+	MaxMessageBytes int
+	// DrainTimeout is how long open TCP connections may keep delivering
+	// the messages already on their way once Close is called.
+	DrainTimeout time.Duration
+	Processor    LogProcessor
+	Parsers      *parser.Registry
+
+	mu        sync.Mutex
+	udpConn   net.PacketConn
+	listener  net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewServer(udpAddr, tcpAddr string, processor LogProcessor) *Server {
+	return &Server{
+		UDPAddr:         udpAddr,
+		TCPAddr:         tcpAddr,
+		MaxMessageBytes: DefaultMaxMessageBytes,
+		DrainTimeout:    DefaultDrainTimeout,
+		Processor:       processor,
+		Parsers:         parser.NewRegistry(),
+		conns:           make(map[net.Conn]struct{}),
+	}
+}
+
+// Start binds the configured addresses and serves them in the background.
+// Messages already received are still processed after ctx is cancelled;
+// Close is what stops the server.
+func (s *Server) Start(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return net.ErrClosed
+	}
+
+	if s.UDPAddr != "" {
+		conn, err := net.ListenPacket("udp", s.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("listening on udp %s: %w", s.UDPAddr, err)
+		}
+		s.udpConn = conn
+		log.Printf("Syslog listening on udp %s", conn.LocalAddr())
+
+		s.wg.Add(1)
+		go s.serveUDP(ctx, conn)
+	}
+
+	if s.TCPAddr != "" {
+		listener, err := net.Listen("tcp", s.TCPAddr)
+		if err != nil {
+			if s.udpConn != nil {
+				s.udpConn.Close()
+			}
+			return fmt.Errorf("listening on tcp %s: %w", s.TCPAddr, err)
+		}
+		s.listener = listener
+		log.Printf("Syslog listening on tcp %s", listener.Addr())
+
+		s.wg.Add(1)
+		go s.serveTCP(ctx, listener)
+	}
+
+	return nil
+}
+
+// UDPLocalAddr and TCPLocalAddr report the bound addresses, useful when the
+// configured port is 0.
+func (s *Server) UDPLocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.udpConn == nil {
+		return nil
+	}
+	return s.udpConn.LocalAddr()
+}
+
+func (s *Server) TCPLocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops accepting messages and drains the open connections: each one
+// keeps reading for up to DrainTimeout, then the call returns once every
+// message read has been processed.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		if s.udpConn != nil {
+			s.udpConn.Close()
+		}
+		if s.listener != nil {
+			s.listener.Close()
+		}
+		deadline := time.Now().Add(s.DrainTimeout)
+		for conn := range s.conns {
+			conn.SetReadDeadline(deadline)
+		}
+		s.mu.Unlock()
+
+		s.wg.Wait()
+	})
+	return nil
+}
+
+func (s *Server) serveUDP(ctx context.Context, conn net.PacketConn) {
+	defer s.wg.Done()
+
+	buf := make([]byte, s.maxMessageBytes())
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Syslog udp read error: %v", err)
+			}
+			return
+		}
+		s.handleMessage(ctx, bytes.Clone(buf[:n]), "udp", addr)
+	}
+}
+
+func (s *Server) serveTCP(ctx context.Context, listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Syslog tcp accept error: %v", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(ctx, conn)
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReaderSize(conn, s.maxMessageBytes())
+	for {
+		frame, err := readFrame(reader, s.maxMessageBytes())
+		if len(frame) > 0 {
+			s.handleMessage(ctx, frame, "tcp", conn.RemoteAddr())
+		}
+		if err != nil {
+			var netErr net.Error
+			if !errors.Is(err, io.EOF) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+				log.Printf("Syslog tcp connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads one message. A frame starting with a digit is octet
+// counted ("LEN SP MSG"), anything else ends at the next newline.
+func readFrame(reader *bufio.Reader, maxBytes int) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		prefix, err := reader.ReadSlice(' ')
+		if err != nil {
+			return nil, fmt.Errorf("invalid octet count: %w", err)
+		}
+		length, err := strconv.Atoi(string(prefix[:len(prefix)-1]))
+		if err != nil || length > maxBytes {
+			return nil, fmt.Errorf("invalid octet count %q", prefix[:len(prefix)-1])
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("message longer than %d bytes", maxBytes)
+	}
+	// A last message without a newline is still a message.
+	return bytes.Clone(bytes.TrimRight(line, "\r\n")), err
+}
+
+func (s *Server) handleMessage(ctx context.Context, data []byte, transport string, peer net.Addr) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return
+	}
+
+	logEntry := s.Parsers.Parse(data, parser.Hints{Format: parser.FormatSyslog})
+	logEntry.ID = rand.Text()
+	if logEntry.Attributes == nil {
+		logEntry.Attributes = make(map[string]interface{})
+	}
+	logEntry.Attributes["syslog_transport"] = transport
+	if peer != nil {
+		logEntry.Attributes["syslog_peer"] = peer.String()
+	}
+
+	if err := s.Processor.ProcessLog(ctx, logEntry); err != nil {
+		log.Printf("Error processing syslog message from %v: %v", peer, err)
+	}
+}
+
+func (s *Server) maxMessageBytes() int {
+	if s.MaxMessageBytes <= 0 {
+		return DefaultMaxMessageBytes
+	}
+	return s.MaxMessageBytes
+}
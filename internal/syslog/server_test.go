@@ -0,0 +1,137 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	mu   sync.Mutex
+	logs []service.Log
+}
+
+func (p *recordingProcessor) ProcessLog(ctx context.Context, logEntry service.Log) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logs = append(p.logs, logEntry)
+	return nil
+}
+
+func (p *recordingProcessor) Logs() []service.Log {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]service.Log{}, p.logs...)
+}
+
+func startServer(t *testing.T) (*Server, *recordingProcessor) {
+	processor := &recordingProcessor{}
+	server := NewServer("127.0.0.1:0", "127.0.0.1:0", processor)
+	server.DrainTimeout = 100 * time.Millisecond
+	require.NoError(t, server.Start(context.Background()))
+	t.Cleanup(func() { server.Close() })
+	return server, processor
+}
+
+func TestServer(t *testing.T) {
+	t.Run("GIVEN an RFC 5424 datagram WHEN received over UDP THEN process it with the mapped level", func(t *testing.T) {
+		server, processor := startServer(t)
+
+		conn, err := net.Dial("udp", server.UDPLocalAddr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		fmt.Fprint(conn, "<11>1 2024-06-10T10:00:00Z router-1 bgpd 42 - - neighbor down")
+
+		require.Eventually(t, func() bool { return len(processor.Logs()) == 1 }, time.Second, 5*time.Millisecond)
+		logEntry := processor.Logs()[0]
+		assert.NotEmpty(t, logEntry.ID)
+		assert.Equal(t, "ERROR", logEntry.Level)
+		assert.Equal(t, "bgpd", logEntry.Source)
+		assert.Equal(t, "neighbor down", logEntry.Message)
+		assert.Equal(t, 1, logEntry.Attributes["syslog_facility"])
+		assert.Equal(t, "udp", logEntry.Attributes["syslog_transport"])
+	})
+
+	t.Run("GIVEN octet counted and newline framed messages WHEN received over TCP THEN process each one", func(t *testing.T) {
+		server, processor := startServer(t)
+
+		conn, err := net.Dial("tcp", server.TCPLocalAddr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		rfc5424 := "<164>1 2024-06-10T10:00:00Z host app - - - multi\nline"
+		fmt.Fprintf(conn, "%d %s", len(rfc5424), rfc5424)
+		fmt.Fprint(conn, "<38>Jun 10 10:00:01 host sshd[123]: Accepted publickey\r\n")
+		fmt.Fprint(conn, "<13>Jun 10 10:00:02 host cron: job done\n")
+
+		require.Eventually(t, func() bool { return len(processor.Logs()) == 3 }, time.Second, 5*time.Millisecond)
+		logs := processor.Logs()
+		assert.Equal(t, "multi\nline", logs[0].Message)
+		assert.Equal(t, "WARN", logs[0].Level)
+		assert.Equal(t, "Accepted publickey", logs[1].Message)
+		assert.Equal(t, "sshd", logs[1].Source)
+		assert.Equal(t, "INFO", logs[1].Level)
+		assert.Equal(t, "job done", logs[2].Message)
+	})
+
+	t.Run("GIVEN an unparsable message WHEN received THEN keep it raw and tagged", func(t *testing.T) {
+		server, processor := startServer(t)
+
+		conn, err := net.Dial("tcp", server.TCPLocalAddr().String())
+		require.NoError(t, err)
+		fmt.Fprint(conn, "not syslog at all\n")
+		conn.Close()
+
+		require.Eventually(t, func() bool { return len(processor.Logs()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "not syslog at all", processor.Logs()[0].Message)
+		assert.NotEmpty(t, processor.Logs()[0].Tags)
+	})
+
+	t.Run("GIVEN an open connection WHEN the server closes THEN drain it and refuse new ones", func(t *testing.T) {
+		server, processor := startServer(t)
+		addr := server.TCPLocalAddr().String()
+
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(conn, "<14>Jun 10 10:00:00 host app: message %d\n", i)
+		}
+		assert.NoError(t, server.Close())
+
+		assert.Len(t, processor.Logs(), 50)
+		_, err = bufio.NewReader(conn).ReadByte()
+		assert.Error(t, err, "must close the drained connection")
+		_, err = net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		assert.Error(t, err)
+	})
+}
+
+func TestReadFrame(t *testing.T) {
+	t.Run("GIVEN an octet count above the limit WHEN reading THEN fail", func(t *testing.T) {
+		_, err := readFrame(bufio.NewReader(strings.NewReader("999 <14>msg")), 100)
+
+		assert.ErrorContains(t, err, "invalid octet count")
+	})
+
+	t.Run("GIVEN a line longer than the limit WHEN reading THEN fail", func(t *testing.T) {
+		_, err := readFrame(bufio.NewReaderSize(strings.NewReader(strings.Repeat("a", 64)+"\n"), 16), 16)
+
+		assert.ErrorContains(t, err, "longer than")
+	})
+
+	t.Run("GIVEN a last message without newline WHEN reading THEN return it with EOF", func(t *testing.T) {
+		frame, err := readFrame(bufio.NewReader(strings.NewReader("<14>last")), 100)
+
+		assert.Equal(t, "<14>last", string(frame))
+		assert.Error(t, err)
+	})
+}
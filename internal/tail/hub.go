@@ -0,0 +1,132 @@
+package tail
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// Filter selects the logs a subscriber receives. Empty lists match
+// everything; levels are compared case-insensitively.
+type Filter struct {
+	Levels  []string
+	Sources []string
+}
+
+func (f Filter) Match(logEntry service.Log) bool {
+	if len(f.Levels) > 0 && !slices.ContainsFunc(f.Levels, func(level string) bool { return strings.EqualFold(level, logEntry.Level) }) {
+		return false
+	}
+	if len(f.Sources) > 0 && !slices.Contains(f.Sources, logEntry.Source) {
+		return false
+	}
+	return true
+}
+
+// Hub fans the indexed logs out to the live tail subscribers. Publishing
+// never blocks: a subscriber whose buffer is full misses the log and the
+// drop is counted on its subscription.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	closed      bool
+	bufferSize  int
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Hub{
+		subscribers: make(map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	logs    chan service.Log
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Logs is closed when the subscription is cancelled or the hub shuts down.
+func (s *Subscription) Logs() <-chan service.Log {
+	return s.logs
+}
+
+// Dropped is the number of logs discarded because the subscriber fell
+// behind.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *Subscription) closeLocked() {
+	s.once.Do(func() {
+		delete(s.hub.subscribers, s)
+		close(s.logs)
+	})
+}
+
+// Subscribe registers a subscriber. Once the hub is closed the returned
+// subscription is already closed.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		logs:   make(chan service.Log, h.bufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		sub.closeLocked()
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Publish(logEntry service.Log) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		if !sub.filter.Match(logEntry) {
+			continue
+		}
+		select {
+		case sub.logs <- logEntry:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+// Close ends every subscription so the streaming handlers return.
+func (h *Hub) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		sub.closeLocked()
+	}
+	return nil
+}
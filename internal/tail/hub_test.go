@@ -0,0 +1,63 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("GIVEN filtered subscribers WHEN publishing THEN deliver only matching logs", func(t *testing.T) {
+		hub := NewHub(10)
+		failures := hub.Subscribe(Filter{Levels: []string{"error"}, Sources: []string{"payments"}})
+		all := hub.Subscribe(Filter{})
+
+		hub.Publish(service.Log{ID: "1", Level: "ERROR", Source: "payments"})
+		hub.Publish(service.Log{ID: "2", Level: "INFO", Source: "payments"})
+		hub.Publish(service.Log{ID: "3", Level: "ERROR", Source: "checkout"})
+
+		assert.Len(t, failures.Logs(), 1)
+		assert.Equal(t, "1", (<-failures.Logs()).ID)
+		assert.Len(t, all.Logs(), 3)
+	})
+
+	t.Run("GIVEN a full buffer WHEN publishing THEN drop and count without blocking", func(t *testing.T) {
+		hub := NewHub(2)
+		sub := hub.Subscribe(Filter{})
+
+		for i := 0; i < 5; i++ {
+			hub.Publish(service.Log{ID: "x"})
+		}
+
+		assert.Len(t, sub.Logs(), 2)
+		assert.Equal(t, uint64(3), sub.Dropped())
+	})
+
+	t.Run("GIVEN a cancelled subscription WHEN publishing THEN it is no longer served", func(t *testing.T) {
+		hub := NewHub(2)
+		sub := hub.Subscribe(Filter{})
+
+		sub.Close()
+		sub.Close()
+		hub.Publish(service.Log{ID: "1"})
+
+		_, open := <-sub.Logs()
+		assert.False(t, open)
+		assert.Equal(t, 0, hub.Subscribers())
+	})
+
+	t.Run("GIVEN a closed hub WHEN subscribing THEN every subscription is closed", func(t *testing.T) {
+		hub := NewHub(2)
+		before := hub.Subscribe(Filter{})
+
+		assert.NoError(t, hub.Close())
+		after := hub.Subscribe(Filter{})
+
+		_, open := <-before.Logs()
+		assert.False(t, open)
+		_, open = <-after.Logs()
+		assert.False(t, open)
+		assert.Equal(t, 0, hub.Subscribers())
+	})
+}
@@ -0,0 +1,47 @@
+package wal
+
+import (
+	"context"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+type MockElasticSearchClient struct {
+	Indexed []service.Log
+	Err     error
+	// Status is reported for every document when set.
+	Status int
+}
+
+func (m *MockElasticSearchClient) Index(ctx context.Context, index string, id string, body interface{}) error {
+	return m.Err
+}
+
+func (m *MockElasticSearchClient) BulkIndex(ctx context.Context, index string, docs []service.BulkDocument) ([]service.BulkItemResult, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	results := make([]service.BulkItemResult, len(docs))
+	for i, doc := range docs {
+		if m.Status >= 300 {
+			results[i] = service.BulkItemResult{ID: doc.ID, Status: m.Status, Error: "rejected"}
+			continue
+		}
+		m.Indexed = append(m.Indexed, doc.Body.(service.Log))
+		results[i] = service.BulkItemResult{ID: doc.ID, Status: 201}
+	}
+	return results, nil
+}
+
+func (m *MockElasticSearchClient) SearchLogs(ctx context.Context, index string, query service.Query) (service.SearchResult, error) {
+	return service.SearchResult{}, m.Err
+}
+
+func (m *MockElasticSearchClient) ClosePointInTime(ctx context.Context, id string) error {
+	return m.Err
+}
+
+func (m *MockElasticSearchClient) AggregateLogs(ctx context.Context, index string, where service.Condition, aggs []service.Aggregation) (service.AggregationResult, error) {
+	return service.AggregationResult{}, m.Err
+}
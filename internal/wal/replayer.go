@@ -0,0 +1,167 @@
+package wal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/retry"
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+// Replayer drains the WAL into Elasticsearch in bulk requests. It only runs
+// while Ready reports the cluster as healthy.
+type Replayer struct {
+	WAL       *WAL
+	Client    service.ElasticSearchClient
+	Index     string
+	BatchSize int
+	Interval  time.Duration
+	Ready     func() bool
+}
+
+func NewReplayer(w *WAL, client service.ElasticSearchClient, index string, interval time.Duration, ready func() bool) *Replayer {
+	return &Replayer{
+		WAL:       w,
+		Client:    client,
+		Index:     index,
+		BatchSize: 500,
+		Interval:  interval,
+		Ready:     ready,
+	}
+}
+
+func (r *Replayer) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !r.WAL.Pending() || (r.Ready != nil && !r.Ready()) {
+			continue
+		}
+
+		if err := r.ReplayOnce(ctx); err != nil {
+			log.Printf("WAL replay paused: %v", err)
+		}
+
+		stats := r.WAL.Stats()
+		log.Printf("WAL replay progress: %d replayed, %d dropped, %d bytes pending in %d segments",
+			stats.ReplayedRecords, stats.DroppedRecords, stats.PendingBytes, stats.Segments)
+	}
+}
+
+// ReplayOnce replays every pending record, stopping at the first failure that
+// may succeed later. Progress is checkpointed after each bulk request.
+func (r *Replayer) ReplayOnce(ctx context.Context) error {
+	for {
+		seq, offset, ok, err := r.WAL.nextSegment()
+		if err != nil || !ok {
+			return err
+		}
+
+		if err := r.replaySegment(ctx, seq, offset); err != nil {
+			r.WAL.recordReplay(err, 0)
+			return err
+		}
+	}
+}
+
+func (r *Replayer) replaySegment(ctx context.Context, seq uint64, offset int64) error {
+	path := r.WAL.segmentPath(seq)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var batch []service.Log
+	var batchEnd int64
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		dropped, err := r.index(ctx, batch)
+		if err != nil {
+			return err
+		}
+		r.WAL.recordReplay(nil, dropped)
+		if err := r.WAL.advance(seq, batchEnd, int64(len(batch))-dropped); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	_, _, scanErr := scanSegment(f, offset, func(payload []byte, next int64) error {
+		var logEntry service.Log
+		if err := json.Unmarshal(payload, &logEntry); err != nil {
+			return fmt.Errorf("%w: %v", errCorrupt, err)
+		}
+
+		batch = append(batch, logEntry)
+		batchEnd = next
+		if len(batch) >= max(r.BatchSize, 1) {
+			return flush()
+		}
+		return nil
+	})
+
+	if scanErr != nil && !errors.Is(scanErr, errCorrupt) {
+		return scanErr
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	f.Close()
+	if scanErr != nil {
+		return r.WAL.quarantine(seq, info.Size(), scanErr)
+	}
+	return r.WAL.release(seq, info.Size())
+}
+
+// index sends the logs in one bulk request. Documents rejected for good are
+// dropped and counted; any retryable failure aborts the replay.
+func (r *Replayer) index(ctx context.Context, logs []service.Log) (int64, error) {
+	docs := make([]service.BulkDocument, len(logs))
+	for i, logEntry := range logs {
+		docs[i] = service.BulkDocument{ID: logEntry.ID, Body: logEntry}
+	}
+
+	results, err := r.Client.BulkIndex(ctx, r.Index, docs)
+	if err != nil {
+		return 0, err
+	}
+
+	var dropped int64
+	for _, result := range results {
+		if !result.Failed() {
+			continue
+		}
+
+		itemErr := &service.BulkItemError{ID: result.ID, Status: result.Status, Reason: result.Error}
+		if retry.Transient(itemErr) {
+			return 0, itemErr
+		}
+
+		log.Printf("Dropping WAL record: %v", itemErr)
+		dropped++
+	}
+
+	return dropped, nil
+}
@@ -0,0 +1,452 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+)
+
+const (
+	segmentExt     = ".wal"
+	corruptExt     = ".corrupt"
+	checkpointFile = "checkpoint.json"
+	headerSize     = 8
+	maxRecordSize  = 64 << 20
+)
+
+var (
+	ErrFull    = errors.New("write-ahead log is full")
+	ErrClosed  = errors.New("write-ahead log is closed")
+	errCorrupt = errors.New("corrupt record")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type Stats struct {
+	Dir             string     `json:"dir"`
+	Segments        int        `json:"segments"`
+	PendingBytes    int64      `json:"pending_bytes"`
+	MaxBytes        int64      `json:"max_bytes"`
+	AppendedRecords int64      `json:"appended_records"`
+	ReplayedRecords int64      `json:"replayed_records"`
+	DroppedRecords  int64      `json:"dropped_records"`
+	CorruptSegments int64      `json:"corrupt_segments"`
+	LastReplayAt    *time.Time `json:"last_replay_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+type checkpoint struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// WAL is a bounded, segmented write-ahead log of service.Log records kept
+// under Dir. Every record is framed as a 4-byte length, a 4-byte CRC-32C of
+// the payload and the JSON payload, so torn writes and corrupted segments are
+// detected when the log is opened or replayed.
+type WAL struct {
+	dir             string
+	maxSegmentBytes int64
+	maxTotalBytes   int64
+
+	mu         sync.Mutex
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+	sealed     []uint64
+	totalBytes int64
+	checkpoint checkpoint
+	stats      Stats
+	closed     bool
+}
+
+func Open(dir string, maxSegmentBytes, maxTotalBytes int64) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	w := &WAL{
+		dir:             dir,
+		maxSegmentBytes: maxSegmentBytes,
+		maxTotalBytes:   maxTotalBytes,
+	}
+
+	seqs, err := w.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.loadCheckpoint(); err != nil {
+		return nil, err
+	}
+
+	for _, seq := range seqs {
+		info, err := os.Stat(w.segmentPath(seq))
+		if err != nil {
+			return nil, err
+		}
+		w.totalBytes += info.Size()
+	}
+
+	if len(seqs) > 0 {
+		w.sealed = seqs[:len(seqs)-1]
+		if err := w.openActive(seqs[len(seqs)-1]); err != nil {
+			return nil, err
+		}
+	} else if err := w.openActive(1); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Append durably writes the logs: it returns only after they are synced to
+// disk. It fails with ErrFull when the log would exceed its size cap.
+func (w *WAL) Append(logs ...service.Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	var buf []byte
+	for _, logEntry := range logs {
+		payload, err := json.Marshal(logEntry)
+		if err != nil {
+			return err
+		}
+		buf = appendRecord(buf, payload)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	if w.maxTotalBytes > 0 && w.totalBytes+int64(len(buf)) > w.maxTotalBytes {
+		return ErrFull
+	}
+
+	if w.maxSegmentBytes > 0 && w.activeSize > 0 && w.activeSize+int64(len(buf)) > w.maxSegmentBytes {
+		if err := w.sealActive(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.active.Write(buf); err != nil {
+		return err
+	}
+	if err := w.active.Sync(); err != nil {
+		return err
+	}
+
+	w.activeSize += int64(len(buf))
+	w.totalBytes += int64(len(buf))
+	w.stats.AppendedRecords += int64(len(logs))
+	return nil
+}
+
+func (w *WAL) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.Dir = w.dir
+	stats.Segments = len(w.sealed) + 1
+	stats.PendingBytes = w.totalBytes - w.checkpoint.Offset
+	stats.MaxBytes = w.maxTotalBytes
+	return stats
+}
+
+// Pending reports whether there are records left to replay.
+func (w *WAL) Pending() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.totalBytes-w.checkpoint.Offset > 0
+}
+
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.active.Close()
+}
+
+// nextSegment seals the active segment when nothing else is waiting, and
+// returns the oldest sealed segment with the offset to resume from.
+func (w *WAL) nextSegment() (uint64, int64, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, 0, false, ErrClosed
+	}
+
+	if len(w.sealed) == 0 {
+		if w.activeSize == 0 {
+			return 0, 0, false, nil
+		}
+		if err := w.sealActive(); err != nil {
+			return 0, 0, false, err
+		}
+	}
+
+	seq := w.sealed[0]
+	offset := int64(0)
+	if w.checkpoint.Segment == seq {
+		offset = w.checkpoint.Offset
+	}
+	return seq, offset, true, nil
+}
+
+// advance records the replay progress inside a sealed segment.
+func (w *WAL) advance(seq uint64, offset int64, replayed int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.checkpoint = checkpoint{Segment: seq, Offset: offset}
+	w.stats.ReplayedRecords += replayed
+	return w.saveCheckpoint()
+}
+
+// release deletes a fully replayed (or quarantined) sealed segment.
+func (w *WAL) release(seq uint64, size int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.sealed) > 0 && w.sealed[0] == seq {
+		w.sealed = w.sealed[1:]
+	}
+	w.totalBytes -= size
+	w.checkpoint = checkpoint{}
+	if err := os.Remove(w.segmentPath(seq)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return w.saveCheckpoint()
+}
+
+func (w *WAL) quarantine(seq uint64, size int64, cause error) error {
+	log.Printf("WAL segment %d is corrupt, quarantining it: %v", seq, cause)
+
+	w.mu.Lock()
+	w.stats.CorruptSegments++
+	w.mu.Unlock()
+
+	if err := os.Rename(w.segmentPath(seq), w.segmentPath(seq)+corruptExt); err != nil {
+		return err
+	}
+	return w.release(seq, size)
+}
+
+func (w *WAL) recordReplay(err error, dropped int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now().UTC()
+	w.stats.LastReplayAt = &now
+	w.stats.DroppedRecords += dropped
+	w.stats.LastError = ""
+	if err != nil {
+		w.stats.LastError = err.Error()
+	}
+}
+
+// sealActive must be called with mu held.
+func (w *WAL) sealActive() error {
+	if err := w.active.Close(); err != nil {
+		return err
+	}
+	w.sealed = append(w.sealed, w.activeSeq)
+	return w.openActive(w.activeSeq + 1)
+}
+
+// openActive opens a segment for appending, truncating any torn write left
+// at its end by a crash. A segment damaged before its end is sealed instead,
+// for the replayer to replay its valid records and quarantine it.
+func (w *WAL) openActive(seq uint64) error {
+	path := w.segmentPath(seq)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+
+	valid, _, scanErr := scanSegment(f, 0, nil)
+	if scanErr != nil && !errors.Is(scanErr, errCorrupt) {
+		f.Close()
+		return scanErr
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if scanErr != nil {
+		torn, err := tornTail(f, valid, info.Size())
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if !torn {
+			log.Printf("WAL segment %d is corrupt before its end, sealing it: %v", seq, scanErr)
+			f.Close()
+			w.sealed = append(w.sealed, seq)
+			return w.openActive(seq + 1)
+		}
+	}
+	if info.Size() > valid {
+		log.Printf("WAL segment %d has a torn tail, truncating %d bytes", seq, info.Size()-valid)
+		if err := f.Truncate(valid); err != nil {
+			f.Close()
+			return err
+		}
+		w.totalBytes -= info.Size() - valid
+	}
+
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	w.active = f
+	w.activeSeq = seq
+	w.activeSize = valid
+	return nil
+}
+
+func (w *WAL) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (w *WAL) segmentPath(seq uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+func (w *WAL) loadCheckpoint() error {
+	data, err := os.ReadFile(filepath.Join(w.dir, checkpointFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &w.checkpoint)
+}
+
+func (w *WAL) saveCheckpoint() error {
+	data, err := json.Marshal(w.checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(w.dir, checkpointFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(w.dir, checkpointFile))
+}
+
+func appendRecord(buf []byte, payload []byte) []byte {
+	var header [headerSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.Checksum(payload, crcTable))
+	buf = append(buf, header[:]...)
+	return append(buf, payload...)
+}
+
+// tornTail reports whether the damaged record at offset runs to the end of
+// the segment, as a write interrupted by a crash does. Records found damaged
+// with more data after them were corrupted once written.
+func tornTail(f *os.File, offset, size int64) (bool, error) {
+	if size-offset < headerSize {
+		return true, nil
+	}
+
+	var header [headerSize]byte
+	if _, err := f.ReadAt(header[:], offset); err != nil {
+		return false, err
+	}
+	recordSize := binary.BigEndian.Uint32(header[0:4])
+	if recordSize > maxRecordSize {
+		return false, nil
+	}
+	return offset+headerSize+int64(recordSize) >= size, nil
+}
+
+// scanSegment reads the records of f starting at offset and calls fn for
+// each one with the offset right after it. It returns the offset of the last
+// valid record end, and errCorrupt if it stopped on a damaged record.
+func scanSegment(f *os.File, offset int64, fn func(payload []byte, next int64) error) (int64, int, error) {
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, 0, err
+	}
+
+	reader := bufio.NewReader(f)
+	count := 0
+	var header [headerSize]byte
+	for {
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			if err == io.EOF {
+				return offset, count, nil
+			}
+			return offset, count, fmt.Errorf("%w: truncated header at offset %d", errCorrupt, offset)
+		}
+
+		size := binary.BigEndian.Uint32(header[0:4])
+		if size > maxRecordSize {
+			return offset, count, fmt.Errorf("%w: invalid record size %d at offset %d", errCorrupt, size, offset)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return offset, count, fmt.Errorf("%w: truncated payload at offset %d", errCorrupt, offset)
+		}
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+			return offset, count, fmt.Errorf("%w: checksum mismatch at offset %d", errCorrupt, offset)
+		}
+
+		next := offset + headerSize + int64(size)
+		if fn != nil {
+			if err := fn(payload, next); err != nil {
+				return offset, count, err
+			}
+		}
+		offset = next
+		count++
+	}
+}
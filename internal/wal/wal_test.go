@@ -0,0 +1,187 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rodrigogmartins/log-processor/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLogs(n int) []service.Log {
+	logs := make([]service.Log, n)
+	for i := range logs {
+		logs[i] = service.Log{ID: fmt.Sprint(i), Message: fmt.Sprintf("msg %d", i), Timestamp: time.Now().UTC()}
+	}
+	return logs
+}
+
+func TestWAL_AppendAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 256, 1<<20)
+	require.NoError(t, err)
+	defer w.Close()
+
+	for _, logEntry := range newLogs(10) {
+		require.NoError(t, w.Append(logEntry))
+	}
+	assert.True(t, w.Pending())
+	assert.Greater(t, w.Stats().Segments, 1, "must roll segments past the size cap")
+
+	mockES := &MockElasticSearchClient{}
+	replayer := NewReplayer(w, mockES, "logs-index", time.Hour, nil)
+	replayer.BatchSize = 3
+
+	require.NoError(t, replayer.ReplayOnce(context.Background()))
+
+	assert.Len(t, mockES.Indexed, 10)
+	assert.Equal(t, "0", mockES.Indexed[0].ID)
+	assert.Equal(t, "9", mockES.Indexed[9].ID)
+	assert.False(t, w.Pending())
+
+	stats := w.Stats()
+	assert.Equal(t, int64(10), stats.AppendedRecords)
+	assert.Equal(t, int64(10), stats.ReplayedRecords)
+	assert.Equal(t, int64(0), stats.PendingBytes)
+	assert.NotNil(t, stats.LastReplayAt)
+}
+
+func TestWAL_Full(t *testing.T) {
+	w, err := Open(t.TempDir(), 0, 200)
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, w.Append(newLogs(1)...))
+	assert.ErrorIs(t, w.Append(newLogs(5)...), ErrFull)
+}
+
+func TestWAL_ReplayFailureKeepsRecords(t *testing.T) {
+	w, err := Open(t.TempDir(), 0, 1<<20)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Append(newLogs(3)...))
+
+	mockES := &MockElasticSearchClient{Err: errors.New("connection refused")}
+	replayer := NewReplayer(w, mockES, "logs-index", time.Hour, nil)
+
+	assert.Error(t, replayer.ReplayOnce(context.Background()))
+	assert.True(t, w.Pending())
+	assert.Equal(t, "connection refused", w.Stats().LastError)
+
+	mockES.Err = nil
+	require.NoError(t, replayer.ReplayOnce(context.Background()))
+	assert.Len(t, mockES.Indexed, 3)
+}
+
+func TestWAL_DropsRejectedRecords(t *testing.T) {
+	w, err := Open(t.TempDir(), 0, 1<<20)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Append(newLogs(2)...))
+
+	replayer := NewReplayer(w, &MockElasticSearchClient{Status: 400}, "logs-index", time.Hour, nil)
+
+	require.NoError(t, replayer.ReplayOnce(context.Background()))
+	assert.False(t, w.Pending())
+	assert.Equal(t, int64(2), w.Stats().DroppedRecords)
+}
+
+func TestWAL_CorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 256, 1<<20)
+	require.NoError(t, err)
+	for _, logEntry := range newLogs(4) {
+		require.NoError(t, w.Append(logEntry))
+	}
+	require.NoError(t, w.Close())
+
+	// Flip a byte inside the last record of the first, sealed, segment.
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentExt))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[len(data)-3] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	w, err = Open(dir, 256, 1<<20)
+	require.NoError(t, err)
+	defer w.Close()
+
+	mockES := &MockElasticSearchClient{}
+	require.NoError(t, NewReplayer(w, mockES, "logs-index", time.Hour, nil).ReplayOnce(context.Background()))
+
+	var ids []string
+	for _, l := range mockES.Indexed {
+		ids = append(ids, l.ID)
+	}
+	assert.Equal(t, []string{"0", "2", "3"}, ids, "records before the damage and in later segments are kept")
+	assert.Equal(t, int64(1), w.Stats().CorruptSegments)
+	_, err = os.Stat(path + corruptExt)
+	assert.NoError(t, err, "corrupt segment must be quarantined")
+}
+
+func TestWAL_CorruptActiveSegment(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 0, 1<<20)
+	require.NoError(t, err)
+	for _, logEntry := range newLogs(3) {
+		require.NoError(t, w.Append(logEntry))
+	}
+	require.NoError(t, w.Close())
+
+	// Flip a byte inside the first record of the active segment, which is
+	// followed by valid ones.
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentExt))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data[headerSize+2] ^= 0xff
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	w, err = Open(dir, 0, 1<<20)
+	require.NoError(t, err)
+	defer w.Close()
+	require.NoError(t, w.Append(service.Log{ID: "new", Message: "after restart"}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), info.Size(), "records after the damage must not be truncated")
+
+	mockES := &MockElasticSearchClient{}
+	require.NoError(t, NewReplayer(w, mockES, "logs-index", time.Hour, nil).ReplayOnce(context.Background()))
+
+	require.Len(t, mockES.Indexed, 1)
+	assert.Equal(t, "new", mockES.Indexed[0].ID)
+	assert.Equal(t, int64(1), w.Stats().CorruptSegments)
+	quarantined, err := os.ReadFile(path + corruptExt)
+	require.NoError(t, err, "corrupt segment must be quarantined")
+	assert.Equal(t, data, quarantined)
+}
+
+func TestWAL_TornTail(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 0, 1<<20)
+	require.NoError(t, err)
+	require.NoError(t, w.Append(newLogs(2)...))
+	require.NoError(t, w.Close())
+
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 1, segmentExt))
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 9, 1})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = Open(dir, 0, 1<<20)
+	require.NoError(t, err)
+	defer w.Close()
+
+	mockES := &MockElasticSearchClient{}
+	require.NoError(t, NewReplayer(w, mockES, "logs-index", time.Hour, nil).ReplayOnce(context.Background()))
+	assert.Len(t, mockES.Indexed, 2)
+	assert.Equal(t, int64(0), w.Stats().CorruptSegments)
+}